@@ -0,0 +1,88 @@
+package airlines
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Alliance membership is bundled with the binary, since it changes rarely and none of the
+// APIs we use expose it. The data should be refreshed whenever a carrier switches alliance.
+//
+//go:embed alliances.json
+var allianceData []byte
+
+type allianceFile struct {
+	AsOf      string              `json:"asOf"`
+	Alliances map[string][]string `json:"alliances"`
+}
+
+var alliances = loadAlliances()
+
+func loadAlliances() map[string][]string {
+
+	var data allianceFile
+	if err := json.Unmarshal(allianceData, &data); err != nil {
+		// The file is embedded at build time, so this can only happen if it was edited incorrectly
+		panic(fmt.Sprintf("parsing embedded alliance data: %v", err))
+	}
+
+	return data.Alliances
+}
+
+// Gets the IATA codes of the member airlines of the given alliance. The alliance name is
+// matched case-insensitively, and ignoring spaces (e.g. "star alliance" or "StarAlliance").
+func GetMembers(alliance string) (members []string, err error) {
+
+	name, found := findAlliance(alliance)
+	if !found {
+		return nil, fmt.Errorf("unknown alliance %q (expected one of: %s)", alliance, strings.Join(GetAlliances(), ", "))
+	}
+
+	members = make([]string, len(alliances[name]))
+	copy(members, alliances[name])
+	return members, nil
+}
+
+// Gets the name of the alliance which the given airline belongs to, if any
+func GetAlliance(airlineCode string) (alliance string, found bool) {
+
+	for name, members := range alliances {
+		for _, member := range members {
+			if member == airlineCode {
+				return name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Gets the names of all the known alliances, in alphabetical order
+func GetAlliances() []string {
+
+	names := make([]string, 0, len(alliances))
+	for name := range alliances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func findAlliance(alliance string) (name string, found bool) {
+
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	}
+
+	for name := range alliances {
+		if normalize(name) == normalize(alliance) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
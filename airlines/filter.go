@@ -0,0 +1,110 @@
+package airlines
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Airline preferences for a search. An airline is allowed if no allow-list is given, or if it
+// appears in Include or is a member of one of the Alliances. Airlines in Exclude are never
+// allowed, even if they would otherwise be included by their alliance.
+type Filter struct {
+	Include   []string
+	Exclude   []string
+	Alliances []string
+}
+
+// Checks that all of the alliance names are known and the airline codes look like IATA codes
+func (filter Filter) Validate() error {
+
+	for _, alliance := range filter.Alliances {
+		if _, err := GetMembers(alliance); err != nil {
+			return err
+		}
+	}
+
+	for _, code := range append(append([]string{}, filter.Include...), filter.Exclude...) {
+		if len(code) != 2 {
+			return fmt.Errorf("invalid airline code %q (expected a 2-character IATA code)", code)
+		}
+	}
+
+	return nil
+}
+
+// Returns true if neither an allow-list nor a deny-list has been given
+func (filter Filter) IsEmpty() bool {
+	return len(filter.Include) == 0 && len(filter.Exclude) == 0 && len(filter.Alliances) == 0
+}
+
+// Checks whether the given airline satisfies the filter
+func (filter Filter) Allows(airlineCode string) bool {
+
+	airlineCode = strings.ToUpper(airlineCode)
+
+	for _, code := range filter.Exclude {
+		if strings.ToUpper(code) == airlineCode {
+			return false
+		}
+	}
+
+	allowed := filter.AllowedCodes()
+	if allowed == nil {
+		return true
+	}
+
+	for _, code := range allowed {
+		if code == airlineCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Gets the full allow-list, combining the included airlines with the members of the included
+// alliances, and removing any excluded airlines. Returns nil if there is no allow-list.
+// Note: Unknown alliances are ignored here, so Validate should be called first.
+func (filter Filter) AllowedCodes() []string {
+
+	if len(filter.Include) == 0 && len(filter.Alliances) == 0 {
+		return nil
+	}
+
+	codes := make(map[string]bool)
+	for _, code := range filter.Include {
+		codes[strings.ToUpper(code)] = true
+	}
+	for _, alliance := range filter.Alliances {
+		members, _ := GetMembers(alliance)
+		for _, code := range members {
+			codes[code] = true
+		}
+	}
+	for _, code := range filter.Exclude {
+		delete(codes, strings.ToUpper(code))
+	}
+
+	// Note: An allow-list which has been emptied by the exclusions is returned as an empty
+	// (non-nil) slice, since it still needs to reject everything
+	allowed := make([]string, 0, len(codes))
+	for code := range codes {
+		allowed = append(allowed, code)
+	}
+	sort.Strings(allowed)
+
+	return allowed
+}
+
+// Gets the upper-cased deny-list
+func (filter Filter) ExcludedCodes() []string {
+
+	excluded := make([]string, 0, len(filter.Exclude))
+	for _, code := range filter.Exclude {
+		excluded = append(excluded, strings.ToUpper(code))
+	}
+	sort.Strings(excluded)
+
+	return excluded
+}
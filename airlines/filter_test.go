@@ -0,0 +1,44 @@
+package airlines
+
+import "testing"
+
+func TestFilterAllows(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		airline  string
+		expected bool
+	}{
+		{"empty filter allows everything", Filter{}, "DY", true},
+		{"included airline", Filter{Include: []string{"DY"}}, "DY", true},
+		{"airline not included", Filter{Include: []string{"DY"}}, "SK", false},
+		{"excluded airline", Filter{Exclude: []string{"FR"}}, "FR", false},
+		{"airline not excluded", Filter{Exclude: []string{"FR"}}, "DY", true},
+		{"alliance member", Filter{Alliances: []string{"star alliance"}}, "LH", true},
+		{"not an alliance member", Filter{Alliances: []string{"Star Alliance"}}, "DY", false},
+		{"excluded alliance member", Filter{Alliances: []string{"SkyTeam"}, Exclude: []string{"KL"}}, "KL", false},
+		{"everything excluded", Filter{Include: []string{"DY"}, Exclude: []string{"DY"}}, "SK", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.filter.Allows(test.airline); actual != test.expected {
+				t.Errorf("Allows(%s) returned %v; Expected %v", test.airline, actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+
+	if err := (Filter{Alliances: []string{"Rainbow Alliance"}}).Validate(); err == nil {
+		t.Error("Expected an error for an unknown alliance")
+	}
+	if err := (Filter{Exclude: []string{"NAX"}}).Validate(); err == nil {
+		t.Error("Expected an error for an ICAO airline code")
+	}
+	if err := (Filter{Include: []string{"DY"}, Alliances: []string{"oneworld"}}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"flynow/airports"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Runs the airport command, which searches the airport database by code, city or name:
+//
+//	flynow airport <query>
+func runAirport(args []string, out io.Writer) error {
+
+	flags := flag.NewFlagSet("flynow airport", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: flynow airport <code, city or name>")
+	}
+	query := strings.Join(flags.Args(), " ")
+
+	matches := airports.Search(query)
+	if len(matches) == 0 {
+		return fmt.Errorf("no airports match %q", query)
+	}
+
+	for _, airport := range matches {
+		fmt.Fprintf(out, "%s\t%s\t%s, %s, %s\t%.4f, %.4f\t%s\n", airport.Iata, airport.Icao, airport.Name, airport.City, airport.Country, airport.Latitude, airport.Longitude, airport.TimeZone)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package airports
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// The time zone database is bundled too, so that local times work on systems without one
+	_ "time/tzdata"
+)
+
+// The airport data is bundled with the binary, so that codes can be checked and described without any
+// API calls. It covers the airports served from the Nordic countries and the main European and long-haul
+// hubs, and should be extended whenever a search turns up an airport which isn't in it.
+//
+//go:embed airports.csv
+var airportData []byte
+
+type Airport struct {
+	Iata      string
+	Icao      string
+	Name      string
+	City      string
+	Country   string // ISO 3166-1 alpha-2 code
+	Latitude  float64
+	Longitude float64
+	TimeZone  string // IANA time zone name, e.g. Europe/Oslo
+}
+
+var airports, byCode = loadAirports()
+
+func loadAirports() ([]Airport, map[string]*Airport) {
+
+	records, err := csv.NewReader(bytes.NewReader(airportData)).ReadAll()
+	if err != nil || len(records) == 0 {
+		// The file is embedded at build time, so this can only happen if it was edited incorrectly
+		panic(fmt.Sprintf("parsing embedded airport data: %v", err))
+	}
+
+	list := make([]Airport, 0, len(records)-1)
+	for i, record := range records[1:] {
+		airport, err := parseAirport(record)
+		if err != nil {
+			panic(fmt.Sprintf("parsing embedded airport data (line %d): %v", i+2, err))
+		}
+		list = append(list, airport)
+	}
+
+	codes := make(map[string]*Airport, 2*len(list))
+	for i := range list {
+		codes[list[i].Iata] = &list[i]
+		codes[list[i].Icao] = &list[i]
+	}
+
+	return list, codes
+}
+
+func parseAirport(record []string) (airport Airport, err error) {
+
+	airport = Airport{Iata: record[0], Icao: record[1], Name: record[2], City: record[3], Country: record[4], TimeZone: record[7]}
+	if airport.Latitude, err = strconv.ParseFloat(record[5], 64); err != nil {
+		return airport, fmt.Errorf("latitude: %w", err)
+	}
+	if airport.Longitude, err = strconv.ParseFloat(record[6], 64); err != nil {
+		return airport, fmt.Errorf("longitude: %w", err)
+	}
+
+	return airport, nil
+}
+
+// Finds an airport by its IATA (e.g. OSL) or ICAO (e.g. ENGM) code, ignoring case
+func Lookup(code string) (airport Airport, found bool) {
+
+	if a, found := byCode[strings.ToUpper(strings.TrimSpace(code))]; found {
+		return *a, true
+	}
+	return airport, false
+}
+
+// Checks that the code is the IATA code of a known airport
+func Validate(code string) error {
+
+	if airport, found := Lookup(code); !found || airport.Iata != strings.ToUpper(code) {
+		return fmt.Errorf("unknown airport %q (not in the airport database)", code)
+	}
+	return nil
+}
+
+// Gets all the known airports, in the order of the data file
+func GetAll() []Airport {
+	return append([]Airport(nil), airports...)
+}
+
+// Finds the airports matching the query, which can be an IATA or ICAO code, or part of the name, city or
+// country code. Letters such as ø and é match o and e, so that names can be typed on any keyboard. An
+// exact code match comes first, followed by the airports whose city or name starts with the query.
+func Search(query string) []Airport {
+
+	query = fold(strings.TrimSpace(query))
+	if query == "" {
+		return []Airport{}
+	}
+
+	type match struct {
+		airport Airport
+		rank    int
+	}
+	matches := make([]match, 0)
+	for _, airport := range airports {
+		city, name := fold(airport.City), fold(airport.Name)
+		switch {
+		case fold(airport.Iata) == query || fold(airport.Icao) == query:
+			matches = append(matches, match{airport, 0})
+		case strings.HasPrefix(city, query) || strings.HasPrefix(name, query):
+			matches = append(matches, match{airport, 1})
+		case strings.Contains(city, query) || strings.Contains(name, query) || fold(airport.Country) == query:
+			matches = append(matches, match{airport, 2})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].rank < matches[j].rank })
+
+	results := make([]Airport, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, m.airport)
+	}
+	return results
+}
+
+// Gets the time zone of the airport with the given code, or UTC if the airport or its time zone is unknown
+func GetLocation(code string) *time.Location {
+
+	if airport, found := Lookup(code); found {
+		return airport.GetLocation()
+	}
+	return time.UTC
+}
+
+// Gets the airport's time zone, or UTC if it is unknown
+func (airport Airport) GetLocation() *time.Location {
+
+	location, err := time.LoadLocation(airport.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Gets a short description for display, e.g. "Oslo (OSL)"
+func (airport Airport) GetShortName() string {
+	return fmt.Sprintf("%s (%s)", airport.City, airport.Iata)
+}
+
+func (airport Airport) String() string {
+	return fmt.Sprintf("%s %s, %s, %s", airport.Iata, airport.Name, airport.City, airport.Country)
+}
+
+// Gets a short description of the airport with the given code for display, or just the code if it is unknown
+func Describe(code string) string {
+
+	if airport, found := Lookup(code); found {
+		return airport.GetShortName()
+	}
+	return code
+}
+
+// Letters which are compared as their plain equivalents
+var foldReplacer = strings.NewReplacer(
+	"å", "a", "ä", "a", "á", "a", "à", "a", "â", "a", "ã", "a",
+	"æ", "ae", "ø", "o", "ö", "o", "ó", "o", "ô", "o", "õ", "o",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ć", "c", "č", "c", "ñ", "n", "ń", "n", "ł", "l", "ś", "s", "š", "s", "ż", "z", "ź", "z", "ž", "z",
+)
+
+// Gets the lower-case form of the text with the accented letters replaced, for comparison
+func fold(text string) string {
+	return foldReplacer.Replace(strings.ToLower(text))
+}
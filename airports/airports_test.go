@@ -0,0 +1,252 @@
+package airports
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		code          string
+		expectedFound bool
+		expectedIata  string
+	}{
+		{"IATA code", "OSL", true, "OSL"},
+		{"ICAO code", "ENGM", true, "OSL"},
+		{"lower case", "trf", true, "TRF"},
+		{"surrounding spaces", " cph ", true, "CPH"},
+		{"unknown code", "X1Z", false, ""},
+		{"empty code", "", false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Act
+			airport, found := Lookup(test.code)
+
+			// Assert
+			if found != test.expectedFound {
+				t.Fatalf("Lookup(%q) found %v; Expected %v", test.code, found, test.expectedFound)
+			}
+			if airport.Iata != test.expectedIata {
+				t.Errorf("Lookup(%q) gave %s; Expected %s", test.code, airport.Iata, test.expectedIata)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	if err := Validate("BGO"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := Validate("ENBR"); err == nil {
+		t.Error("Expected an error for an ICAO code")
+	}
+	if err := Validate("X1Z"); err == nil {
+		t.Error("Expected an error for an unknown code")
+	}
+}
+
+func TestSearch(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"code, then prefix, then substring", "ber", "BER,BGO,BGY,KSU,ABZ,LIS,EWR"},
+		{"city without accents", "tromso", "TOS"},
+		{"city with accents", "Tromsø", "TOS"},
+		{"several airports in a city", "stockholm", "ARN,BMA,NYO"},
+		{"part of a name", "gardermoen", "OSL"},
+		{"ICAO code", "EKCH", "CPH"},
+		{"no match", "atlantis", ""},
+		{"empty query", " ", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Act
+			matches := Search(test.query)
+
+			// Assert
+			codes := make([]string, 0, len(matches))
+			for _, airport := range matches {
+				codes = append(codes, airport.Iata)
+			}
+			if actual := strings.Join(codes, ","); actual != test.expected {
+				t.Errorf("Search(%q) gave %s; Expected %s", test.query, actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestEmbeddedData(t *testing.T) {
+
+	seen := make(map[string]bool)
+	for _, airport := range GetAll() {
+		if len(airport.Iata) != 3 || len(airport.Icao) != 4 || len(airport.Country) != 2 {
+			t.Errorf("%s has an invalid code: %+v", airport.Iata, airport)
+		}
+		if seen[airport.Iata] || seen[airport.Icao] {
+			t.Errorf("%s is listed more than once", airport.Iata)
+		}
+		seen[airport.Iata], seen[airport.Icao] = true, true
+
+		if airport.Latitude < -90 || airport.Latitude > 90 || airport.Longitude < -180 || airport.Longitude > 180 {
+			t.Errorf("%s has invalid coordinates %.4f, %.4f", airport.Iata, airport.Latitude, airport.Longitude)
+		}
+		if airport.GetLocation().String() != airport.TimeZone {
+			t.Errorf("%s has an unknown time zone %q", airport.Iata, airport.TimeZone)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedCode     string
+		expectedAirports string
+		expectedErr      bool
+	}{
+		{"airport code", "TRF", "TRF", "TRF", false},
+		{"airport and metro code", "OSL", "OSL", "OSL", false},
+		{"ICAO code", "ESSA", "ARN", "ARN", false},
+		{"metro code", "lon", "LON", "LHR,LGW,STN,LTN,LCY", false},
+		{"metro city name", "Oslo", "OSL", "OSL,TRF,RYG", false},
+		{"metro name in several words", "new york", "NYC", "JFK,EWR,LGA", false},
+		{"city with one airport", "Tromsø", "TOS", "TOS", false},
+		{"city name without accents", "malaga", "AGP", "AGP", false},
+		{"unknown airport code", "XQZ", "XQZ", "XQZ", false},
+		{"unknown city", "Atlantis", "", "", true},
+		{"empty", "", "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Act
+			place, err := Resolve(test.query)
+
+			// Assert
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("Resolve(%q) returned error %v; Expected an error: %v", test.query, err, test.expectedErr)
+			}
+			if place.Code != test.expectedCode {
+				t.Errorf("Resolve(%q) gave code %s; Expected %s", test.query, place.Code, test.expectedCode)
+			}
+			if actual := strings.Join(place.Airports, ","); actual != test.expectedAirports {
+				t.Errorf("Resolve(%q) gave airports %s; Expected %s", test.query, actual, test.expectedAirports)
+			}
+		})
+	}
+}
+
+func TestPlaceString(t *testing.T) {
+
+	if s := (Place{Code: "OSL", Name: "Oslo", Airports: []string{"OSL"}}).String(); s != "OSL" {
+		t.Errorf("Got %q for a single airport; Expected OSL", s)
+	}
+	if s := (Place{Code: "OSL", Name: "Oslo", Airports: []string{"OSL", "TRF", "RYG"}}).String(); s != "Oslo (OSL, TRF, RYG)" {
+		t.Errorf("Got %q for a metro area; Expected Oslo (OSL, TRF, RYG)", s)
+	}
+}
+
+func TestEmbeddedMetros(t *testing.T) {
+
+	for _, metro := range GetMetros() {
+		if len(metro.Airports) < 2 {
+			t.Errorf("%s has fewer than 2 airports", metro.Code)
+		}
+		for _, code := range metro.Airports {
+			if err := Validate(code); err != nil {
+				t.Errorf("%s: %v", metro.Code, err)
+			}
+		}
+	}
+}
+
+func TestGetDistanceTo(t *testing.T) {
+
+	tests := []struct {
+		from     string
+		to       string
+		expected float64
+	}{
+		{"OSL", "OSL", 0},
+		{"OSL", "TRF", 122},
+		{"OSL", "CPH", 517},
+		{"OSL", "JFK", 5910},
+	}
+
+	for _, test := range tests {
+		t.Run(test.from+"-"+test.to, func(t *testing.T) {
+
+			// Arrange
+			from, _ := Lookup(test.from)
+			to, _ := Lookup(test.to)
+
+			// Act
+			actual := from.GetDistanceTo(to)
+
+			// Assert
+			if math.Abs(actual-test.expected) > 0.01*test.expected+1 {
+				t.Errorf("Distance %s-%s is %.0f km; Expected about %.0f km", test.from, test.to, actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestFindNearby(t *testing.T) {
+
+	// Arrange
+	latitude, longitude, err := ParseLocation("59.91,10.75")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Act
+	nearby := FindNearby(latitude, longitude, 120)
+
+	// Assert
+	codes := make([]string, 0, len(nearby))
+	for _, airport := range nearby {
+		codes = append(codes, airport.Iata)
+	}
+	if actual := strings.Join(codes, ","); actual != "OSL,RYG,TRF" {
+		t.Errorf("Found %s within 120 km of Oslo; Expected OSL,RYG,TRF", actual)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+
+	tests := []struct {
+		text        string
+		expectedErr bool
+	}{
+		{"59.91,10.75", false},
+		{" 59.91 , 10.75 ", false},
+		{"TRF", false},
+		{"Bergen", false},
+		{"91,10", true},
+		{"north,east", true},
+		{"XQZ", true},
+		{"Atlantis", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if _, _, err := ParseLocation(test.text); (err != nil) != test.expectedErr {
+				t.Errorf("ParseLocation(%q) returned error %v; Expected an error: %v", test.text, err, test.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package airports
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The mean radius of the Earth, which is accurate enough for comparing flights
+const earthRadiusKm = 6371.0
+
+// Gets the great-circle distance in km between two points, given in degrees
+func GetDistance(latitude1 float64, longitude1 float64, latitude2 float64, longitude2 float64) float64 {
+
+	// Haversine formula
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLatitude := toRadians(latitude2 - latitude1)
+	dLongitude := toRadians(longitude2 - longitude1)
+	a := math.Pow(math.Sin(dLatitude/2), 2) + math.Cos(toRadians(latitude1))*math.Cos(toRadians(latitude2))*math.Pow(math.Sin(dLongitude/2), 2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Gets the great-circle distance in km to another airport
+func (airport Airport) GetDistanceTo(other Airport) float64 {
+	return GetDistance(airport.Latitude, airport.Longitude, other.Latitude, other.Longitude)
+}
+
+// Gets the airports within the given distance (in km) of a point, nearest first
+func FindNearby(latitude float64, longitude float64, radiusKm float64) []Airport {
+
+	type nearby struct {
+		airport  Airport
+		distance float64
+	}
+	found := make([]nearby, 0)
+	for _, airport := range airports {
+		if distance := GetDistance(latitude, longitude, airport.Latitude, airport.Longitude); distance <= radiusKm {
+			found = append(found, nearby{airport, distance})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+
+	results := make([]Airport, 0, len(found))
+	for _, n := range found {
+		results = append(results, n.airport)
+	}
+	return results
+}
+
+// Parses a location given as "latitude,longitude" (e.g. "59.91,10.75"), or as an airport, metro area or
+// city, which stands for the location of its first airport
+func ParseLocation(text string) (latitude float64, longitude float64, err error) {
+
+	if first, second, found := strings.Cut(text, ","); found {
+		latitude, err = strconv.ParseFloat(strings.TrimSpace(first), 64)
+		if err == nil {
+			longitude, err = strconv.ParseFloat(strings.TrimSpace(second), 64)
+		}
+		if err != nil || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+			return 0, 0, fmt.Errorf("%q is not a valid latitude and longitude", text)
+		}
+		return latitude, longitude, nil
+	}
+
+	place, err := Resolve(text)
+	if err != nil {
+		return 0, 0, err
+	}
+	airport, found := Lookup(place.Airports[0])
+	if !found {
+		return 0, 0, fmt.Errorf("the location of %s isn't known (not in the airport database)", place.Airports[0])
+	}
+	return airport.Latitude, airport.Longitude, nil
+}
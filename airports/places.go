@@ -0,0 +1,136 @@
+package airports
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Metropolitan areas (i.e. the airports serving the same city, such as LHR, LGW and STN for London) are
+// bundled with the binary too. They use the IATA metropolitan area codes, and only list the airports which
+// are in the airport data.
+//
+//go:embed metros.json
+var metroData []byte
+
+type metroFile struct {
+	Metros []Place `json:"metros"`
+}
+
+// An airport, or a group of airports serving the same city, to search from or to
+type Place struct {
+	Code     string   `json:"code"` // IATA code of the airport or metropolitan area
+	Name     string   `json:"name"`
+	Airports []string `json:"airports"`
+}
+
+var metros = loadMetros()
+
+func loadMetros() []Place {
+
+	var data metroFile
+	if err := json.Unmarshal(metroData, &data); err != nil {
+		// The file is embedded at build time, so this can only happen if it was edited incorrectly
+		panic(fmt.Sprintf("parsing embedded metro area data: %v", err))
+	}
+
+	return data.Metros
+}
+
+// Gets the metropolitan areas, in the order of the data file
+func GetMetros() []Place {
+
+	places := make([]Place, 0, len(metros))
+	for _, metro := range metros {
+		places = append(places, metro.copy())
+	}
+	return places
+}
+
+// Resolves what the user typed to the airports to search:
+//
+//  1. An airport code (e.g. TRF or ENTO) gives that airport alone. A code which is also a metropolitan area
+//     code, such as OSL, means the airport, so that a search from OSL keeps its meaning (and its quota use).
+//  2. A metropolitan area code (e.g. LON) gives all the airports in the area.
+//  3. A city name (e.g. "Oslo" or "london") gives its metropolitan area, or else all the airports in the city.
+//
+// Any other 3-letter code is taken to be an airport which isn't in the database.
+func Resolve(query string) (place Place, err error) {
+
+	query = strings.TrimSpace(query)
+	code := strings.ToUpper(query)
+
+	if airport, found := Lookup(code); found {
+		return Place{Code: airport.Iata, Name: airport.City, Airports: []string{airport.Iata}}, nil
+	}
+
+	name := fold(query)
+	for _, metro := range metros {
+		if metro.Code == code || fold(metro.Name) == name {
+			return metro.copy(), nil
+		}
+	}
+
+	for _, airport := range airports {
+		if fold(airport.City) == name {
+			if len(place.Airports) == 0 {
+				place = Place{Code: airport.Iata, Name: airport.City}
+			}
+			place.Airports = append(place.Airports, airport.Iata)
+		}
+	}
+	if len(place.Airports) > 0 {
+		return place, nil
+	}
+
+	if isCode(code) {
+		return Place{Code: code, Name: code, Airports: []string{code}}, nil
+	}
+	return place, fmt.Errorf("unknown airport, metro area or city %q", query)
+}
+
+// Checks whether the place has more than one airport
+func (place Place) IsGroup() bool {
+	return len(place.Airports) > 1
+}
+
+// Checks whether the given airport is one of the place's airports
+func (place Place) Contains(airport string) bool {
+
+	for _, a := range place.Airports {
+		if a == airport {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the airport code for a single airport, or the name and airports of a group, e.g. "Oslo (OSL, TRF, RYG)"
+func (place Place) String() string {
+
+	if !place.IsGroup() {
+		return place.Code
+	}
+	return fmt.Sprintf("%s (%s)", place.Name, strings.Join(place.Airports, ", "))
+}
+
+func (place Place) copy() Place {
+
+	place.Airports = append([]string(nil), place.Airports...)
+	return place
+}
+
+// Checks for a 3-letter upper-case code
+func isCode(text string) bool {
+
+	if len(text) != 3 {
+		return false
+	}
+	for _, c := range text {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,9 @@
+package amadeus
+
+// Relevant partial models for the Amadeus REST API responses shared by all endpoints
+
+// Response model for requests to get API Bearer token
+type amadeusToken struct {
+	AccessToken string `json:"access_token"`
+	Expiry      int    `json:"expires_in"`
+}
@@ -0,0 +1,299 @@
+package amadeus
+
+import (
+	"bytes"
+	"encoding/json"
+	"flynow/apierror"
+	"flynow/credentials"
+	"flynow/transport"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client for the Amadeus self-service APIs, which takes care of the Bearer token, and the retry
+// and rate-limit handling that all of the Amadeus endpoints need.
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+
+	// Provides the client ID and secret when a token is needed
+	credentials credentials.Provider
+
+	// The token is shared by all requests, and refreshed shortly before it expires
+	tokenMutex  sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	// Limits the number of requests per second, to stay within the Amadeus rate limit. Each request
+	// reserves the next free slot, so no ticker (or goroutine) is needed, and nothing is left running.
+	limiterMutex sync.Mutex
+	nextRequest  time.Time
+
+	// Difference between the server's clock and the local clock, as seen in the last token response
+	clockSkew      time.Duration
+	clockSkewKnown bool
+}
+
+const (
+	requestsPerSecond = 10
+	maxRetries        = 6
+)
+
+// Creates an Amadeus client for the given base URL (e.g. the test or production environment, or a fake
+// server), which authenticates with the client ID and secret from the given provider. The token and rate
+// limit are shared by all requests made through the same client.
+func NewClient(baseUrl string, secrets credentials.Provider) *Client {
+
+	client := Client{
+		baseUrl:     strings.TrimSuffix(baseUrl, "/"),
+		credentials: secrets,
+		httpClient:  transport.GetClient(),
+	}
+	return &client
+}
+
+// Waits until the next request is allowed by the rate limit
+func (client *Client) waitForRateLimit() {
+
+	client.limiterMutex.Lock()
+	slot := time.Now()
+	if slot.Before(client.nextRequest) {
+		slot = client.nextRequest
+	}
+	client.nextRequest = slot.Add(time.Second / requestsPerSecond)
+	client.limiterMutex.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
+
+// Gets a Bearer token if the client doesn't already have a valid one. This doesn't need to be called
+// before making requests, but can be used to check the credentials up front.
+func (client *Client) Authenticate() error {
+
+	_, err := client.getToken()
+	return err
+}
+
+// Performs a GET request to the given API path, and parses the JSON response into the result
+func (client *Client) Get(path string, query url.Values, result any) error {
+
+	createRequest := func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodGet, client.baseUrl+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.URL.RawQuery = query.Encode()
+		return request, nil
+	}
+
+	return client.do(createRequest, result)
+}
+
+// Performs a POST request with the given JSON body to the given API path, and parses the JSON response
+// into the result. Amadeus uses POST for some read-only operations with large inputs, which are marked
+// with the X-HTTP-Method-Override header.
+func (client *Client) Post(path string, body any, methodOverride string, result any) error {
+
+	bodyData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("creating request body: %w", err)
+	}
+
+	createRequest := func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPost, client.baseUrl+path, bytes.NewReader(bodyData))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/vnd.amadeus+json")
+		if methodOverride != "" {
+			request.Header.Set("X-HTTP-Method-Override", methodOverride)
+		}
+		return request, nil
+	}
+
+	return client.do(createRequest, result)
+}
+
+// Sends the request, retrying with an exponential backoff if the rate limit has been exceeded.
+// A new request is created for each attempt, since the request body can only be read once.
+func (client *Client) do(createRequest func() (*http.Request, error), result any) error {
+
+	token, err := client.getToken()
+	if err != nil {
+		return err
+	}
+
+	var response *http.Response
+	backoffSeconds := int32(1)
+	for attempt := 0; ; attempt++ {
+
+		request, err := createRequest()
+		if err != nil {
+			return fmt.Errorf("creating HTTP request: %w", err)
+		}
+		request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		// Call the API
+		client.waitForRateLimit()
+		start := time.Now()
+		response, err = client.httpClient.Do(request)
+		if err != nil {
+			return fmt.Errorf("calling Amadeus API: %w", err)
+		}
+		slog.Debug("Amadeus request", "path", request.URL.Path, "status", response.StatusCode, "attempt", attempt+1, "latency", time.Since(start))
+
+		if response.StatusCode != http.StatusTooManyRequests || attempt == maxRetries {
+			break
+		}
+
+		// Finish with the rejected response before waiting, so that its connection can be reused
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+
+		// Perform an exponential backoff & retry on 429
+		backoff := ((backoffSeconds * 1000) + rand.Int31n(500))
+		duration := time.Duration(backoff) * time.Millisecond
+		slog.Info("Amadeus rate limit exceeded, retrying", "path", request.URL.Path, "backoff", duration)
+		time.Sleep(duration)
+		backoffSeconds = backoffSeconds * 2
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		err := getResponseError(response)
+		if response.StatusCode == http.StatusTooManyRequests {
+			return fmt.Errorf("rate limit still exceeded after %d retries: %w", maxRetries, err)
+		}
+		return err
+	}
+
+	// Read and parse the response
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+
+	if err := json.Unmarshal(responseBody, result); err != nil {
+		return fmt.Errorf("parsing response body: %w", err)
+	}
+
+	return nil
+}
+
+// Gets a Bearer token for use in all Amadeus API requests. The token is cached until shortly before it
+// expires, so that it can be reused by any number of searches.
+func (client *Client) getToken() (string, error) {
+
+	client.tokenMutex.Lock()
+	defer client.tokenMutex.Unlock()
+
+	if client.token != "" && time.Now().Before(client.tokenExpiry) {
+		return client.token, nil
+	}
+
+	token, err := client.requestToken()
+	if err != nil {
+		return "", err
+	}
+
+	// Allow a small margin, so that the token doesn't expire in the middle of a request
+	client.token = token.AccessToken
+	client.tokenExpiry = time.Now().Add(time.Duration(token.Expiry)*time.Second - time.Minute)
+
+	return client.token, nil
+}
+
+// Performs a REST call to the Amadeus token endpoint to get a Bearer token. Note that a valid client ID
+// and client secret must be configured.
+func (client *Client) requestToken() (token amadeusToken, err error) {
+
+	const tokenPath = "/v1/security/oauth2/token"
+
+	clientId, err := credentials.Get(client.credentials, credentials.AmadeusClientId)
+	if err != nil {
+		return token, err
+	}
+	clientSecret, err := credentials.Get(client.credentials, credentials.AmadeusClientSecret)
+	if err != nil {
+		return token, err
+	}
+
+	// Construct the POST request body as URL-encoded form data
+	bodyData := url.Values{}
+	bodyData.Set("grant_type", "client_credentials")
+	bodyData.Set("client_id", clientId.Reveal())
+	bodyData.Set("client_secret", clientSecret.Reveal())
+	body := bodyData.Encode()
+
+	request, err := http.NewRequest(http.MethodPost, client.baseUrl+tokenPath, strings.NewReader(body))
+	if err != nil {
+		return token, fmt.Errorf("creating HTTP request: %w", err)
+	}
+
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	// Call the token API
+	sent := time.Now()
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return token, fmt.Errorf("requesting Amadeus token: %w", err)
+	}
+	defer response.Body.Close()
+
+	client.recordClockSkew(response, sent, time.Now())
+	slog.Debug("Amadeus token request", "status", response.StatusCode, "latency", time.Since(sent))
+
+	if response.StatusCode != http.StatusOK {
+		return token, fmt.Errorf("getting token: %w", getResponseError(response))
+	}
+
+	// Read and parse the response data
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return token, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if err := json.Unmarshal(responseBody, &token); err != nil {
+		return token, fmt.Errorf("parsing token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Gets the difference between the Amadeus server's clock and the local clock (positive if the local clock is
+// behind), based on the Date header of the last token response. This is only accurate to about a second.
+func (client *Client) GetClockSkew() (skew time.Duration, known bool) {
+
+	client.tokenMutex.Lock()
+	defer client.tokenMutex.Unlock()
+
+	return client.clockSkew, client.clockSkewKnown
+}
+
+// Compares the Date header of the response with the local time halfway through the request. This is called
+// while the token mutex is held.
+func (client *Client) recordClockSkew(response *http.Response, sent time.Time, received time.Time) {
+
+	serverTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+
+	localTime := sent.Add(received.Sub(sent) / 2)
+	client.clockSkew = serverTime.Sub(localTime).Round(time.Second)
+	client.clockSkewKnown = true
+}
+
+// Parses the body of an error response, which is used for the error details if it is in the Amadeus format
+func getResponseError(response *http.Response) *apierror.Error {
+
+	body, _ := io.ReadAll(response.Body)
+	return apierror.ParseAmadeus(response.StatusCode, response.Request.URL.Path, body)
+}
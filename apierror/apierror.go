@@ -0,0 +1,214 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Errors returned by the AviationStack and Amadeus APIs, parsed from their error responses. Each error is
+// classified with one of the sentinel errors below where possible, so that callers can check for them with
+// errors.Is rather than by status code.
+
+// Names of the APIs, as used in error messages
+const (
+	AviationStack = "AviationStack"
+	Amadeus       = "Amadeus"
+)
+
+var (
+	// The API key, client ID or secret, or token was rejected
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// The monthly request quota has been used up
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// Too many requests were made in a short time, so the request can be retried later
+	ErrRateLimited = errors.New("rate limited")
+
+	// An airport code in the request is invalid or unknown
+	ErrInvalidAirport = errors.New("invalid airport")
+)
+
+// An error response from one of the APIs
+type Error struct {
+	Api        string
+	StatusCode int
+	Path       string
+
+	// The API's own error code (e.g. invalid_access_key or 38190), and its description
+	Code   string
+	Title  string
+	Detail string
+
+	// The request parameter which the error is about, if given (e.g. destinationLocationCode)
+	Parameter string
+
+	// The sentinel error which the error is classified as, if any
+	Kind error
+}
+
+func (err *Error) Error() string {
+
+	message := fmt.Sprintf("unexpected response code (%d) from %s %s", err.StatusCode, err.Api, err.Path)
+
+	details := make([]string, 0, 3)
+	for _, detail := range []string{err.Code, err.Title, err.Detail} {
+		if detail != "" && !slices.Contains(details, detail) {
+			details = append(details, detail)
+		}
+	}
+	if len(details) > 0 {
+		message += ": " + strings.Join(details, " ")
+	}
+	if err.Parameter != "" {
+		message += fmt.Sprintf(" (%s)", err.Parameter)
+	}
+
+	return message
+}
+
+// Allows the error to be matched with errors.Is against the sentinel error it is classified as
+func (err *Error) Unwrap() error {
+	return err.Kind
+}
+
+// AviationStack error codes, as listed in the API documentation
+var aviationStackKinds = map[string]error{
+	"invalid_access_key":  ErrUnauthorized,
+	"missing_access_key":  ErrUnauthorized,
+	"inactive_user":       ErrUnauthorized,
+	"usage_limit_reached": ErrQuotaExceeded,
+	"rate_limit_reached":  ErrRateLimited,
+}
+
+// Request parameters which hold airport codes
+var airportParameters = []string{
+	"dep_iata", "arr_iata", // AviationStack
+	"originLocationCode", "destinationLocationCode", "departureAirportCode", // Amadeus
+}
+
+// Parses an AviationStack error response, such as:
+//
+//	{"error": {"code": "usage_limit_reached", "message": "Your monthly usage limit has been reached."}}
+//
+// Validation errors also have a context, which is keyed by the invalid parameters. If the body can't be
+// parsed, the error is only classified by its status code.
+func ParseAviationStack(statusCode int, path string, body []byte) *Error {
+
+	var response struct {
+		Error struct {
+			Code    string                     `json:"code"`
+			Message string                     `json:"message"`
+			Context map[string]json.RawMessage `json:"context"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &response)
+
+	err := Error{
+		Api:        AviationStack,
+		StatusCode: statusCode,
+		Path:       path,
+		Code:       response.Error.Code,
+		Detail:     response.Error.Message,
+	}
+
+	// Use the parameters in a consistent order, if there are several
+	parameters := make([]string, 0, len(response.Error.Context))
+	for parameter := range response.Error.Context {
+		parameters = append(parameters, parameter)
+	}
+	sort.Strings(parameters)
+	err.Parameter = strings.Join(parameters, ",")
+
+	err.Kind = aviationStackKinds[err.Code]
+	if err.Kind == nil {
+		err.Kind = classify(statusCode, parameters)
+	}
+
+	return &err
+}
+
+// Parses an Amadeus error response. Most endpoints return a list of errors, of which the first is used:
+//
+//	{"errors": [{"status": 400, "code": 477, "title": "INVALID FORMAT", "detail": "...", "source": {"parameter": "destinationLocationCode"}}]}
+//
+// The token endpoint uses the OAuth format instead:
+//
+//	{"error": "invalid_client", "error_description": "Client credentials are invalid", "code": 38187, "title": "Invalid parameters"}
+//
+// If the body can't be parsed, the error is only classified by its status code.
+func ParseAmadeus(statusCode int, path string, body []byte) *Error {
+
+	type errorSource struct {
+		Parameter string `json:"parameter"`
+	}
+	type errorDetails struct {
+		Code   json.Number `json:"code"`
+		Title  string      `json:"title"`
+		Detail string      `json:"detail"`
+		Source errorSource `json:"source"`
+	}
+	var response struct {
+		Errors []errorDetails `json:"errors"`
+
+		// The OAuth format
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+		Title       string `json:"title"`
+	}
+	_ = json.Unmarshal(body, &response)
+
+	err := Error{Api: Amadeus, StatusCode: statusCode, Path: path}
+	switch {
+	case len(response.Errors) > 0:
+		first := response.Errors[0]
+		err.Code, err.Title, err.Detail, err.Parameter = first.Code.String(), first.Title, first.Detail, first.Source.Parameter
+	case response.Error != "":
+		err.Code, err.Title, err.Detail = response.Error, response.Title, response.Description
+	}
+
+	// A rejected client ID or secret is reported as a bad request by the token endpoint
+	if response.Error == "invalid_client" {
+		err.Kind = ErrUnauthorized
+	} else {
+		err.Kind = classify(statusCode, []string{err.Parameter})
+	}
+
+	return &err
+}
+
+// Classifies an error by its status code and the parameters it is about
+func classify(statusCode int, parameters []string) error {
+
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+
+	if statusCode >= 400 && statusCode < 500 {
+		for _, parameter := range parameters {
+			if slices.Contains(airportParameters, parameter) {
+				return ErrInvalidAirport
+			}
+		}
+	}
+
+	return nil
+}
+
+// Gets the status code of an API error, or 0 if the error didn't come from an API response
+func GetStatusCode(err error) int {
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,177 @@
+package apierror
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAviationStack(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		statusCode    int
+		body          string
+		expectedKind  error
+		expectedCode  string
+		expectedParam string
+	}{
+		{
+			name:         "invalid access key",
+			statusCode:   401,
+			body:         `{"error":{"code":"invalid_access_key","message":"You have not supplied a valid API Access Key."}}`,
+			expectedKind: ErrUnauthorized,
+			expectedCode: "invalid_access_key",
+		},
+		{
+			name:         "usage limit reached",
+			statusCode:   429,
+			body:         `{"error":{"code":"usage_limit_reached","message":"Your monthly usage limit has been reached."}}`,
+			expectedKind: ErrQuotaExceeded,
+			expectedCode: "usage_limit_reached",
+		},
+		{
+			name:         "rate limit reached",
+			statusCode:   429,
+			body:         `{"error":{"code":"rate_limit_reached","message":"Too many requests."}}`,
+			expectedKind: ErrRateLimited,
+			expectedCode: "rate_limit_reached",
+		},
+		{
+			name:          "invalid airport",
+			statusCode:    422,
+			body:          `{"error":{"code":"validation_error","message":"Request failed with validation error","context":{"dep_iata":[{"key":"invalid_iata","message":"Invalid IATA code"}]}}}`,
+			expectedKind:  ErrInvalidAirport,
+			expectedCode:  "validation_error",
+			expectedParam: "dep_iata",
+		},
+		{
+			name:         "server error",
+			statusCode:   500,
+			body:         `{"error":{"code":"internal_error","message":"An internal error occurred."}}`,
+			expectedCode: "internal_error",
+		},
+		{
+			name:         "unparsable body",
+			statusCode:   401,
+			body:         `<html>Unauthorized</html>`,
+			expectedKind: ErrUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Act
+			err := ParseAviationStack(tc.statusCode, "/v1/flights", []byte(tc.body))
+
+			// Assert
+			assertKind(t, err, tc.expectedKind)
+			if err.Code != tc.expectedCode || err.Parameter != tc.expectedParam {
+				t.Errorf("Got code %q and parameter %q; Expected %q and %q", err.Code, err.Parameter, tc.expectedCode, tc.expectedParam)
+			}
+		})
+	}
+}
+
+func TestParseAmadeus(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		statusCode    int
+		body          string
+		expectedKind  error
+		expectedCode  string
+		expectedParam string
+	}{
+		{
+			name:         "invalid access token",
+			statusCode:   401,
+			body:         `{"errors":[{"code":38190,"title":"Invalid access token","detail":"The access token provided in the Authorization header is invalid","status":401}]}`,
+			expectedKind: ErrUnauthorized,
+			expectedCode: "38190",
+		},
+		{
+			name:         "invalid client",
+			statusCode:   401,
+			body:         `{"error":"invalid_client","error_description":"Client credentials are invalid","code":38187,"title":"Invalid parameters"}`,
+			expectedKind: ErrUnauthorized,
+			expectedCode: "invalid_client",
+		},
+		{
+			name:         "too many requests",
+			statusCode:   429,
+			body:         `{"errors":[{"code":38194,"title":"Too many requests","detail":"The network rate limit is exceeded, please try again later","status":429}]}`,
+			expectedKind: ErrRateLimited,
+			expectedCode: "38194",
+		},
+		{
+			name:          "invalid destination",
+			statusCode:    400,
+			body:          `{"errors":[{"status":400,"code":477,"title":"INVALID FORMAT","detail":"Invalid airport code","source":{"parameter":"destinationLocationCode"}}]}`,
+			expectedKind:  ErrInvalidAirport,
+			expectedCode:  "477",
+			expectedParam: "destinationLocationCode",
+		},
+		{
+			name:          "invalid date",
+			statusCode:    400,
+			body:          `{"errors":[{"status":400,"code":425,"title":"INVALID DATE","detail":"Date/Time is in the past","source":{"parameter":"departureDate"}}]}`,
+			expectedCode:  "425",
+			expectedParam: "departureDate",
+		},
+		{
+			name:       "empty body",
+			statusCode: 503,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Act
+			err := ParseAmadeus(tc.statusCode, "/v2/shopping/flight-offers", []byte(tc.body))
+
+			// Assert
+			assertKind(t, err, tc.expectedKind)
+			if err.Code != tc.expectedCode || err.Parameter != tc.expectedParam {
+				t.Errorf("Got code %q and parameter %q; Expected %q and %q", err.Code, err.Parameter, tc.expectedCode, tc.expectedParam)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+
+	// Arrange
+	err := ParseAmadeus(400, "/v2/shopping/flight-offers", []byte(`{"errors":[{"code":477,"title":"INVALID FORMAT","detail":"Invalid airport code","source":{"parameter":"destinationLocationCode"}}]}`))
+
+	// Act
+	message := err.Error()
+
+	// Assert
+	expected := "unexpected response code (400) from Amadeus /v2/shopping/flight-offers: 477 INVALID FORMAT Invalid airport code (destinationLocationCode)"
+	if message != expected {
+		t.Errorf("Got message %q; Expected %q", message, expected)
+	}
+	if GetStatusCode(errors.Join(errors.New("searching"), err)) != 400 {
+		t.Error("Expected the status code to be found in a wrapped error")
+	}
+}
+
+func assertKind(t *testing.T, err *Error, expected error) {
+
+	t.Helper()
+
+	if expected == nil {
+		for _, kind := range []error{ErrUnauthorized, ErrQuotaExceeded, ErrRateLimited, ErrInvalidAirport} {
+			if errors.Is(err, kind) {
+				t.Errorf("Got error %q classified as %v; Expected it not to be classified", err, kind)
+			}
+		}
+		return
+	}
+
+	if !errors.Is(err, expected) {
+		t.Errorf("Got error %q; Expected it to be %v", err, expected)
+	}
+}
@@ -0,0 +1,87 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// A cassette is a directory of recorded HTTP interactions, one JSON file per request, which can be replayed
+// to run the application without any network access or API quota. Secrets (API keys, client credentials and
+// Bearer tokens) are never written to the cassette.
+
+// A single recorded request and its response
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Query parameters which change from run to run, and so are ignored when matching requests (e.g. the
+// Amadeus searches are always for flights departing today)
+var volatileParameters = []string{"departureDate"}
+
+// Gets the key used to match a request against the recorded ones. This is based on the method, the URL
+// (without any volatile query parameters) and the body. JSON bodies are compared by their contents rather
+// than their formatting.
+func getMatchKey(method string, rawUrl string, body string) string {
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return method + " " + rawUrl + "\n" + body
+	}
+
+	query := u.Query()
+	for _, name := range volatileParameters {
+		query.Del(name)
+	}
+	u.RawQuery = query.Encode()
+
+	return method + " " + u.String() + "\n" + canonicalBody(body)
+}
+
+func canonicalBody(body string) string {
+
+	var contents any
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&contents); err != nil {
+		return body
+	}
+
+	// Note: Maps are always marshaled with sorted keys
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return body
+	}
+
+	return string(bytes.TrimSpace(data))
+}
+
+// Gets the names of the interaction files in the cassette directory, in the order they were recorded
+func sortInteractionFiles(names []string) []string {
+
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasSuffix(name, ".json") {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
@@ -0,0 +1,79 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			io.WriteString(w, `{"access_token":"secret-token","expires_in":1799}`)
+		default:
+			io.WriteString(w, `{"destination":"`+r.URL.Query().Get("dest")+`"}`)
+		}
+	}))
+	defer server.Close()
+
+	readBody := func(response *http.Response, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer response.Body.Close()
+		data, _ := io.ReadAll(response.Body)
+		return string(data)
+	}
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	recordingClient := &http.Client{Transport: recorder}
+
+	// Act
+	form := url.Values{"grant_type": {"client_credentials"}, "client_secret": {"hunter2"}}
+	readBody(recordingClient.Post(server.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode())))
+	readBody(recordingClient.Get(server.URL + "/search?dest=CPH&departureDate=2024-04-15&access_key=abc123"))
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	replayingClient := &http.Client{Transport: replayer}
+	replayed := readBody(replayingClient.Get(server.URL + "/search?access_key=other&departureDate=2024-05-01&dest=CPH"))
+	token := readBody(replayingClient.Post(server.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode())))
+	_, unmatchedErr := replayingClient.Get(server.URL + "/search?dest=LHR")
+
+	// Assert
+	if replayed != `{"destination":"CPH"}` {
+		t.Errorf("Replayed %s", replayed)
+	}
+	if strings.Contains(token, "secret-token") {
+		t.Errorf("Token was not redacted: %s", token)
+	}
+	if unmatchedErr == nil {
+		t.Error("Expected an error for a request which wasn't recorded")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		for _, secret := range []string{"hunter2", "abc123", "secret-token"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("Secret %s was recorded in %s", secret, filepath.Base(file))
+			}
+		}
+	}
+}
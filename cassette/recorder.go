@@ -0,0 +1,128 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"flynow/transport"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// Transport which passes each request on to another transport, and saves the request and response in the
+// cassette directory
+type Recorder struct {
+	dir   string
+	next  http.RoundTripper
+	mutex sync.Mutex
+	count int
+}
+
+// Creates a recorder which saves the interactions in the given directory, creating it if needed
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cassette directory: %w", err)
+	}
+
+	// Continue the numbering of any existing interactions, so that they aren't overwritten
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	recorder := Recorder{dir: dir, next: next, count: len(sortInteractionFiles(names))}
+	return &recorder, nil
+}
+
+func (recorder *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	// Keep a copy of the request body, since sending the request consumes it
+	var requestBody []byte
+	if request.Body != nil {
+		data, err := io.ReadAll(request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		request.Body.Close()
+		requestBody = data
+		request.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	response, err := recorder.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Likewise, keep a copy of the response body, and give the caller a fresh reader for it
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	recorded := interaction{
+		Request: recordedRequest{
+			Method: request.Method,
+			Url:    transport.RedactUrl(request.URL),
+			Body:   transport.RedactRequestBody(requestBody, request.Header.Get("Content-Type")),
+		},
+		Response: recordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     getRecordedHeaders(response.Header),
+			Body:       transport.RedactResponseBody(responseBody),
+		},
+	}
+
+	if err := recorder.save(recorded); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func (recorder *Recorder) save(recorded interaction) error {
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing interaction: %w", err)
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.count++
+	request, _ := http.NewRequest(recorded.Request.Method, recorded.Request.Url, nil)
+	name := fmt.Sprintf("%04d-%s-%s.json", recorder.count, recorded.Request.Method, unsafeFileNameChars.ReplaceAllString(request.URL.Host+request.URL.Path, "-"))
+
+	if err := os.WriteFile(filepath.Join(recorder.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("writing interaction: %w", err)
+	}
+
+	return nil
+}
+
+// Only the headers which the clients actually use are kept, e.g. to avoid recording cookies
+func getRecordedHeaders(header http.Header) http.Header {
+
+	recorded := http.Header{}
+	for _, name := range []string{"Content-Type", "Date"} {
+		if value := header.Get(name); value != "" {
+			recorded.Set(name, value)
+		}
+	}
+
+	return recorded
+}
@@ -0,0 +1,100 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"flynow/transport"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Transport which serves every request from the interactions in a cassette directory, without any network
+// access. If the same request was recorded more than once, the responses are served in the recorded order,
+// and the last one is repeated after that.
+type Replayer struct {
+	mutex        sync.Mutex
+	interactions map[string][]interaction
+}
+
+// Creates a replayer for the cassette in the given directory
+func NewReplayer(dir string) (*Replayer, error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	replayer := Replayer{interactions: make(map[string][]interaction)}
+	for _, name := range sortInteractionFiles(names) {
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading interaction: %w", err)
+		}
+
+		var recorded interaction
+		if err := json.Unmarshal(data, &recorded); err != nil {
+			return nil, fmt.Errorf("parsing interaction %s: %w", name, err)
+		}
+
+		key := getMatchKey(recorded.Request.Method, recorded.Request.Url, recorded.Request.Body)
+		replayer.interactions[key] = append(replayer.interactions[key], recorded)
+	}
+
+	if len(replayer.interactions) == 0 {
+		return nil, fmt.Errorf("no interactions found in cassette %s", dir)
+	}
+
+	return &replayer, nil
+}
+
+func (replayer *Replayer) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	var requestBody []byte
+	if request.Body != nil {
+		data, err := io.ReadAll(request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		request.Body.Close()
+		requestBody = data
+	}
+
+	url := transport.RedactUrl(request.URL)
+	key := getMatchKey(request.Method, url, transport.RedactRequestBody(requestBody, request.Header.Get("Content-Type")))
+
+	replayer.mutex.Lock()
+	recorded, found := replayer.interactions[key]
+	if found && len(recorded) > 1 {
+		replayer.interactions[key] = recorded[1:]
+	}
+	replayer.mutex.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("no recorded response for %s %s", request.Method, url)
+	}
+	response := recorded[0].Response
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(response.Body))),
+		ContentLength: int64(len(response.Body)),
+		Request:       request,
+	}, nil
+}
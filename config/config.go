@@ -0,0 +1,518 @@
+package config
+
+import (
+	"errors"
+	"flynow/airlines"
+	"flynow/airports"
+	"flynow/credentials"
+	"flynow/pipeline"
+	"flynow/pricing"
+	"flynow/where"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Typed application configuration, which is loaded in layers. Each layer overrides the settings given by
+// the previous ones:
+//
+//  1. The built-in defaults
+//  2. The user config file (flynow/config.json in the user's config directory, or the file given by
+//     the FLYNOW_CONFIG environment variable or the --config flag)
+//  3. FLYNOW_* environment variables (e.g. FLYNOW_ORIGIN)
+//  4. Command-line flags (e.g. --origin)
+//
+// The API secrets aren't part of the configuration, which only says where they are kept (see the
+// credentials package).
+
+// Sources of the list of destinations to search
+const (
+	AviationStackSource = "aviationstack" // Today's scheduled flights (limited monthly quota)
+	AmadeusRoutesSource = "amadeus"       // All known direct routes
+	CrossCheckSource    = "crosscheck"    // Today's scheduled flights which are also known direct routes
+	FixtureSource       = "fixture"       // Flights read from a file in the AviationStack format
+	TimetableSource     = "timetable"     // Flights read from a local SSIM or CSV timetable file
+	LearnedSource       = "learned"       // Destinations predicted from previous AviationStack responses
+)
+
+var scheduleSources = []string{AviationStackSource, AmadeusRoutesSource, CrossCheckSource, FixtureSource, TimetableSource, LearnedSource}
+
+// Providers of the API secrets
+const (
+	EnvCredentials     = "env"     // FLYNOW_* environment variables
+	FileCredentials    = "file"    // A JSON file which only its owner can read
+	CommandCredentials = "command" // An external command, such as a password manager CLI
+	VaultCredentials   = "vault"   // A local file encrypted with a passphrase
+)
+
+var credentialProviders = []string{EnvCredentials, FileCredentials, CommandCredentials, VaultCredentials}
+
+// Base URLs of the APIs
+const (
+	DefaultAviationStackUrl = "http://api.aviationstack.com"
+	DefaultAmadeusUrl       = "https://test.api.amadeus.com"
+)
+
+type Config struct {
+	// The airport, metro area or city to search from (e.g. OSL, LON or "Oslo"), and the currency for the prices
+	Origin   string `json:"origin"`
+	Currency string `json:"currency"`
+
+	// The airport, metro area or city to search for flights to. By default, every destination in the
+	// schedule is searched.
+	Destination string `json:"destination"`
+
+	Credentials Credentials `json:"credentials"`
+	Endpoints   Endpoints   `json:"endpoints"`
+	Schedule    Schedule    `json:"schedule"`
+	Nearby      Nearby      `json:"nearby"`
+	Airlines    Airlines    `json:"airlines"`
+
+	// The number of checked bags per traveler. The cost of any bags which the fare doesn't include is added
+	// to the price, so that fares with and without included bags can be compared fairly.
+	CheckedBags int `json:"checkedBags"`
+
+	Fares Fares `json:"fares"`
+
+	Ranking Ranking `json:"ranking"`
+
+	// An expression which the flights must match to be shown, e.g. price < 1500 NOK && dest.country != "NO"
+	// (see the where package)
+	Where string `json:"where"`
+
+	// The compiled Where expression, which is kept so that it's only compiled once
+	condition *where.Condition
+
+	Pipeline Pipeline `json:"pipeline"`
+
+	// The maximum number of Amadeus searches performed at the same time
+	Concurrency int `json:"concurrency"`
+
+	// Directory for the route database and quota usage. The default is flynow in the user's cache directory.
+	CacheDir string `json:"cacheDir"`
+
+	Quota Quota `json:"quota"`
+
+	// The config file which the settings were loaded from, if any
+	Path string `json:"-"`
+}
+
+// Where the API secrets are kept. The secrets themselves aren't part of the configuration.
+type Credentials struct {
+	// The providers to read the secrets from, in priority order
+	Providers []string `json:"providers"`
+
+	// The JSON file used by the file provider, which must only be readable by its owner. The default is
+	// flynow/credentials.json in the user's config directory.
+	File string `json:"file"`
+
+	// The command used by the command provider, such as "pass show flynow/{key}"
+	Command string `json:"command"`
+
+	// The encrypted file used by the vault provider. The default is flynow/vault.json in the user's
+	// config directory.
+	Vault string `json:"vault"`
+}
+
+// Base URLs of the APIs (e.g. to use the Amadeus production environment)
+type Endpoints struct {
+	AviationStackUrl string `json:"aviationStack"`
+	AmadeusUrl       string `json:"amadeus"`
+}
+
+// Settings for the list of destinations to search
+type Schedule struct {
+	// The sources, in priority order
+	Sources []string `json:"sources"`
+
+	// Whether the destinations from all the sources are merged, rather than only falling back to the next
+	// source when one fails
+	Merge bool `json:"merge"`
+
+	// The file used by the fixture source, in the AviationStack format
+	Fixture string `json:"fixture"`
+
+	// The SSIM or CSV file used by the timetable source
+	Timetable string `json:"timetable"`
+
+	// The database of routes learned from previous schedules. The default is routes.json in the cache directory.
+	RouteDatabase string `json:"routeDatabase"`
+
+	// The fraction (0 to 1) of previous days on which a route must have had a flight, for the learned
+	// source to predict it
+	RouteConfidence float64 `json:"routeConfidence"`
+}
+
+// Settings for searching from every airport near home, rather than only from the origin
+type Nearby struct {
+	// The distance from home to search within, in km, or 0 to only search from the origin
+	Radius float64 `json:"radius"`
+
+	// Where the journey starts, as "latitude,longitude" or as an airport, metro area or city. The default
+	// is the origin.
+	Home string `json:"home"`
+
+	// The time and cost of getting from home to each airport, by IATA code. The airports which aren't
+	// listed are taken to cost nothing to get to.
+	Transfers map[string]Transfer `json:"transfers"`
+}
+
+// The journey from home to an airport, e.g. by train or car
+type Transfer struct {
+	Minutes int `json:"minutes"`
+
+	// The cost in the search currency, including e.g. parking
+	Cost float64 `json:"cost"`
+}
+
+// Which airlines may be flown. Every airline is allowed unless some are included, either by code or by
+// alliance, and the excluded airlines are never allowed.
+type Airlines struct {
+	// IATA airline codes, e.g. SK or DY
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+
+	// Alliance names, e.g. "Star Alliance", "oneworld" or "SkyTeam"
+	Alliances []string `json:"alliances"`
+}
+
+// The fares to accept. Offers with other fares are skipped by the "fares" offer filter.
+type Fares struct {
+	// Fare brands never to buy, e.g. LIGHT or LOWFARE
+	ExcludeBrands []string `json:"excludeBrands"`
+
+	// The only cabins to fly in, e.g. ECONOMY or PREMIUM_ECONOMY, or any cabin if empty
+	Cabins []string `json:"cabins"`
+
+	// Amenities which must be included in the fare free of charge, e.g. checked-bag or seat
+	RequiredAmenities []string `json:"requiredAmenities"`
+}
+
+// How the results are ordered
+type Ranking struct {
+	// The keys to sort by, in priority order, each with a leading "-" for descending order (e.g. "-time").
+	// The default is the score if any weights are given, or else the door-to-door price when searching
+	// nearby airports, or the price.
+	Sort []string `json:"sort"`
+
+	// The weight of each measure (price, soonness, duration, arrival and stops) in the score
+	Weights pricing.Weights `json:"weights"`
+
+	// The measures to find the best trade-offs between (e.g. price, soonness and duration), each with a
+	// leading "-" if higher values are better. The trade-offs are only shown if this is set.
+	Pareto []string `json:"pareto"`
+}
+
+// The stages which decide which destinations and offers are used, and add details to the results, in order
+// and with their parameters (e.g. {"stage": "exclude", "params": {"countries": ["GB"]}}). An empty list
+// means the built-in defaults for that part of the search.
+type Pipeline struct {
+	// Decide which scheduled flights count towards the destinations, e.g. origin, airlines and exclude.
+	// These are checked when the search is set up, since the schedule package depends on this one.
+	Destinations []pipeline.Stage `json:"destinations"`
+
+	// Decide which offers can be chosen, e.g. direct, route, airlines, fares and currency
+	Offers []pipeline.Stage `json:"offers"`
+
+	// Add details to the chosen flights, e.g. emissions
+	Results []pipeline.Stage `json:"results"`
+}
+
+// Limits on the use of the APIs
+type Quota struct {
+	// The number of AviationStack requests allowed per calendar month (100 on the free plan), or 0 for no limit
+	AviationStackMonthly int `json:"aviationStackMonthly"`
+}
+
+// Gets the built-in default configuration
+func Default() Config {
+
+	return Config{
+		Origin:      "OSL",
+		Currency:    "NOK",
+		Credentials: Credentials{Providers: []string{EnvCredentials, FileCredentials, VaultCredentials}},
+		Endpoints:   Endpoints{AviationStackUrl: DefaultAviationStackUrl, AmadeusUrl: DefaultAmadeusUrl},
+		Schedule:    Schedule{Sources: []string{AviationStackSource}, RouteConfidence: 0.5},
+		Concurrency: 5,
+		Quota:       Quota{AviationStackMonthly: 100},
+	}
+}
+
+// Gets the airports to search from, e.g. OSL, TRF and RYG for an origin of "Oslo", or every airport within
+// the radius of home when searching nearby airports
+func (cfg *Config) GetOrigin() airports.Place {
+
+	// The settings have already been validated, so they always resolve
+	place, _ := airports.Resolve(cfg.Origin)
+	if cfg.Nearby.Radius == 0 {
+		return place
+	}
+
+	latitude, longitude, _ := airports.ParseLocation(cfg.getHome())
+	nearby := airports.FindNearby(latitude, longitude, cfg.Nearby.Radius)
+	if len(nearby) == 0 {
+		return place
+	}
+
+	place = airports.Place{Code: nearby[0].Iata, Name: fmt.Sprintf("Within %g km of %s", cfg.Nearby.Radius, cfg.getHome())}
+	for _, airport := range nearby {
+		place.Airports = append(place.Airports, airport.Iata)
+	}
+	return place
+}
+
+// Gets where the journey starts, for searching nearby airports
+func (cfg *Config) getHome() string {
+
+	if cfg.Nearby.Home != "" {
+		return cfg.Nearby.Home
+	}
+	return cfg.Origin
+}
+
+// Gets the journey from home to the given airport, which is free if none has been configured
+func (cfg *Config) GetTransfer(airport string) Transfer {
+	return cfg.Nearby.Transfers[airport]
+}
+
+// Gets the airports to search for flights to, or false if every destination in the schedule is searched
+func (cfg *Config) GetDestination() (place airports.Place, found bool) {
+
+	if cfg.Destination == "" {
+		return place, false
+	}
+	place, err := airports.Resolve(cfg.Destination)
+	return place, err == nil
+}
+
+// Gets the order of the results, from the sort keys and weights
+func (cfg *Config) GetRanking() pricing.Ranking {
+
+	// The settings have already been validated, so the keys always parse
+	keys, _ := pricing.ParseSortKeys(cfg.Ranking.Sort)
+	if len(keys) == 0 {
+		switch {
+		case len(cfg.Ranking.Weights) > 0:
+			keys = []pricing.SortKey{{Field: pricing.SortByScore}}
+		case cfg.Nearby.Radius > 0:
+			keys = []pricing.SortKey{{Field: pricing.SortByDoorToDoor}}
+		default:
+			keys = []pricing.SortKey{{Field: pricing.SortByPrice}}
+		}
+	}
+
+	return pricing.Ranking{Keys: keys, Weights: cfg.Ranking.Weights}
+}
+
+// Gets the measures to find the best trade-offs between, if any
+func (cfg *Config) GetParetoDimensions() []pricing.Dimension {
+
+	// The settings have already been validated, so the measures always parse
+	dimensions, _ := pricing.ParseDimensions(cfg.Ranking.Pareto)
+	return dimensions
+}
+
+// Gets the airline filter for the schedule and price searches
+func (cfg *Config) GetAirlineFilter() airlines.Filter {
+	return airlines.Filter{Include: cfg.Airlines.Include, Exclude: cfg.Airlines.Exclude, Alliances: cfg.Airlines.Alliances}
+}
+
+// Gets the condition which the flights must match to be shown, or nil if there isn't one. Any amount of
+// money in it must be in the currency of the prices.
+func (cfg *Config) GetCondition() (*where.Condition, error) {
+
+	if cfg.Where == "" {
+		return nil, nil
+	}
+	if cfg.condition != nil && cfg.condition.String() == cfg.Where {
+		return cfg.condition, nil
+	}
+
+	condition, err := where.Compile(cfg.Where)
+	if err != nil {
+		return nil, err
+	}
+	if err := condition.CheckCurrency(cfg.Currency); err != nil {
+		return nil, err
+	}
+	cfg.condition = condition
+	return condition, nil
+}
+
+// Gets the fares to accept
+func (cfg *Config) GetFareFilter() pricing.FareFilter {
+	return pricing.FareFilter{ExcludedBrands: cfg.Fares.ExcludeBrands, Cabins: cfg.Fares.Cabins, RequiredAmenities: cfg.Fares.RequiredAmenities}
+}
+
+// Gets the offer filters and enrichers to use for the price search
+func (cfg *Config) GetPricingStages() (offerFilters []pipeline.Named[pricing.OfferFilter], enrichers []pipeline.Named[pricing.Enricher]) {
+
+	// The settings have already been validated, so the stages can always be created
+	offerFilters, _ = pricing.BuildOfferFilters(cfg.Pipeline.Offers)
+	enrichers, _ = pricing.BuildEnrichers(cfg.Pipeline.Results)
+	return offerFilters, enrichers
+}
+
+// Gets the location of the file used to keep track of the API quota usage
+func (cfg *Config) GetQuotaPath() string {
+	return filepath.Join(cfg.CacheDir, "quota.json")
+}
+
+// Checks whether any of the schedule sources use the AviationStack API
+func (cfg *Config) UsesAviationStack() bool {
+
+	for _, source := range cfg.Schedule.Sources {
+		if source == AviationStackSource || source == CrossCheckSource {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the names of the secrets needed by the selected sources
+func (cfg *Config) GetRequiredCredentials() []string {
+
+	keys := []string{credentials.AmadeusClientId, credentials.AmadeusClientSecret}
+	if cfg.UsesAviationStack() {
+		keys = append(keys, credentials.AviationStackApiKey)
+	}
+
+	return keys
+}
+
+// Checks that all the settings are valid, and reports every problem found
+func (cfg *Config) Validate() error {
+
+	var errs []error
+	check := func(setting string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", setting, err))
+		}
+	}
+
+	check("origin", validatePlace(cfg.Origin))
+	if cfg.Destination != "" {
+		check("destination", validatePlace(cfg.Destination))
+	}
+	check("currency", validateCode(cfg.Currency, "currency"))
+	check("endpoints.aviationStack", validateUrl(cfg.Endpoints.AviationStackUrl))
+	check("endpoints.amadeus", validateUrl(cfg.Endpoints.AmadeusUrl))
+
+	if len(cfg.Schedule.Sources) == 0 {
+		check("schedule.sources", errors.New("at least one source is needed"))
+	}
+	for _, source := range cfg.Schedule.Sources {
+		check("schedule.sources", validateName(source, scheduleSources, "source"))
+		if source == FixtureSource && cfg.Schedule.Fixture == "" {
+			check("schedule.fixture", errors.New("a file is needed for the fixture source"))
+		}
+		if source == TimetableSource && cfg.Schedule.Timetable == "" {
+			check("schedule.timetable", errors.New("a file is needed for the timetable source"))
+		}
+	}
+	if len(cfg.Credentials.Providers) == 0 {
+		check("credentials.providers", errors.New("at least one provider is needed"))
+	}
+	for _, provider := range cfg.Credentials.Providers {
+		check("credentials.providers", validateName(provider, credentialProviders, "provider"))
+		if provider == CommandCredentials && cfg.Credentials.Command == "" {
+			check("credentials.command", errors.New("a command is needed for the command provider"))
+		}
+	}
+
+	if cfg.Schedule.RouteConfidence < 0 || cfg.Schedule.RouteConfidence > 1 {
+		check("schedule.routeConfidence", fmt.Errorf("%g is not between 0 and 1", cfg.Schedule.RouteConfidence))
+	}
+
+	if cfg.Nearby.Radius < 0 {
+		check("nearby.radius", fmt.Errorf("%g is negative", cfg.Nearby.Radius))
+	}
+	if cfg.Nearby.Radius > 0 {
+		_, _, err := airports.ParseLocation(cfg.getHome())
+		check("nearby.home", err)
+	}
+	for code, transfer := range cfg.Nearby.Transfers {
+		check("nearby.transfers", validateCode(code, "airport"))
+		if transfer.Minutes < 0 || transfer.Cost < 0 {
+			check("nearby.transfers."+code, errors.New("the time and cost can't be negative"))
+		}
+	}
+
+	check("airlines", cfg.GetAirlineFilter().Validate())
+	check("fares.requiredAmenities", cfg.GetFareFilter().Validate())
+	if cfg.CheckedBags < 0 {
+		check("checkedBags", fmt.Errorf("%d is negative", cfg.CheckedBags))
+	}
+
+	if _, err := pricing.ParseSortKeys(cfg.Ranking.Sort); err != nil {
+		check("ranking.sort", err)
+	}
+	check("ranking.weights", cfg.Ranking.Weights.Validate())
+	if _, err := pricing.ParseDimensions(cfg.Ranking.Pareto); err != nil {
+		check("ranking.pareto", err)
+	}
+
+	if _, err := cfg.GetCondition(); err != nil {
+		check("where", err)
+	}
+
+	if _, err := pricing.BuildOfferFilters(cfg.Pipeline.Offers); err != nil {
+		check("pipeline.offers", err)
+	}
+	if _, err := pricing.BuildEnrichers(cfg.Pipeline.Results); err != nil {
+		check("pipeline.results", err)
+	}
+
+	if cfg.Concurrency < 1 {
+		check("concurrency", fmt.Errorf("%d is less than 1", cfg.Concurrency))
+	}
+	if cfg.Quota.AviationStackMonthly < 0 {
+		check("quota.aviationStackMonthly", fmt.Errorf("%d is negative", cfg.Quota.AviationStackMonthly))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Checks that an origin or destination can be resolved to one or more airports
+func validatePlace(query string) error {
+
+	_, err := airports.Resolve(query)
+	return err
+}
+
+// Checks for a 3-letter code, such as an IATA airport code or ISO currency code
+func validateCode(code string, kind string) error {
+
+	if len(code) != 3 {
+		return fmt.Errorf("%q is not a 3-letter %s code", code, kind)
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return fmt.Errorf("%q is not a 3-letter %s code", code, kind)
+		}
+	}
+	return nil
+}
+
+func validateUrl(value string) error {
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", value)
+	}
+	return nil
+}
+
+func validateName(name string, names []string, kind string) error {
+
+	for _, n := range names {
+		if n == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s %q (expected one of %s)", kind, name, strings.Join(names, ", "))
+}
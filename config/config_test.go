@@ -0,0 +1,284 @@
+package config
+
+import (
+	"errors"
+	"flynow/credentials"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getEnv(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, found := values[name]
+		return value, found
+	}
+}
+
+func writeConfigFile(t *testing.T, contents string) string {
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+
+	// Arrange
+	path := writeConfigFile(t, `{
+		"origin": "BGO",
+		"currency": "EUR",
+		"concurrency": 2,
+		"cacheDir": "cache",
+		"credentials": { "providers": ["file"], "file": "secrets.json" }
+	}`)
+	env := getEnv(map[string]string{
+		"FLYNOW_CURRENCY":             "usd",
+		"FLYNOW_CONCURRENCY":          "3",
+		"FLYNOW_CREDENTIAL_PROVIDERS": "env, file",
+	})
+	flags := map[string]string{"concurrency": "4"}
+
+	// Act
+	cfg, err := Load(path, env, flags)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Origin != "BGO" {
+		t.Errorf("Origin is %q; Expected the file value BGO", cfg.Origin)
+	}
+	if cfg.Currency != "USD" {
+		t.Errorf("Currency is %q; Expected the environment value USD", cfg.Currency)
+	}
+	if cfg.Concurrency != 4 {
+		t.Errorf("Concurrency is %d; Expected the flag value 4", cfg.Concurrency)
+	}
+	if len(cfg.Credentials.Providers) != 2 || cfg.Credentials.Providers[0] != EnvCredentials || cfg.Credentials.Providers[1] != FileCredentials {
+		t.Errorf("Credential providers are %v; Expected the environment value [env file]", cfg.Credentials.Providers)
+	}
+	if expected := filepath.Join(filepath.Dir(path), "secrets.json"); cfg.Credentials.File != expected {
+		t.Errorf("Credentials file is %q; Expected %q", cfg.Credentials.File, expected)
+	}
+	if expected := filepath.Join(filepath.Dir(path), "cache"); cfg.CacheDir != expected {
+		t.Errorf("Cache directory is %q; Expected %q", cfg.CacheDir, expected)
+	}
+	if expected := filepath.Join(cfg.CacheDir, "routes.json"); cfg.Schedule.RouteDatabase != expected {
+		t.Errorf("Route database is %q; Expected %q", cfg.Schedule.RouteDatabase, expected)
+	}
+	if cfg.Endpoints.AmadeusUrl != DefaultAmadeusUrl {
+		t.Errorf("Amadeus URL is %q; Expected the default", cfg.Endpoints.AmadeusUrl)
+	}
+}
+
+func TestLoadUsesConfigVariable(t *testing.T) {
+
+	// Arrange
+	path := writeConfigFile(t, `{ "origin": "TRD" }`)
+
+	// Act
+	cfg, err := Load("", getEnv(map[string]string{"FLYNOW_CONFIG": path}), nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Origin != "TRD" {
+		t.Errorf("Origin is %q; Expected TRD", cfg.Origin)
+	}
+}
+
+func TestLoadMissingDefaultFile(t *testing.T) {
+
+	// Arrange
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	// Act
+	cfg, err := Load("", getEnv(nil), nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Origin != "OSL" || len(cfg.Schedule.Sources) != 1 || cfg.Schedule.Sources[0] != AviationStackSource {
+		t.Errorf("Got %+v; Expected the defaults", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		flags    map[string]string
+		expected string
+	}{
+		{name: "unknown setting in file", file: `{ "orign": "BGO" }`, expected: `unknown field "orign"`},
+		{name: "invalid JSON", file: `{ "origin": `, expected: "parsing config file"},
+		{name: "invalid number in environment", env: map[string]string{"FLYNOW_CONCURRENCY": "many"}, expected: "environment variable FLYNOW_CONCURRENCY"},
+		{name: "invalid flag value", flags: map[string]string{"route-confidence": "high"}, expected: "flag --route-confidence"},
+		{name: "invalid origin", flags: map[string]string{"origin": "OSLX"}, expected: "origin: unknown airport, metro area or city \"OSLX\""},
+		{name: "invalid destination", flags: map[string]string{"destination": "Atlantis"}, expected: "destination: unknown airport, metro area or city \"Atlantis\""},
+		{name: "invalid URL", env: map[string]string{"FLYNOW_AMADEUS_URL": "test.api.amadeus.com"}, expected: "endpoints.amadeus"},
+		{name: "unknown source", flags: map[string]string{"schedule-source": "aviationstack,oracle"}, expected: `unknown source "oracle"`},
+		{name: "missing timetable", flags: map[string]string{"schedule-source": "timetable"}, expected: "schedule.timetable"},
+		{name: "unknown credential provider", env: map[string]string{"FLYNOW_CREDENTIAL_PROVIDERS": "env,keychain"}, expected: `unknown provider "keychain"`},
+		{name: "missing credential command", flags: map[string]string{"credential-providers": "command"}, expected: "credentials.command"},
+		{name: "negative radius", flags: map[string]string{"radius": "-10"}, expected: "nearby.radius: -10 is negative"},
+		{name: "invalid home", flags: map[string]string{"radius": "100", "home": "91,10"}, expected: "nearby.home"},
+		{name: "home needed for unknown origin", flags: map[string]string{"radius": "100", "origin": "XQZ"}, expected: "nearby.home"},
+		{name: "negative transfer", file: `{ "nearby": { "transfers": { "TRF": { "minutes": -5 } } } }`, expected: "nearby.transfers.TRF"},
+		{name: "unknown alliance", flags: map[string]string{"alliances": "Star Alliance,Blue Sky"}, expected: `airlines: unknown alliance "Blue Sky"`},
+		{name: "invalid airline code", file: `{ "airlines": { "exclude": ["Ryanair"] } }`, expected: `airlines: invalid airline code "Ryanair"`},
+		{name: "negative checked bags", env: map[string]string{"FLYNOW_CHECKED_BAGS": "-1"}, expected: "checkedBags: -1 is negative"},
+		{name: "unknown amenity", flags: map[string]string{"required-amenities": "seat,lounge"}, expected: `fares.requiredAmenities: unknown amenity "lounge"`},
+		{name: "unknown sort key", flags: map[string]string{"sort": "price,comfort"}, expected: `ranking.sort: unknown sort key "comfort"`},
+		{name: "invalid weights flag", flags: map[string]string{"weights": "price=cheap"}, expected: "flag --weights"},
+		{name: "unknown measure in file", file: `{ "ranking": { "weights": { "comfort": 1 } } }`, expected: `ranking.weights: unknown measure "comfort"`},
+		{name: "unknown trade-off measure", flags: map[string]string{"pareto": "price,comfort"}, expected: `ranking.pareto: unknown measure "comfort"`},
+		{name: "invalid where expression", flags: map[string]string{"where": "price <"}, expected: "where: column 8: expected a value"},
+		{name: "where amount in another currency", flags: map[string]string{"currency": "NOK", "where": "price < 100 EUR"}, expected: "where: column 9: the amount is in EUR, but the prices are in NOK"},
+		{name: "unknown offer filter", file: `{ "pipeline": { "offers": [ { "stage": "direct" }, { "stage": "nonstop" } ] } }`, expected: `pipeline.offers: unknown offer filter "nonstop"`},
+		{name: "parameters for an enricher", file: `{ "pipeline": { "results": [ { "stage": "emissions", "params": { "per": "seat" } } ] } }`, expected: `pipeline.results: creating enricher "emissions": no parameters are expected`},
+		{name: "invalid concurrency", file: `{ "concurrency": 0 }`, expected: "concurrency: 0 is less than 1"},
+		{name: "negative quota", env: map[string]string{"FLYNOW_AVIATIONSTACK_QUOTA": "-1"}, expected: "quota.aviationStackMonthly"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Arrange
+			file := tc.file
+			if file == "" {
+				file = "{}"
+			}
+
+			// Act
+			_, err := Load(writeConfigFile(t, file), getEnv(tc.env), tc.flags)
+
+			// Assert
+			if err == nil {
+				t.Fatal("Load succeeded; Expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Got error %q; Expected it to contain %q", err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+
+	// Act
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"), getEnv(nil), nil)
+
+	// Assert
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Got error %v; Expected the file to be missing", err)
+	}
+}
+
+func TestGetRequiredCredentials(t *testing.T) {
+
+	// Arrange
+	cfg := Default()
+	cfg.Schedule.Sources = []string{TimetableSource}
+
+	// Act & Assert
+	if actual := cfg.GetRequiredCredentials(); len(actual) != 2 {
+		t.Errorf("Got %v; Expected no AviationStack key to be needed", actual)
+	}
+
+	cfg.Schedule.Sources = []string{LearnedSource, CrossCheckSource}
+	if actual := cfg.GetRequiredCredentials(); len(actual) != 3 || actual[2] != credentials.AviationStackApiKey {
+		t.Errorf("Got %v; Expected the AviationStack key to be needed", actual)
+	}
+}
+
+func TestGetOrigin(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		origin   string
+		nearby   Nearby
+		expected string
+	}{
+		{"airport", "BGO", Nearby{}, "BGO"},
+		{"metro area", "Oslo", Nearby{}, "Oslo (OSL, TRF, RYG)"},
+		{"within a radius of the origin", "OSL", Nearby{Radius: 100}, "Within 100 km of OSL (OSL, RYG)"},
+		{"within a radius of home", "OSL", Nearby{Radius: 120, Home: "59.91,10.75"}, "Within 120 km of 59.91,10.75 (OSL, RYG, TRF)"},
+		{"no airports within the radius", "OSL", Nearby{Radius: 1, Home: "62,8"}, "OSL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			cfg := Default()
+			cfg.Origin = test.origin
+			cfg.Nearby = test.nearby
+
+			// Act
+			actual := cfg.GetOrigin()
+
+			// Assert
+			if actual.String() != test.expected {
+				t.Errorf("Got origin %s; Expected %s", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestGetRanking(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		expected string
+	}{
+		{"default", nil, "[price]"},
+		{"nearby airports", map[string]string{"radius": "100"}, "[door-to-door]"},
+		{"weights", map[string]string{"radius": "100", "weights": "price=1,soonness=0.5"}, "[score]"},
+		{"sort keys", map[string]string{"weights": "price=1", "sort": "dest, -time"}, "[dest -time]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			cfg, err := Load(writeConfigFile(t, "{}"), getEnv(nil), test.flags)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			// Act
+			actual := cfg.GetRanking()
+
+			// Assert
+			if s := fmt.Sprint(actual.Keys); s != test.expected {
+				t.Errorf("Got sort keys %s; Expected %s", s, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadExampleConfig(t *testing.T) {
+
+	// Act
+	cfg, err := Load("example-config.json", getEnv(nil), nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, err := os.Stat(cfg.Schedule.Timetable); err != nil {
+		t.Errorf("Timetable path %q isn't relative to the config file: %v", cfg.Schedule.Timetable, err)
+	}
+}
@@ -0,0 +1,328 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"flynow/pricing"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A setting which can be given as an environment variable or a flag
+type setting struct {
+	// The flag name, which is also used for the environment variable (e.g. FLYNOW_CACHE_DIR for cache-dir)
+	name  string
+	usage string
+
+	apply func(cfg *Config, value string) error
+}
+
+var settings = []setting{
+	{name: "origin", usage: "airport or metro area `code`, or city name, to search from (e.g. OSL, LON or Oslo)", apply: func(cfg *Config, value string) error {
+		cfg.Origin = value
+		return nil
+	}},
+	{name: "destination", usage: "airport or metro area `code`, or city name, to search for flights to (the default is every destination in the schedule)", apply: func(cfg *Config, value string) error {
+		cfg.Destination = value
+		return nil
+	}},
+	{name: "currency", usage: "ISO `code` of the currency for the prices", apply: func(cfg *Config, value string) error {
+		cfg.Currency = value
+		return nil
+	}},
+	{name: "credential-providers", usage: "comma-separated `list` of credential providers (env, file, command or vault), in priority order", apply: func(cfg *Config, value string) error {
+		cfg.Credentials.Providers = splitList(value)
+		return nil
+	}},
+	{name: "credentials-file", usage: "JSON `file` of secrets, for the file credential provider", apply: func(cfg *Config, value string) error {
+		cfg.Credentials.File = value
+		return nil
+	}},
+	{name: "credential-command", usage: "`command` which prints the secret named {key}, for the command credential provider", apply: func(cfg *Config, value string) error {
+		cfg.Credentials.Command = value
+		return nil
+	}},
+	{name: "vault-file", usage: "encrypted `file` of secrets, for the vault credential provider", apply: func(cfg *Config, value string) error {
+		cfg.Credentials.Vault = value
+		return nil
+	}},
+	{name: "aviationstack-url", usage: "base `URL` of the AviationStack API", apply: func(cfg *Config, value string) error {
+		cfg.Endpoints.AviationStackUrl = value
+		return nil
+	}},
+	{name: "amadeus-url", usage: "base `URL` of the Amadeus API", apply: func(cfg *Config, value string) error {
+		cfg.Endpoints.AmadeusUrl = value
+		return nil
+	}},
+	{name: "schedule-source", usage: "comma-separated `list` of destination sources, in priority order", apply: func(cfg *Config, value string) error {
+		cfg.Schedule.Sources = splitList(value)
+		return nil
+	}},
+	{name: "merge-schedule-sources", usage: "merge the destinations from all the sources, rather than only using them as fallbacks (true or false)", apply: func(cfg *Config, value string) (err error) {
+		cfg.Schedule.Merge, err = strconv.ParseBool(value)
+		return err
+	}},
+	{name: "schedule-fixture", usage: "`file` of flights in the AviationStack format, for the fixture source", apply: func(cfg *Config, value string) error {
+		cfg.Schedule.Fixture = value
+		return nil
+	}},
+	{name: "timetable", usage: "SSIM or CSV `file`, for the timetable source", apply: func(cfg *Config, value string) error {
+		cfg.Schedule.Timetable = value
+		return nil
+	}},
+	{name: "route-database", usage: "`file` of routes learned from previous schedules", apply: func(cfg *Config, value string) error {
+		cfg.Schedule.RouteDatabase = value
+		return nil
+	}},
+	{name: "route-confidence", usage: "`fraction` of previous days a route must have flown on, for the learned source", apply: func(cfg *Config, value string) (err error) {
+		cfg.Schedule.RouteConfidence, err = strconv.ParseFloat(value, 64)
+		return err
+	}},
+	{name: "radius", usage: "search from every airport within this `distance` of home, in km (0 only searches from the origin)", apply: func(cfg *Config, value string) (err error) {
+		cfg.Nearby.Radius, err = strconv.ParseFloat(value, 64)
+		return err
+	}},
+	{name: "home", usage: "`location` where the journey starts, as latitude,longitude or an airport or city (the default is the origin)", apply: func(cfg *Config, value string) error {
+		cfg.Nearby.Home = value
+		return nil
+	}},
+	{name: "include-airlines", usage: "comma-separated `list` of IATA codes of the only airlines to fly with (e.g. SK,DY)", apply: func(cfg *Config, value string) error {
+		cfg.Airlines.Include = splitList(value)
+		return nil
+	}},
+	{name: "exclude-airlines", usage: "comma-separated `list` of IATA codes of airlines never to fly with (e.g. FR)", apply: func(cfg *Config, value string) error {
+		cfg.Airlines.Exclude = splitList(value)
+		return nil
+	}},
+	{name: "alliances", usage: "comma-separated `list` of the only airline alliances to fly with (e.g. Star Alliance,SkyTeam)", apply: func(cfg *Config, value string) error {
+		cfg.Airlines.Alliances = splitList(value)
+		return nil
+	}},
+	{name: "checked-bags", usage: "`number` of checked bags per traveler, whose cost is added to fares which don't include them", apply: func(cfg *Config, value string) (err error) {
+		cfg.CheckedBags, err = strconv.Atoi(value)
+		return err
+	}},
+	{name: "exclude-brands", usage: "comma-separated `list` of fare brands never to buy (e.g. LIGHT,LOWFARE)", apply: func(cfg *Config, value string) error {
+		cfg.Fares.ExcludeBrands = splitList(value)
+		return nil
+	}},
+	{name: "cabins", usage: "comma-separated `list` of the only cabins to fly in (e.g. ECONOMY,PREMIUM_ECONOMY)", apply: func(cfg *Config, value string) error {
+		cfg.Fares.Cabins = splitList(value)
+		return nil
+	}},
+	{name: "required-amenities", usage: "comma-separated `list` of amenities which the fare must include (e.g. checked-bag,seat)", apply: func(cfg *Config, value string) error {
+		cfg.Fares.RequiredAmenities = splitList(value)
+		return nil
+	}},
+	{name: "sort", usage: "comma-separated `list` of keys to sort the results by, with a leading - for descending order (e.g. score,-time)", apply: func(cfg *Config, value string) error {
+		cfg.Ranking.Sort = splitList(value)
+		return nil
+	}},
+	{name: "weights", usage: "comma-separated `list` of the weights in the score (e.g. price=1,soonness=0.5)", apply: func(cfg *Config, value string) (err error) {
+		cfg.Ranking.Weights, err = pricing.ParseWeights(value)
+		return err
+	}},
+	{name: "pareto", usage: "comma-separated `list` of measures to show the best trade-offs between, with a leading - where higher is better (e.g. price,soonness,duration)", apply: func(cfg *Config, value string) error {
+		cfg.Ranking.Pareto = splitList(value)
+		return nil
+	}},
+	{name: "where", usage: "`expression` which the flights must match, e.g. 'price < 1500 NOK && dest.country != \"NO\"'", apply: func(cfg *Config, value string) error {
+		cfg.Where = value
+		return nil
+	}},
+	{name: "concurrency", usage: "maximum `number` of flight searches at the same time", apply: func(cfg *Config, value string) (err error) {
+		cfg.Concurrency, err = strconv.Atoi(value)
+		return err
+	}},
+	{name: "cache-dir", usage: "`directory` for the route database and quota usage", apply: func(cfg *Config, value string) error {
+		cfg.CacheDir = value
+		return nil
+	}},
+	{name: "aviationstack-quota", usage: "`number` of AviationStack requests allowed per month, or 0 for no limit", apply: func(cfg *Config, value string) (err error) {
+		cfg.Quota.AviationStackMonthly, err = strconv.Atoi(value)
+		return err
+	}},
+}
+
+// Environment variable which gives the location of the config file
+const configFileVariable = "FLYNOW_CONFIG"
+
+// Gets the name of the environment variable for a setting
+func getVariableName(name string) string {
+	return "FLYNOW_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Gets the default location of the config file, in the user's config directory
+func GetDefaultPath() (string, error) {
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+
+	return filepath.Join(configDir, "flynow", "config.json"), nil
+}
+
+// The config flags registered on a flag set, which override the config file and environment variables
+type Flags struct {
+	path   *string
+	values map[string]*string
+	flags  *flag.FlagSet
+}
+
+// Registers the --config flag, and a flag for each of the settings
+func RegisterFlags(flags *flag.FlagSet) *Flags {
+
+	f := Flags{values: make(map[string]*string), flags: flags}
+	f.path = flags.String("config", "", "config `file` (default flynow/config.json in the user's config directory)")
+	for _, s := range settings {
+		f.values[s.name] = flags.String(s.name, "", s.usage)
+	}
+
+	return &f
+}
+
+// Loads the configuration, with the flags which were given on the command line taking precedence. This
+// must be called after the flags have been parsed.
+func (f *Flags) Load() (*Config, error) {
+
+	overrides := make(map[string]string)
+	f.flags.Visit(func(fl *flag.Flag) {
+		if value, found := f.values[fl.Name]; found {
+			overrides[fl.Name] = *value
+		}
+	})
+
+	return Load(*f.path, os.LookupEnv, overrides)
+}
+
+// Loads the configuration from the defaults, the config file, the environment variables (using the given
+// lookup function) and the given flag values, in that order of precedence. If no config file is given,
+// the FLYNOW_CONFIG environment variable or the default location is used. An explicitly given file must
+// exist, but the file in the default location is optional.
+func Load(path string, lookupEnv func(string) (string, bool), flagValues map[string]string) (*Config, error) {
+
+	cfg := Default()
+
+	if path == "" {
+		path, _ = lookupEnv(configFileVariable)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.Path = path
+	} else if defaultPath, err := GetDefaultPath(); err == nil {
+		err := loadFile(defaultPath, &cfg)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			cfg.Path = defaultPath
+		}
+	}
+
+	for _, s := range settings {
+		if value, found := lookupEnv(getVariableName(s.name)); found {
+			if err := s.apply(&cfg, strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("environment variable %s: %w", getVariableName(s.name), err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, found := flagValues[s.name]; found {
+			if err := s.apply(&cfg, strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("flag --%s: %w", s.name, err)
+			}
+		}
+	}
+
+	if err := resolveDefaults(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Reads the config file over the given configuration, so that any settings which aren't in the file keep
+// their previous values. Relative paths in the file are relative to the file's directory.
+func loadFile(path string, cfg *Config) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&cfg.CacheDir, &cfg.Credentials.File, &cfg.Credentials.Vault, &cfg.Schedule.Fixture, &cfg.Schedule.Timetable, &cfg.Schedule.RouteDatabase} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+
+	return nil
+}
+
+// Fills in the settings whose defaults depend on the environment or on other settings
+func resolveDefaults(cfg *Config) error {
+
+	cfg.Origin = strings.TrimSpace(cfg.Origin)
+	cfg.Destination = strings.TrimSpace(cfg.Destination)
+	cfg.Currency = strings.ToUpper(cfg.Currency)
+
+	if cfg.CacheDir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("finding cache directory: %w", err)
+		}
+		cfg.CacheDir = filepath.Join(cacheDir, "flynow")
+	}
+
+	if cfg.Credentials.File == "" || cfg.Credentials.Vault == "" {
+		path, err := GetDefaultPath()
+		if err != nil {
+			return err
+		}
+		if cfg.Credentials.File == "" {
+			cfg.Credentials.File = filepath.Join(filepath.Dir(path), "credentials.json")
+		}
+		if cfg.Credentials.Vault == "" {
+			cfg.Credentials.Vault = filepath.Join(filepath.Dir(path), "vault.json")
+		}
+	}
+
+	if cfg.Schedule.RouteDatabase == "" {
+		cfg.Schedule.RouteDatabase = filepath.Join(cfg.CacheDir, "routes.json")
+	}
+
+	return nil
+}
+
+func splitList(value string) []string {
+
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"flynow/config"
+	"flynow/credentials"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Environment variable which gives the vault passphrase, so that it doesn't need to be typed in
+const vaultPassphraseVariable = "FLYNOW_VAULT_PASSPHRASE"
+
+// Runs the credentials command, which saves or checks the API secrets:
+//
+//	flynow credentials set [--provider file|vault] <name>   (reads the secret from the input)
+//	flynow credentials check
+func runCredentials(args []string, in io.Reader, out io.Writer) error {
+
+	if len(args) == 0 {
+		return errors.New("usage: flynow credentials set|check [options]")
+	}
+
+	input := bufio.NewReader(in)
+	switch args[0] {
+	case "set":
+		return setCredential(args[1:], input, out)
+	case "check":
+		return checkCredentials(args[1:], input, out)
+	default:
+		return fmt.Errorf("unknown credentials command %q (expected set or check)", args[0])
+	}
+}
+
+// Saves a secret in the credentials file or vault. The secret is read from the input rather than given as
+// an argument, since the command line is visible to other users.
+func setCredential(args []string, input *bufio.Reader, out io.Writer) error {
+
+	flags := flag.NewFlagSet("flynow credentials set", flag.ContinueOnError)
+	providerName := flags.String("provider", config.FileCredentials, "where to save the secret (file or vault)")
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || !slices.Contains(credentials.Keys, flags.Arg(0)) {
+		return fmt.Errorf("expected the name of a secret (one of %s)", strings.Join(credentials.Keys, ", "))
+	}
+	key := flags.Arg(0)
+
+	cfg, err := configFlags.Load()
+	if err != nil {
+		return err
+	}
+
+	var store credentials.Store
+	switch *providerName {
+	case config.FileCredentials:
+		store = credentials.FileProvider{Path: cfg.Credentials.File}
+	case config.VaultCredentials:
+		store = credentials.NewVaultProvider(cfg.Credentials.Vault, getPassphraseReader(input, out))
+	default:
+		return fmt.Errorf("secrets can only be saved with the file or vault provider, not %q", *providerName)
+	}
+
+	value, err := readLine(input, out, fmt.Sprintf("Value for %s: ", key))
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return errors.New("the secret can't be empty")
+	}
+
+	if err := store.Set(key, credentials.Secret(value)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Saved %s in %s\n", key, store.Name())
+	if !slices.Contains(cfg.Credentials.Providers, *providerName) {
+		fmt.Fprintf(out, "Note: The %s provider isn't enabled in the configuration, so this secret won't be used\n", *providerName)
+	}
+
+	return nil
+}
+
+// Shows where each of the secrets was found (but not the secrets themselves), and reports any which are
+// needed but missing
+func checkCredentials(args []string, input *bufio.Reader, out io.Writer) error {
+
+	flags := flag.NewFlagSet("flynow credentials check", flag.ContinueOnError)
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := configFlags.Load()
+	if err != nil {
+		return err
+	}
+
+	chain := getCredentialProvider(cfg, getPassphraseReader(input, out))
+	required := cfg.GetRequiredCredentials()
+
+	var errs []error
+	for _, key := range credentials.Keys {
+		provider, _, err := chain.Find(key)
+		switch {
+		case err == nil:
+			fmt.Fprintf(out, "%s: found in %s\n", key, provider.Name())
+		case errors.Is(err, credentials.ErrNotFound) && !slices.Contains(required, key):
+			fmt.Fprintf(out, "%s: missing (not needed by the selected sources)\n", key)
+		case errors.Is(err, credentials.ErrNotFound):
+			fmt.Fprintf(out, "%s: MISSING\n", key)
+			errs = append(errs, fmt.Errorf("%s: %w in %s", key, credentials.ErrNotFound, chain.Name()))
+		default:
+			fmt.Fprintf(out, "%s: ERROR %v\n", key, err)
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Gets a provider which reads the secrets from the configured providers, in order
+func getCredentialProvider(cfg *config.Config, passphrase func() (string, error)) *credentials.Chain {
+
+	providers := make([]credentials.Provider, 0, len(cfg.Credentials.Providers))
+	for _, name := range cfg.Credentials.Providers {
+		switch name {
+		case config.EnvCredentials:
+			providers = append(providers, credentials.EnvProvider{})
+		case config.FileCredentials:
+			providers = append(providers, credentials.FileProvider{Path: cfg.Credentials.File})
+		case config.CommandCredentials:
+			providers = append(providers, credentials.CommandProvider{Command: cfg.Credentials.Command})
+		case config.VaultCredentials:
+			providers = append(providers, credentials.NewVaultProvider(cfg.Credentials.Vault, passphrase))
+		}
+	}
+
+	return credentials.NewChain(providers...)
+}
+
+// Gets a function which reads the vault passphrase from the environment, or else from the input
+func getPassphraseReader(input *bufio.Reader, out io.Writer) func() (string, error) {
+
+	return func() (string, error) {
+		if passphrase, found := os.LookupEnv(vaultPassphraseVariable); found {
+			return passphrase, nil
+		}
+
+		passphrase, err := readLine(input, out, "Vault passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("no passphrase given (type it in, or set %s)", vaultPassphraseVariable)
+		}
+		return passphrase, nil
+	}
+}
+
+// Shows the prompt, and reads a line from the input
+func readLine(input *bufio.Reader, out io.Writer, prompt string) (string, error) {
+
+	fmt.Fprint(out, prompt)
+	line, err := input.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
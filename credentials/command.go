@@ -0,0 +1,56 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Provider which runs an external command to get each secret, such as a password manager CLI. The command
+// is split into arguments on spaces, and {key} in any argument is replaced by the name of the secret, e.g.
+//
+//	pass show flynow/{key}
+//	op read op://Private/flynow/{key}
+//
+// The first line of the output is used as the secret. The command is run directly, rather than by a shell.
+type CommandProvider struct {
+	Command string
+}
+
+func (provider CommandProvider) Name() string {
+	return "command"
+}
+
+func (provider CommandProvider) Get(key string) (Secret, error) {
+
+	args := strings.Fields(provider.Command)
+	if len(args) == 0 {
+		return "", errors.New("no credential command configured")
+	}
+	for i := range args {
+		args[i] = strings.ReplaceAll(args[i], "{key}", key)
+	}
+
+	// Note: The output isn't included in any error, since it may contain the secret, and neither is the
+	// error output, since some tools echo their input
+	var output bytes.Buffer
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdout = &output
+	if err := command.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("credential command %s failed with exit code %d", args[0], exitErr.ExitCode())
+		}
+		return "", fmt.Errorf("running credential command %s: %w", args[0], err)
+	}
+
+	value, _, _ := strings.Cut(output.String(), "\n")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", ErrNotFound
+	}
+
+	return Secret(value), nil
+}
@@ -0,0 +1,165 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Secrets used to access the APIs, which can be read from several kinds of storage. Secret values are never
+// included in error messages, and are redacted if they are formatted or logged by accident.
+
+// Names of the secrets
+const (
+	AviationStackApiKey = "aviationstack-api-key"
+	AmadeusClientId     = "amadeus-client-id"
+	AmadeusClientSecret = "amadeus-client-secret"
+)
+
+// All the secrets used by the application
+var Keys = []string{AviationStackApiKey, AmadeusClientId, AmadeusClientSecret}
+
+// Error returned when a provider doesn't have the requested secret
+var ErrNotFound = errors.New("credential not found")
+
+// Storage for secrets, such as environment variables, a file or a password manager
+type Provider interface {
+	// Gets the name of the provider, for messages
+	Name() string
+
+	// Gets the secret with the given name, or returns ErrNotFound if it isn't there
+	Get(key string) (Secret, error)
+}
+
+// Storage which secrets can also be saved to
+type Store interface {
+	Provider
+	Set(key string, value Secret) error
+}
+
+// A secret value, which is redacted when it is formatted, so that it can't end up in an error message or log
+// by mistake. The value must be read explicitly with Reveal.
+type Secret string
+
+const redacted = "[REDACTED]"
+
+func (secret Secret) String() string {
+	return redacted
+}
+
+func (secret Secret) GoString() string {
+	return redacted
+}
+
+func (secret Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// Gets the actual secret value
+func (secret Secret) Reveal() string {
+	return string(secret)
+}
+
+// Gets the secret from the provider, with an error naming the missing secret and provider
+func Get(provider Provider, key string) (Secret, error) {
+
+	secret, err := provider.Get(key)
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%s: %w in %s", key, ErrNotFound, provider.Name())
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading %s from %s: %w", key, provider.Name(), err)
+	}
+
+	return secret, nil
+}
+
+// Provider which tries several other providers in order, and remembers the secrets it has found, so that
+// each one is only read (e.g. with a slow command, or a passphrase prompt) once
+type Chain struct {
+	providers []Provider
+	mutex     sync.Mutex
+	found     map[string]foundSecret
+}
+
+type foundSecret struct {
+	provider Provider
+	secret   Secret
+}
+
+// Creates a provider which tries each of the given providers in order
+func NewChain(providers ...Provider) *Chain {
+	chain := Chain{providers: providers, found: make(map[string]foundSecret)}
+	return &chain
+}
+
+func (chain *Chain) Name() string {
+
+	names := make([]string, 0, len(chain.providers))
+	for _, p := range chain.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Gets the secret from the first provider which has it. A provider which fails (rather than just not having
+// the secret) stops the search, so that a broken password manager isn't silently skipped.
+func (chain *Chain) Get(key string) (Secret, error) {
+
+	_, secret, err := chain.Find(key)
+	return secret, err
+}
+
+// Gets the secret from the first provider which has it, along with that provider
+func (chain *Chain) Find(key string) (Provider, Secret, error) {
+
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+
+	if f, found := chain.found[key]; found {
+		return f.provider, f.secret, nil
+	}
+
+	for _, p := range chain.providers {
+		secret, err := p.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return p, "", fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		chain.found[key] = foundSecret{provider: p, secret: secret}
+		return p, secret, nil
+	}
+
+	return nil, "", ErrNotFound
+}
+
+// Provider with fixed secrets, e.g. placeholders for replaying recorded requests
+type Static map[string]Secret
+
+func (static Static) Name() string {
+	return "static"
+}
+
+func (static Static) Get(key string) (Secret, error) {
+
+	if secret, found := static[key]; found {
+		return secret, nil
+	}
+	return "", ErrNotFound
+}
+
+// Checks that the provider has all of the given secrets, and reports all the missing ones
+func Check(provider Provider, keys ...string) error {
+
+	var errs []error
+	for _, key := range keys {
+		if _, err := Get(provider, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
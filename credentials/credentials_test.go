@@ -0,0 +1,164 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSecretIsRedacted(t *testing.T) {
+
+	// Arrange
+	secret := Secret("hunter2")
+	wrapped := struct{ Key Secret }{secret}
+	data, _ := json.Marshal(wrapped)
+
+	// Act
+	formatted := []string{
+		fmt.Sprint(secret),
+		fmt.Sprintf("%s %v %q %+v %#v", secret, secret, secret, wrapped, wrapped),
+		fmt.Errorf("failed with %v", secret).Error(),
+		string(data),
+	}
+
+	// Assert
+	for _, f := range formatted {
+		if strings.Contains(f, "hunter2") {
+			t.Errorf("Secret appears in %q", f)
+		}
+	}
+	if secret.Reveal() != "hunter2" {
+		t.Errorf("Revealed %q; Expected hunter2", secret.Reveal())
+	}
+}
+
+func TestChainUsesFirstProviderWithSecret(t *testing.T) {
+
+	// Arrange
+	first := Static{AmadeusClientId: "first-id"}
+	second := Static{AmadeusClientId: "second-id", AmadeusClientSecret: "second-secret"}
+	chain := NewChain(first, second)
+
+	// Act
+	id, idErr := chain.Get(AmadeusClientId)
+	secret, secretErr := chain.Get(AmadeusClientSecret)
+	_, missingErr := Get(chain, AviationStackApiKey)
+
+	// Assert
+	if idErr != nil || id.Reveal() != "first-id" {
+		t.Errorf("Got %q (error %v); Expected the secret from the first provider", id.Reveal(), idErr)
+	}
+	if secretErr != nil || secret.Reveal() != "second-secret" {
+		t.Errorf("Got %q (error %v); Expected the secret from the second provider", secret.Reveal(), secretErr)
+	}
+	if !errors.Is(missingErr, ErrNotFound) || !strings.Contains(missingErr.Error(), AviationStackApiKey) {
+		t.Errorf("Got error %v; Expected it to name the missing secret", missingErr)
+	}
+}
+
+func TestChainStopsAtFailingProvider(t *testing.T) {
+
+	// Arrange
+	chain := NewChain(CommandProvider{Command: "false"}, Static{AmadeusClientId: "id"})
+
+	// Act
+	_, err := chain.Get(AmadeusClientId)
+
+	// Assert
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Got error %v; Expected the failing command to be reported", err)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+
+	// Arrange
+	env := map[string]string{"FLYNOW_AMADEUS_CLIENT_SECRET": " secret \n", "FLYNOW_AMADEUS_CLIENT_ID": ""}
+	provider := EnvProvider{LookupEnv: func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}}
+
+	// Act
+	secret, err := provider.Get(AmadeusClientSecret)
+	_, emptyErr := provider.Get(AmadeusClientId)
+
+	// Assert
+	if err != nil || secret.Reveal() != "secret" {
+		t.Errorf("Got %q (error %v); Expected secret", secret.Reveal(), err)
+	}
+	if !errors.Is(emptyErr, ErrNotFound) {
+		t.Errorf("Got error %v; Expected an empty variable to be treated as missing", emptyErr)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+
+	// Arrange
+	provider := FileProvider{Path: filepath.Join(t.TempDir(), "flynow", "credentials.json")}
+
+	// Act
+	_, missingErr := provider.Get(AmadeusClientId)
+	setErr := provider.Set(AmadeusClientId, "file-id")
+	secret, getErr := provider.Get(AmadeusClientId)
+
+	// Assert
+	if !errors.Is(missingErr, ErrNotFound) {
+		t.Errorf("Got error %v; Expected a missing file to have no secrets", missingErr)
+	}
+	if setErr != nil || getErr != nil || secret.Reveal() != "file-id" {
+		t.Fatalf("Got %q (errors %v, %v); Expected the saved secret", secret.Reveal(), setErr, getErr)
+	}
+	if info, err := os.Stat(provider.Path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0o600) {
+		t.Errorf("File has mode %v (error %v); Expected 0600", info.Mode(), err)
+	}
+}
+
+func TestFileProviderRejectsSharedFile(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("File permissions aren't checked on Windows")
+	}
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"amadeus-client-id":"shared-id"}`), 0o644); err != nil {
+		t.Fatalf("Unable to write credentials file: %v", err)
+	}
+
+	// Act
+	_, err := FileProvider{Path: path}.Get(AmadeusClientId)
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("Got error %v; Expected the file to be rejected", err)
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("The test command needs a Unix shell")
+	}
+
+	// Arrange
+	provider := CommandProvider{Command: "echo value-for-{key}"}
+	failing := CommandProvider{Command: "sh -c echo-oops-secret-output;exit 3"}
+
+	// Act
+	secret, err := provider.Get(AmadeusClientId)
+	_, failErr := failing.Get(AmadeusClientId)
+
+	// Assert
+	if err != nil || secret.Reveal() != "value-for-amadeus-client-id" {
+		t.Errorf("Got %q (error %v); Expected value-for-amadeus-client-id", secret.Reveal(), err)
+	}
+	if failErr == nil || strings.Contains(failErr.Error(), "secret") {
+		t.Errorf("Got error %v; Expected a failure without the output", failErr)
+	}
+}
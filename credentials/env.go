@@ -0,0 +1,36 @@
+package credentials
+
+import (
+	"os"
+	"strings"
+)
+
+// Provider which reads the secrets from environment variables, such as FLYNOW_AMADEUS_CLIENT_SECRET
+type EnvProvider struct {
+	// Looks up an environment variable, in the same way as os.LookupEnv (which is used if this is nil)
+	LookupEnv func(string) (string, bool)
+}
+
+// Gets the name of the environment variable for a secret
+func GetVariableName(key string) string {
+	return "FLYNOW_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+func (provider EnvProvider) Name() string {
+	return "environment"
+}
+
+func (provider EnvProvider) Get(key string) (Secret, error) {
+
+	lookupEnv := provider.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	value, found := lookupEnv(GetVariableName(key))
+	if !found || strings.TrimSpace(value) == "" {
+		return "", ErrNotFound
+	}
+
+	return Secret(strings.TrimSpace(value)), nil
+}
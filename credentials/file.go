@@ -0,0 +1,122 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Provider which reads the secrets from a JSON file, which must only be readable by its owner:
+//
+//	{ "amadeus-client-id": "...", "amadeus-client-secret": "..." }
+type FileProvider struct {
+	Path string
+}
+
+func (provider FileProvider) Name() string {
+	return "file " + provider.Path
+}
+
+func (provider FileProvider) Get(key string) (Secret, error) {
+
+	secrets, err := provider.read()
+	if err != nil {
+		return "", err
+	}
+
+	if value, found := secrets[key]; found && value != "" {
+		return Secret(value), nil
+	}
+	return "", ErrNotFound
+}
+
+// Saves the secret in the file, which is created (only readable by its owner) if it doesn't exist yet
+func (provider FileProvider) Set(key string, value Secret) error {
+
+	secrets, err := provider.read()
+	if errors.Is(err, ErrNotFound) {
+		secrets = make(map[string]string)
+	} else if err != nil {
+		return err
+	}
+
+	secrets[key] = value.Reveal()
+
+	data, err := json.MarshalIndent(secrets, "", "    ")
+	if err != nil {
+		return fmt.Errorf("serializing credentials: %w", err)
+	}
+
+	return writePrivateFile(provider.Path, data)
+}
+
+// Reads all the secrets in the file, or returns ErrNotFound if there is no file
+func (provider FileProvider) read() (map[string]string, error) {
+
+	info, err := os.Stat(provider.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking credentials file: %w", err)
+	}
+	if err := checkPrivate(info); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(provider.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading credentials file: %w", err)
+	}
+
+	// Note: The JSON error is left out, since it can quote part of the file
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("credentials file %s is not a JSON object of strings", provider.Path)
+	}
+
+	return secrets, nil
+}
+
+// Checks that a file containing secrets can't be read by other users. Windows doesn't use Unix permissions,
+// so the check is skipped there.
+func checkPrivate(info fs.FileInfo) error {
+
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if mode := info.Mode().Perm(); mode&0o077 != 0 {
+		return fmt.Errorf("%s can be accessed by other users (mode %04o), so it won't be used; run chmod 600 on it", info.Name(), mode)
+	}
+
+	return nil
+}
+
+// Writes a file which only its owner can read, via a temporary file so that an interrupted save can't
+// lose the existing secrets
+func writePrivateFile(path string, data []byte) error {
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating credentials directory: %w", err)
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	// The permissions aren't changed if the temporary file was left over from an earlier attempt
+	if err := os.Chmod(tempPath, 0o600); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	return nil
+}
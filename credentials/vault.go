@@ -0,0 +1,236 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// Provider which keeps the secrets in a local file encrypted with a passphrase. The key is derived from
+// the passphrase with PBKDF2-HMAC-SHA256, and the secrets are encrypted with AES-256-GCM, so that any
+// change to the file is detected. The passphrase is only requested if the vault file exists.
+type VaultProvider struct {
+	path string
+
+	// Gets the passphrase, e.g. from an environment variable or a prompt
+	passphrase func() (string, error)
+
+	mutex   sync.Mutex
+	secrets map[string]string
+	key     []byte
+	salt    []byte
+
+	// The number of PBKDF2 iterations the key was derived with, which is kept when the vault is saved
+	iterations int
+}
+
+// Format of the vault file
+type vaultFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+const vaultVersion = 1
+
+// Number of PBKDF2 iterations for new vaults, as recommended by OWASP for PBKDF2-HMAC-SHA256
+var vaultIterations = 600000
+
+// Error returned when the vault can't be decrypted with the passphrase
+var ErrWrongPassphrase = errors.New("unable to decrypt vault (wrong passphrase, or the file has been changed)")
+
+// Creates a provider for the vault in the given file, which uses the given function to get the passphrase
+// when the vault is first opened
+func NewVaultProvider(path string, passphrase func() (string, error)) *VaultProvider {
+	provider := VaultProvider{path: path, passphrase: passphrase}
+	return &provider
+}
+
+func (provider *VaultProvider) Name() string {
+	return "vault " + provider.path
+}
+
+func (provider *VaultProvider) Get(key string) (Secret, error) {
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if err := provider.open(false); err != nil {
+		return "", err
+	}
+
+	if value, found := provider.secrets[key]; found && value != "" {
+		return Secret(value), nil
+	}
+	return "", ErrNotFound
+}
+
+// Saves the secret in the vault, which is created if it doesn't exist yet
+func (provider *VaultProvider) Set(key string, value Secret) error {
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if err := provider.open(true); err != nil {
+		return err
+	}
+
+	provider.secrets[key] = value.Reveal()
+
+	return provider.save()
+}
+
+// Decrypts the vault, if that hasn't already been done. If the vault doesn't exist, either ErrNotFound is
+// returned, or (if create is set) an empty vault is set up with a new key.
+func (provider *VaultProvider) open(create bool) error {
+
+	if provider.secrets != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(provider.path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		return provider.create()
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("reading vault: %w", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing vault %s: %w", provider.path, err)
+	}
+	if file.Version != vaultVersion {
+		return fmt.Errorf("unsupported vault version %d", file.Version)
+	}
+
+	passphrase, err := provider.passphrase()
+	if err != nil {
+		return fmt.Errorf("getting vault passphrase: %w", err)
+	}
+
+	key, err := deriveKey(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decrypt(key, file.Nonce, file.Data)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("vault %s doesn't contain a JSON object of strings", provider.path)
+	}
+
+	provider.secrets, provider.key, provider.salt, provider.iterations = secrets, key, file.Salt, file.Iterations
+	return nil
+}
+
+func (provider *VaultProvider) create() error {
+
+	passphrase, err := provider.passphrase()
+	if err != nil {
+		return fmt.Errorf("getting vault passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return errors.New("the vault passphrase can't be empty")
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("generating salt: %w", err)
+	}
+
+	key, err := deriveKey(passphrase, salt, vaultIterations)
+	if err != nil {
+		return err
+	}
+
+	provider.secrets = make(map[string]string)
+	provider.salt, provider.key, provider.iterations = salt, key, vaultIterations
+	return nil
+}
+
+// Encrypts the secrets with a new nonce, and writes them to the vault file
+func (provider *VaultProvider) save() error {
+
+	plaintext, err := json.Marshal(provider.secrets)
+	if err != nil {
+		return fmt.Errorf("serializing vault: %w", err)
+	}
+
+	nonce, ciphertext, err := encrypt(provider.key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{Version: vaultVersion, Iterations: provider.iterations, Salt: provider.salt, Nonce: nonce, Data: ciphertext}
+	data, err := json.MarshalIndent(file, "", "    ")
+	if err != nil {
+		return fmt.Errorf("serializing vault: %w", err)
+	}
+
+	return writePrivateFile(provider.path, data)
+}
+
+// Derives the AES-256 key from the passphrase
+func deriveKey(passphrase string, salt []byte, iterations int) ([]byte, error) {
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("deriving vault key: %w", err)
+	}
+	return key, nil
+}
+
+func encrypt(key []byte, plaintext []byte) (nonce []byte, ciphertext []byte, err error) {
+
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func decrypt(key []byte, nonce []byte, ciphertext []byte) ([]byte, error) {
+
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,147 @@
+package credentials
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// Keep the tests fast, since the security of the key derivation isn't being tested
+	vaultIterations = 1000
+}
+
+func getPassphrase(passphrase string) func() (string, error) {
+	return func() (string, error) {
+		return passphrase, nil
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+
+	// The first 32 bytes of the test vector from RFC 7914 section 11
+	const expected = "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+
+	// Act
+	key, err := deriveKey("passwd", []byte("salt"), 1)
+
+	// Assert
+	if actual := hex.EncodeToString(key); err != nil || actual != expected {
+		t.Errorf("Got %s (error %v); Expected %s", actual, err, expected)
+	}
+}
+
+func TestVaultKeepsIterations(t *testing.T) {
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "vault.json")
+	if err := NewVaultProvider(path, getPassphrase("correct horse")).Set(AmadeusClientId, "vault-id"); err != nil {
+		t.Fatalf("Unable to save secret: %v", err)
+	}
+	vaultIterations = 2000
+	defer func() { vaultIterations = 1000 }()
+
+	// Act
+	setErr := NewVaultProvider(path, getPassphrase("correct horse")).Set(AmadeusClientSecret, "vault-secret")
+	secret, getErr := NewVaultProvider(path, getPassphrase("correct horse")).Get(AmadeusClientId)
+
+	// Assert
+	if setErr != nil {
+		t.Fatalf("Unable to update vault: %v", setErr)
+	}
+	if getErr != nil || secret.Reveal() != "vault-id" {
+		t.Errorf("Got %q (error %v); Expected the vault to open with the iterations it was created with", secret.Reveal(), getErr)
+	}
+	var file vaultFile
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &file); err != nil || file.Iterations != 1000 {
+		t.Errorf("Got %d iterations (error %v); Expected 1000", file.Iterations, err)
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "vault.json")
+	vault := NewVaultProvider(path, getPassphrase("correct horse"))
+
+	// Act
+	firstErr := vault.Set(AmadeusClientId, "vault-id")
+	secondErr := vault.Set(AmadeusClientSecret, "vault-secret")
+	reopened := NewVaultProvider(path, getPassphrase("correct horse"))
+	secret, getErr := reopened.Get(AmadeusClientSecret)
+
+	// Assert
+	if firstErr != nil || secondErr != nil {
+		t.Fatalf("Unable to save secrets: %v, %v", firstErr, secondErr)
+	}
+	if getErr != nil || secret.Reveal() != "vault-secret" {
+		t.Errorf("Got %q (error %v); Expected vault-secret", secret.Reveal(), getErr)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "vault-secret") || strings.Contains(string(data), "correct horse") {
+		t.Errorf("Vault file contains plain text: %s", data)
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "vault.json")
+	if err := NewVaultProvider(path, getPassphrase("right")).Set(AmadeusClientId, "vault-id"); err != nil {
+		t.Fatalf("Unable to save secret: %v", err)
+	}
+
+	// Act
+	_, err := NewVaultProvider(path, getPassphrase("wrong")).Get(AmadeusClientId)
+
+	// Assert
+	if !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Got error %v; Expected the wrong passphrase to be reported", err)
+	}
+}
+
+func TestVaultDetectsChanges(t *testing.T) {
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "vault.json")
+	if err := NewVaultProvider(path, getPassphrase("right")).Set(AmadeusClientId, "vault-id"); err != nil {
+		t.Fatalf("Unable to save secret: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), `"data": "`, `"data": "AAAA`, 1)
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatalf("Unable to change vault: %v", err)
+	}
+
+	// Act
+	_, err := NewVaultProvider(path, getPassphrase("right")).Get(AmadeusClientId)
+
+	// Assert
+	if !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Got error %v; Expected the change to be detected", err)
+	}
+}
+
+func TestMissingVaultDoesNotAskForPassphrase(t *testing.T) {
+
+	// Arrange
+	asked := false
+	vault := NewVaultProvider(filepath.Join(t.TempDir(), "vault.json"), func() (string, error) {
+		asked = true
+		return "", nil
+	})
+
+	// Act
+	_, err := vault.Get(AmadeusClientId)
+
+	// Assert
+	if !errors.Is(err, ErrNotFound) || asked {
+		t.Errorf("Got error %v (asked for passphrase: %v); Expected the secret to be missing", err, asked)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"flynow/airports"
+	"flynow/amadeus"
+	"flynow/apierror"
+	"flynow/config"
+	"flynow/credentials"
+	"flynow/quota"
+	"flynow/schedule"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Outcome of a single diagnostic check
+type checkStatus string
+
+const (
+	checkPassed  checkStatus = "OK"
+	checkWarning checkStatus = "WARN"
+	checkFailed  checkStatus = "FAIL"
+	checkSkipped checkStatus = "SKIP"
+)
+
+type checkResult struct {
+	name   string
+	status checkStatus
+	detail string
+
+	// What the user can do about a failure or warning
+	fix string
+}
+
+// The largest difference from the server's clock which isn't reported
+const maxClockSkew = 30 * time.Second
+
+// The share of the monthly quota which, once used, is reported as a warning
+const quotaWarningLevel = 0.8
+
+// Runs the doctor command, which checks the configuration, the credentials and the connections to the APIs,
+// and says what to fix for each problem found:
+//
+//	flynow doctor [--probe-aviationstack]
+func runDoctor(args []string, in io.Reader, out io.Writer) error {
+
+	flags := flag.NewFlagSet("flynow doctor", flag.ContinueOnError)
+	probe := flags.Bool("probe-aviationstack", false, "make one AviationStack request to check the API key (uses 1 of the monthly requests)")
+	logging := registerLogFlags(flags)
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	defer logging.setup()()
+
+	results := make([]checkResult, 0)
+	report := func(result checkResult) {
+		results = append(results, result)
+		printCheck(out, result)
+	}
+
+	// Nothing else can be checked without a valid configuration
+	cfg, err := configFlags.Load()
+	report(checkConfig(cfg, err))
+	if err != nil {
+		return errors.New("1 check failed")
+	}
+	report(checkOrigin(cfg))
+
+	secrets := getCredentialProvider(cfg, getPassphraseReader(bufio.NewReader(in), out))
+	usage := quota.NewTracker(cfg.GetQuotaPath(), config.AviationStackSource, cfg.Quota.AviationStackMonthly)
+	amadeusClient := amadeus.NewClient(cfg.Endpoints.AmadeusUrl, secrets)
+
+	credentialsResult := checkCredentialsPresent(cfg, secrets)
+	report(credentialsResult)
+	if credentialsResult.status == checkFailed {
+		report(checkResult{"Amadeus token", checkSkipped, "not checked, since the credentials aren't available", ""})
+	} else {
+		report(checkAmadeusToken(cfg, amadeusClient))
+	}
+	report(checkAviationStack(cfg, secrets, usage, *probe))
+	report(checkQuota(cfg, usage))
+	report(checkClockSkew(amadeusClient))
+	for _, result := range checkProviders(cfg) {
+		report(result)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.status == checkFailed {
+			failed++
+		}
+	}
+	switch failed {
+	case 0:
+		fmt.Fprintln(out, "\nNo problems found")
+		return nil
+	case 1:
+		return errors.New("1 check failed")
+	default:
+		return fmt.Errorf("%d checks failed", failed)
+	}
+}
+
+func printCheck(out io.Writer, result checkResult) {
+
+	fmt.Fprintf(out, "[%-4s] %s: %s\n", result.status, result.name, result.detail)
+	if result.fix != "" {
+		fmt.Fprintf(out, "       FIX: %s\n", result.fix)
+	}
+}
+
+func checkConfig(cfg *config.Config, err error) checkResult {
+
+	const name = "Configuration"
+
+	if err != nil {
+		return checkResult{name, checkFailed, err.Error(), "Correct the settings named above, in the config file, the FLYNOW_* environment variables or the flags"}
+	}
+
+	if cfg.Path == "" {
+		path, _ := config.GetDefaultPath()
+		return checkResult{name, checkPassed, fmt.Sprintf("using the defaults and environment (no config file at %s)", path), ""}
+	}
+	return checkResult{name, checkPassed, "loaded from " + cfg.Path, ""}
+}
+
+// Checks that the origin's airports are known. This is only a warning, since the airport database doesn't
+// include every airport which the APIs know about.
+func checkOrigin(cfg *config.Config) checkResult {
+
+	const name = "Origin"
+
+	origin := cfg.GetOrigin()
+	for _, code := range origin.Airports {
+		if err := airports.Validate(code); err != nil {
+			return checkResult{name, checkWarning, fmt.Sprintf("%s isn't in the airport database", code), `Check the IATA code with "flynow airport <city>"; if it is correct, it is just missing from the database`}
+		}
+	}
+
+	if origin.IsGroup() {
+		return checkResult{name, checkPassed, origin.String(), ""}
+	}
+	airport, _ := airports.Lookup(origin.Code)
+	return checkResult{name, checkPassed, airport.String(), ""}
+}
+
+func checkCredentialsPresent(cfg *config.Config, secrets *credentials.Chain) checkResult {
+
+	const name = "Credentials"
+
+	found := make([]string, 0)
+	var problems, fixes []string
+	for _, key := range cfg.GetRequiredCredentials() {
+		provider, _, err := secrets.Find(key)
+		switch {
+		case err == nil:
+			found = append(found, fmt.Sprintf("%s (%s)", key, provider.Name()))
+		case errors.Is(err, credentials.ErrNotFound):
+			problems = append(problems, key+" is missing")
+			fixes = append(fixes, fmt.Sprintf(`run "flynow credentials set %s" or set %s`, key, credentials.GetVariableName(key)))
+		default:
+			problems = append(problems, fmt.Sprintf("%s can't be read: %v", key, err))
+			fixes = append(fixes, fmt.Sprintf("fix the %s provider, or remove it from credentials.providers", provider.Name()))
+		}
+	}
+
+	if len(problems) > 0 {
+		return checkResult{name, checkFailed, strings.Join(problems, "; "), capitalize(strings.Join(fixes, "; "))}
+	}
+	return checkResult{name, checkPassed, "found " + strings.Join(found, ", "), ""}
+}
+
+func checkAmadeusToken(cfg *config.Config, client *amadeus.Client) checkResult {
+
+	const name = "Amadeus token"
+
+	err := client.Authenticate()
+	if err == nil {
+		return checkResult{name, checkPassed, "got a token from " + cfg.Endpoints.AmadeusUrl, ""}
+	}
+
+	switch {
+	case errors.Is(err, credentials.ErrNotFound):
+		return checkResult{name, checkSkipped, "no client ID and secret", ""}
+	case errors.Is(err, apierror.ErrUnauthorized):
+		return checkResult{name, checkFailed, "the client ID or secret was rejected (" + err.Error() + ")",
+			"Check the API key and secret of your app in the Amadeus developer portal, and that they are for the environment at " + cfg.Endpoints.AmadeusUrl + " (test and production keys are different)"}
+	case apierror.GetStatusCode(err) != 0:
+		return checkResult{name, checkFailed, err.Error(), "Amadeus may be having problems; try again later, or check https://developers.amadeus.com for service status"}
+	default:
+		return checkResult{name, checkFailed, err.Error(), "Check the network connection, and that endpoints.amadeus (" + cfg.Endpoints.AmadeusUrl + ") is correct"}
+	}
+}
+
+func checkAviationStack(cfg *config.Config, secrets credentials.Provider, usage *quota.Tracker, probe bool) checkResult {
+
+	const name = "AviationStack"
+
+	if !probe {
+		detail := "not checked, since each request uses up some of the monthly quota"
+		if !cfg.UsesAviationStack() {
+			detail = "not checked, and not used by the selected schedule sources"
+		}
+		return checkResult{name, checkSkipped, detail + " (use --probe-aviationstack to check the API key)", ""}
+	}
+
+	err := schedule.ProbeAviationStack(cfg.Endpoints.AviationStackUrl, secrets, usage, cfg.GetOrigin().Airports[0])
+	switch {
+	case err == nil:
+		return checkResult{name, checkPassed, "the API key was accepted by " + cfg.Endpoints.AviationStackUrl, ""}
+	case errors.Is(err, credentials.ErrNotFound):
+		return checkResult{name, checkSkipped, "no API key", ""}
+	case errors.Is(err, quota.ErrExceeded):
+		return checkResult{name, checkSkipped, "not checked, since " + err.Error(), ""}
+	case errors.Is(err, apierror.ErrUnauthorized):
+		return checkResult{name, checkFailed, "the API key was rejected (" + err.Error() + ")",
+			`Copy the access key from your AviationStack dashboard, and save it with "flynow credentials set aviationstack-api-key"`}
+	case errors.Is(err, apierror.ErrQuotaExceeded):
+		return checkResult{name, checkFailed, "the monthly quota has been used up (" + err.Error() + ")",
+			"Wait until the quota is reset next month, or use another schedule source (e.g. learned or timetable)"}
+	default:
+		return checkResult{name, checkFailed, err.Error(), "Check the network connection, and that endpoints.aviationStack (" + cfg.Endpoints.AviationStackUrl + ") is correct"}
+	}
+}
+
+func checkQuota(cfg *config.Config, usage *quota.Tracker) checkResult {
+
+	const name = "AviationStack quota"
+
+	current, err := usage.GetUsage()
+	if err != nil {
+		return checkResult{name, checkFailed, err.Error(), "Delete the quota file " + cfg.GetQuotaPath() + " to start counting again"}
+	}
+
+	if current.Limit == 0 {
+		return checkResult{name, checkPassed, fmt.Sprintf("%d requests made in %s (no limit)", current.Used, current.Month), ""}
+	}
+
+	detail := fmt.Sprintf("%d of %d requests used in %s", current.Used, current.Limit, current.Month)
+	switch {
+	case current.Used >= current.Limit && cfg.UsesAviationStack():
+		return checkResult{name, checkFailed, detail, "Wait until next month, use another schedule source (e.g. learned or timetable), or raise quota.aviationStackMonthly if your plan allows more requests"}
+	case float64(current.Used) >= quotaWarningLevel*float64(current.Limit):
+		return checkResult{name, checkWarning, detail, "Add a fallback schedule source (e.g. aviationstack,learned), so that searches keep working when the quota runs out"}
+	default:
+		return checkResult{name, checkPassed, detail, ""}
+	}
+}
+
+func checkClockSkew(client *amadeus.Client) checkResult {
+
+	const name = "Clock"
+
+	skew, known := client.GetClockSkew()
+	if !known {
+		return checkResult{name, checkSkipped, "not checked, since the Amadeus server time is unknown", ""}
+	}
+
+	if skew.Abs() > maxClockSkew {
+		direction := "behind"
+		if skew < 0 {
+			direction = "ahead of"
+		}
+		return checkResult{name, checkWarning, fmt.Sprintf("the local clock is %s %s the Amadeus server", skew.Abs().Round(time.Second), direction),
+			"Synchronize the system clock (e.g. enable NTP), since the searches are for flights departing today"}
+	}
+	return checkResult{name, checkPassed, fmt.Sprintf("within %s of the Amadeus server", maxClockSkew), ""}
+}
+
+// Lists the active providers, and checks that the files they need exist
+func checkProviders(cfg *config.Config) []checkResult {
+
+	mode := "first available"
+	if cfg.Schedule.Merge {
+		mode = "merged"
+	}
+
+	results := []checkResult{
+		{name: "Schedule providers", status: checkPassed, detail: fmt.Sprintf("%s (%s)", strings.Join(cfg.Schedule.Sources, ", "), mode)},
+		{name: "Price provider", status: checkPassed, detail: fmt.Sprintf("Amadeus flight offers at %s, with up to %d searches at a time", cfg.Endpoints.AmadeusUrl, cfg.Concurrency)},
+	}
+
+	files := map[string]string{config.FixtureSource: cfg.Schedule.Fixture, config.TimetableSource: cfg.Schedule.Timetable}
+	for _, source := range []string{config.FixtureSource, config.TimetableSource} {
+		if !slices.Contains(cfg.Schedule.Sources, source) {
+			continue
+		}
+		if _, err := os.Stat(files[source]); err != nil {
+			results = append(results, checkResult{"Schedule file", checkFailed, fmt.Sprintf("the %s source can't read %s: %v", source, files[source], err),
+				fmt.Sprintf("Correct schedule.%s, or remove %s from the schedule sources", source, source)})
+		}
+	}
+
+	return results
+}
+
+func capitalize(text string) string {
+
+	if text == "" {
+		return text
+	}
+	return strings.ToUpper(text[:1]) + text[1:]
+}
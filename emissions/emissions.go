@@ -0,0 +1,80 @@
+package emissions
+
+import "math"
+
+// Rough estimates of the CO2 emitted per economy passenger on a flight, following the approach of the ICAO
+// carbon emissions calculator in a simplified form. The fuel burnt per passenger-km mostly depends on the
+// distance, since the take-off and climb use the most fuel, and then on the type of aircraft. The estimates
+// are meant for comparing flights with each other, not for offsetting.
+
+// The CO2 per passenger-km for the flights up to each distance, in kg
+var distanceBands = []struct {
+	maxKm   float64
+	kgPerKm float64
+}{
+	{500, 0.17},
+	{1500, 0.13},
+	{4000, 0.11},
+	{math.Inf(1), 0.10},
+}
+
+// How much more (or less) fuel each type of aircraft burns per seat than a typical narrow-body jet of the
+// last generation (such as the Boeing 737-800 or Airbus A320), by IATA aircraft code. Any other aircraft
+// is taken to be typical.
+var aircraftFactors = map[string]float64{
+	// Turboprops, which are efficient on short routes
+	"AT4": 0.8, "AT5": 0.8, "AT7": 0.8, "ATR": 0.8, "DH4": 0.8, "DH8": 0.8,
+
+	// Regional jets
+	"CR7": 1.2, "CR9": 1.2, "CRK": 1.2, "E70": 1.2, "E75": 1.2, "E90": 1.2, "E95": 1.2, "290": 0.95, "295": 0.95,
+
+	// Narrow-body jets of the current generation
+	"221": 0.85, "223": 0.85, "31N": 0.85, "32N": 0.85, "32Q": 0.85, "7M7": 0.85, "7M8": 0.85, "7M9": 0.85,
+
+	// Older narrow-body jets
+	"717": 1.15, "733": 1.15, "734": 1.15, "735": 1.15, "M88": 1.15,
+
+	// Wide-body jets
+	"339": 0.9, "351": 0.9, "359": 0.9, "788": 0.9, "789": 0.9, "78X": 0.9,
+	"332": 1.1, "333": 1.1, "744": 1.1, "772": 1.1, "77W": 1.05,
+}
+
+// Gets the distance actually flown, which is longer than the great-circle distance because of the routing
+// and any holding, using the ICAO correction for each distance
+func GetFlownDistance(greatCircleKm float64) float64 {
+
+	switch {
+	case greatCircleKm < 550:
+		return greatCircleKm + 50
+	case greatCircleKm < 5500:
+		return greatCircleKm + 100
+	default:
+		return greatCircleKm + 125
+	}
+}
+
+// Gets the relative fuel burn of the given type of aircraft, where 1 is a typical narrow-body jet
+func GetAircraftFactor(aircraftCode string) float64 {
+
+	if factor, found := aircraftFactors[aircraftCode]; found {
+		return factor
+	}
+	return 1
+}
+
+// Estimates the CO2 emitted per economy passenger, in kg, for a flight of the given great-circle distance
+// on the given type of aircraft (or 0 if the distance isn't known)
+func Estimate(greatCircleKm float64, aircraftCode string) float64 {
+
+	if greatCircleKm <= 0 {
+		return 0
+	}
+
+	distance := GetFlownDistance(greatCircleKm)
+	for _, band := range distanceBands {
+		if distance <= band.maxKm {
+			return distance * band.kgPerKm * GetAircraftFactor(aircraftCode)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package emissions
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		distance float64
+		aircraft string
+		expected float64
+	}{
+		{"unknown distance", 0, "73H", 0},
+		{"short flight", 400, "73H", 76.5},
+		{"short flight on a turboprop", 400, "DH4", 61.2},
+		{"medium flight on a new jet", 1000, "32N", 121.55},
+		{"medium flight on an unknown aircraft", 1000, "XXX", 143},
+		{"long flight", 6000, "789", 551.25},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := Estimate(test.distance, test.aircraft); fmt.Sprintf("%.2f", actual) != fmt.Sprintf("%.2f", test.expected) {
+				t.Errorf("Estimate(%g, %s) returned %.2f kg; Expected %.2f kg", test.distance, test.aircraft, actual, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flynow/airports"
+	"flynow/pricing"
+	"flynow/schedule"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Runs the explain command, which performs the same search as the main command (without reconfirming the
+// prices), and prints every scheduled flight and offer which was considered, along with the rule which
+// accepted or rejected it:
+//
+//	flynow explain [search flags]
+func runExplain(args []string, in io.Reader, out io.Writer) error {
+
+	scheduleAudit := schedule.NewAudit()
+	search, err := setupSearch("flynow explain", args, in, out, scheduleAudit)
+	if err != nil {
+		return err
+	}
+	defer search.restore()
+
+	priceAudit := pricing.NewAudit()
+	search.options.Audit = priceAudit
+	origin := search.cfg.GetOrigin()
+
+	fmt.Fprintf(out, "Explaining the search for flights from %s departing today\n", origin)
+
+	routes, err := findDestinations(search)
+	if err != nil {
+		return err
+	}
+
+	// The routes are named by their destination alone, unless there are several origin airports
+	destinations := make([]string, 0)
+	providers := make(map[string][]string)
+	for _, route := range routes {
+		for _, dest := range route.destinations {
+			name := getRouteName(origin, route.origin, dest.Airport)
+			destinations = append(destinations, name)
+			providers[name] = dest.Providers
+		}
+	}
+
+	// A failed search is explained along with the rest, and the error is shown at the end, since it may have
+	// stopped some of the destinations from being searched at all (e.g. if authentication failed)
+	_, searchErr := findPrices(search, routes, nil)
+
+	printScheduleVerdicts(out, scheduleAudit.GetVerdicts())
+	reports := priceAudit.GetReports()
+	printDestinationReports(out, origin, reports, providers)
+	printExplainSummary(out, origin, destinations, reports)
+	if searchErr != nil {
+		fmt.Fprintf(out, "\nErrors\n  %s\n", strings.ReplaceAll(searchErr.Error(), "\n", "\n  "))
+	}
+
+	return nil
+}
+
+// Gets the name of a route for display, e.g. CPH, or TRF-CPH when searching from several airports
+func getRouteName(origin airports.Place, from string, to string) string {
+
+	if origin.IsGroup() {
+		return from + "-" + to
+	}
+	return to
+}
+
+// Prints the decision about each scheduled flight, for the sources which list individual flights
+func printScheduleVerdicts(out io.Writer, verdicts []schedule.FlightVerdict) {
+
+	fmt.Fprintln(out, "\nScheduled flights")
+	if len(verdicts) == 0 {
+		fmt.Fprintln(out, "  (none of the sources used list individual flights)")
+		return
+	}
+
+	for _, v := range verdicts {
+		outcome := "used"
+		if !v.Accepted {
+			outcome = "ignored"
+		}
+		fmt.Fprintf(out, "  %s\t%s\t%s-%s\t%s: %s\n", v.Source, v.FlightNumber, v.Origin, v.Destination, outcome, v.Reason)
+	}
+}
+
+// Prints every offer considered for each destination, and why it was accepted or rejected
+func printDestinationReports(out io.Writer, origin airports.Place, reports []pricing.DestinationReport, providers map[string][]string) {
+
+	fmt.Fprintln(out, "\nDestinations")
+	for _, report := range reports {
+		name := getRouteName(origin, report.Origin, report.Destination)
+		fmt.Fprintf(out, "%s (from %s)\n", name, strings.Join(providers[name], ", "))
+		if report.Problem != "" {
+			fmt.Fprintf(out, "  %s\n", report.Problem)
+		}
+		for _, offer := range report.Offers {
+			outcome := "accepted"
+			if !offer.Accepted {
+				outcome = "rejected"
+			}
+			fmt.Fprintf(out, "  offer %s\t%s\t%s\t%s\t%s: %s\n", offer.OfferId, offer.FlightNumber, offer.Route, offer.Price, outcome, offer.Reason)
+		}
+	}
+}
+
+// Prints how many of the destinations from the schedule ended up with a price, and why the others didn't
+func printExplainSummary(out io.Writer, origin airports.Place, destinations []string, reports []pricing.DestinationReport) {
+
+	priced := make([]string, 0)
+	unpriced := make([]string, 0)
+	reported := make(map[string]bool)
+	for _, report := range reports {
+		name := getRouteName(origin, report.Origin, report.Destination)
+		reported[name] = true
+		if reason := getUnpricedReason(report); reason != "" {
+			unpriced = append(unpriced, fmt.Sprintf("%s: %s", name, reason))
+		} else {
+			priced = append(priced, name)
+		}
+	}
+
+	// A destination without a report was never searched, e.g. because the price search failed before it
+	for _, name := range destinations {
+		if !reported[name] {
+			unpriced = append(unpriced, fmt.Sprintf("%s: not searched", name))
+		}
+	}
+
+	fmt.Fprintln(out, "\nSummary")
+	fmt.Fprintf(out, "  Schedule destinations: %d (%s)\n", len(destinations), strings.Join(destinations, ", "))
+	fmt.Fprintf(out, "  Priced destinations:   %d (%s)\n", len(priced), strings.Join(priced, ", "))
+	fmt.Fprintf(out, "  Without a price:       %d\n", len(unpriced))
+	for _, reason := range unpriced {
+		fmt.Fprintf(out, "    %s\n", reason)
+	}
+}
+
+// Gets the reason a destination has no price, or an empty string if it has one
+func getUnpricedReason(report pricing.DestinationReport) string {
+
+	if report.Problem != "" {
+		return report.Problem
+	}
+	for _, offer := range report.Offers {
+		if offer.Accepted {
+			return ""
+		}
+	}
+	return fmt.Sprintf("none of the %d offers met the criteria", len(report.Offers))
+}
@@ -0,0 +1,397 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"flynow/airports"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fake of the Amadeus self-service APIs used by the application
+type Amadeus struct {
+	// Base URL of the fake server, to be used in place of the real API
+	Url string
+
+	// The OAuth token endpoint
+	Token Endpoint
+
+	// The flight offers search endpoint
+	Offers Endpoint
+
+	// The flight offers price endpoint, which confirms the offers exactly as they were sent
+	Pricing Endpoint
+
+	// The airport routes (direct destinations) endpoint
+	Routes Endpoint
+
+	// Status codes to answer the searches for the given destinations with, e.g. {"ARN": 500}
+	FailingDestinations map[string]int
+
+	mutex  sync.Mutex
+	offers []Offer
+	routes map[string][]string
+}
+
+// The error returned by the flight offers price endpoint for an offer which can no longer be booked, for
+// use as the Body of the Pricing endpoint
+const OfferUnavailableError = `{"errors": [{"status": 400, "code": 34651, "title": "SEGMENT SELL FAILURE", "detail": "Could not sell segment 1"}]}`
+
+// The Bearer token issued by the fake, which must be sent with all other requests
+const AccessToken = "fake-access-token"
+
+// A flight offer for a direct flight, which is returned in the Amadeus format
+type Offer struct {
+	Airline     string
+	Number      string
+	Origin      string
+	Destination string
+	Departure   time.Time
+	Arrival     time.Time
+	Total       float64
+	Currency    string
+
+	// Optional fare details
+	Brand           string
+	IncludedBags    int
+	CheckedBagPrice float64
+
+	// IATA aircraft code; the default is 73H (Boeing 737-800)
+	Aircraft string
+}
+
+// Names of the aircraft codes, for the dictionaries in the search results
+var aircraftNames = map[string]string{
+	"73H": "BOEING 737-800 (WINGLETS)",
+	"32N": "AIRBUS A320NEO",
+	"DH4": "DE HAVILLAND DHC-8 400 SERIES",
+}
+
+// Starts a fake Amadeus server, which is closed when the test finishes. No offers or routes are set up,
+// so every search returns an empty result until some are added.
+func NewAmadeus(t testing.TB) *Amadeus {
+
+	fake := Amadeus{offers: make([]Offer, 0), routes: make(map[string][]string)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/security/oauth2/token", fake.handleToken)
+	mux.HandleFunc("/v2/shopping/flight-offers", fake.handleOffers)
+	mux.HandleFunc("/v1/shopping/flight-offers/pricing", fake.handlePricing)
+	mux.HandleFunc("/v1/airport/direct-destinations", fake.handleRoutes)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	fake.Url = server.URL
+	return &fake
+}
+
+// Creates an offer for a direct flight, with the fare split into base price and taxes as Amadeus does
+func NewOffer(airline string, number string, origin string, destination string, departure time.Time, duration time.Duration, total float64, currency string) Offer {
+
+	return Offer{
+		Airline:     airline,
+		Number:      number,
+		Origin:      origin,
+		Destination: destination,
+		Departure:   departure,
+		Arrival:     departure.Add(duration),
+		Total:       total,
+		Currency:    currency,
+	}
+}
+
+// Adds offers, which are returned by searches for the same route
+func (fake *Amadeus) AddOffers(offers ...Offer) {
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.offers = append(fake.offers, offers...)
+}
+
+// Adds direct destinations from the given airport, which are returned by the airport routes endpoint
+func (fake *Amadeus) AddRoutes(origin string, destinations ...string) {
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.routes[origin] = append(fake.routes[origin], destinations...)
+}
+
+func (fake *Amadeus) handleToken(w http.ResponseWriter, r *http.Request) {
+
+	fake.Token.serve(w, r, amadeusTokenError, func() (int, any) {
+
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" {
+			return http.StatusBadRequest, amadeusTokenError(http.StatusBadRequest)
+		}
+
+		return http.StatusOK, map[string]any{
+			"type":         "amadeusOAuth2Token",
+			"token_type":   "Bearer",
+			"access_token": AccessToken,
+			"expires_in":   1799,
+			"state":        "approved",
+		}
+	})
+}
+
+func (fake *Amadeus) handleOffers(w http.ResponseWriter, r *http.Request) {
+
+	fake.Offers.serve(w, r, amadeusError, func() (int, any) {
+
+		if !isAuthorized(r) {
+			return http.StatusUnauthorized, amadeusError(http.StatusUnauthorized)
+		}
+
+		query := r.URL.Query()
+		origin := query.Get("originLocationCode")
+		destination := query.Get("destinationLocationCode")
+		for parameter, code := range map[string]string{"originLocationCode": origin, "destinationLocationCode": destination} {
+			if !isAirportCode(code) {
+				return http.StatusBadRequest, amadeusParameterError(http.StatusBadRequest, parameter)
+			}
+		}
+		if code, failing := fake.FailingDestinations[destination]; failing {
+			return code, amadeusError(code)
+		}
+		included := splitCodes(query.Get("includedAirlineCodes"))
+		excluded := splitCodes(query.Get("excludedAirlineCodes"))
+
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+
+		data := make([]map[string]any, 0)
+		aircraft := make(map[string]string)
+		for _, offer := range fake.offers {
+			if offer.Origin != origin || offer.Destination != destination {
+				continue
+			}
+			if (len(included) > 0 && !slices.Contains(included, offer.Airline)) || slices.Contains(excluded, offer.Airline) {
+				continue
+			}
+			data = append(data, offer.toAmadeus(len(data)+1))
+			aircraft[offer.getAircraft()] = aircraftNames[offer.getAircraft()]
+		}
+
+		return http.StatusOK, map[string]any{
+			"meta":         map[string]any{"count": len(data)},
+			"data":         data,
+			"dictionaries": map[string]any{"aircraft": aircraft},
+		}
+	})
+}
+
+func (fake *Amadeus) handlePricing(w http.ResponseWriter, r *http.Request) {
+
+	fake.Pricing.serve(w, r, amadeusError, func() (int, any) {
+
+		if !isAuthorized(r) {
+			return http.StatusUnauthorized, amadeusError(http.StatusUnauthorized)
+		}
+		if r.Method != http.MethodPost || r.Header.Get("X-HTTP-Method-Override") != http.MethodGet {
+			return http.StatusMethodNotAllowed, amadeusError(http.StatusMethodNotAllowed)
+		}
+
+		type pricingData struct {
+			Type   string            `json:"type"`
+			Offers []json.RawMessage `json:"flightOffers"`
+		}
+		var request struct {
+			Data pricingData `json:"data"`
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil || json.Unmarshal(body, &request) != nil || len(request.Data.Offers) == 0 {
+			return http.StatusBadRequest, amadeusError(http.StatusBadRequest)
+		}
+
+		return http.StatusOK, map[string]any{
+			"data": pricingData{Type: "flight-offers-pricing", Offers: request.Data.Offers},
+		}
+	})
+}
+
+func (fake *Amadeus) handleRoutes(w http.ResponseWriter, r *http.Request) {
+
+	fake.Routes.serve(w, r, amadeusError, func() (int, any) {
+
+		if !isAuthorized(r) {
+			return http.StatusUnauthorized, amadeusError(http.StatusUnauthorized)
+		}
+
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+
+		data := make([]map[string]any, 0)
+		for _, destination := range fake.routes[r.URL.Query().Get("departureAirportCode")] {
+			data = append(data, map[string]any{
+				"type":     "location",
+				"subtype":  "city",
+				"name":     destination,
+				"iataCode": destination,
+			})
+		}
+
+		return http.StatusOK, map[string]any{
+			"meta": map[string]any{"count": len(data)},
+			"data": data,
+		}
+	})
+}
+
+// Converts the offer to the Amadeus format, with the given offer ID
+func (offer Offer) toAmadeus(id int) map[string]any {
+
+	const layout = "2006-01-02T15:04:05"
+	const taxRate = 0.25
+
+	base := offer.Total / (1 + taxRate)
+	amount := func(value float64) string {
+		return fmt.Sprintf("%.2f", value)
+	}
+
+	additionalServices := make([]map[string]any, 0)
+	if offer.CheckedBagPrice > 0 {
+		additionalServices = append(additionalServices, map[string]any{"amount": amount(offer.CheckedBagPrice), "type": "CHECKED_BAGS"})
+	}
+
+	fareDetails := map[string]any{
+		"segmentId":           "1",
+		"cabin":               "ECONOMY",
+		"fareBasis":           "FAKEFARE",
+		"class":               "Y",
+		"includedCheckedBags": map[string]any{"quantity": offer.IncludedBags},
+	}
+	if offer.Brand != "" {
+		fareDetails["brandedFare"] = offer.Brand
+		fareDetails["brandedFareLabel"] = offer.Brand
+	}
+
+	return map[string]any{
+		"type":                  "flight-offer",
+		"id":                    fmt.Sprint(id),
+		"source":                "GDS",
+		"lastTicketingDate":     offer.Departure.Format(time.DateOnly),
+		"numberOfBookableSeats": 9,
+		"itineraries": []map[string]any{{
+			"duration": fmt.Sprintf("PT%dM", int(offer.Arrival.Sub(offer.Departure).Minutes())),
+			"segments": []map[string]any{{
+				"id":            "1",
+				"departure":     map[string]any{"iataCode": offer.Origin, "at": offer.Departure.In(airports.GetLocation(offer.Origin)).Format(layout)},
+				"arrival":       map[string]any{"iataCode": offer.Destination, "at": offer.Arrival.In(airports.GetLocation(offer.Destination)).Format(layout)},
+				"carrierCode":   offer.Airline,
+				"number":        offer.Number,
+				"aircraft":      map[string]any{"code": offer.getAircraft()},
+				"numberOfStops": 0,
+			}},
+		}},
+		"price": map[string]any{
+			"currency":           offer.Currency,
+			"total":              amount(offer.Total),
+			"base":               amount(base),
+			"fees":               []map[string]any{{"amount": "0.00", "type": "SUPPLIER"}, {"amount": "0.00", "type": "TICKETING"}},
+			"grandTotal":         amount(offer.Total),
+			"additionalServices": additionalServices,
+		},
+		"validatingAirlineCodes": []string{offer.Airline},
+		"travelerPricings": []map[string]any{{
+			"travelerId":           "1",
+			"fareOption":           "STANDARD",
+			"travelerType":         "ADULT",
+			"price":                map[string]any{"currency": offer.Currency, "total": amount(offer.Total), "base": amount(base)},
+			"fareDetailsBySegment": []map[string]any{fareDetails},
+		}},
+	}
+}
+
+func (offer Offer) getAircraft() string {
+
+	if offer.Aircraft == "" {
+		return "73H"
+	}
+	return offer.Aircraft
+}
+
+func isAuthorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer "+AccessToken
+}
+
+func splitCodes(value string) []string {
+
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// Gets an error response in the Amadeus format
+func amadeusError(status int) any {
+	return amadeusParameterError(status, "")
+}
+
+// Gets an error response in the Amadeus format, for an invalid request parameter if one is given
+func amadeusParameterError(status int, parameter string) any {
+
+	type errorSource struct {
+		Parameter string `json:"parameter,omitempty"`
+	}
+	type errorDetails struct {
+		Status int          `json:"status"`
+		Code   int          `json:"code"`
+		Title  string       `json:"title"`
+		Detail string       `json:"detail"`
+		Source *errorSource `json:"source,omitempty"`
+	}
+
+	codes := map[int]int{
+		http.StatusBadRequest:      477,
+		http.StatusUnauthorized:    38190,
+		http.StatusNotFound:        38196,
+		http.StatusTooManyRequests: 38194,
+	}
+
+	details := errorDetails{Status: status, Code: codes[status], Title: strings.ToUpper(http.StatusText(status)), Detail: http.StatusText(status)}
+	if parameter != "" {
+		details.Title = "INVALID FORMAT"
+		details.Detail = "Invalid airport code"
+		details.Source = &errorSource{Parameter: parameter}
+	}
+
+	return struct {
+		Errors []errorDetails `json:"errors"`
+	}{[]errorDetails{details}}
+}
+
+// Gets an error response in the OAuth format used by the token endpoint, where rejected credentials are
+// reported as invalid_client
+func amadeusTokenError(status int) any {
+
+	response := map[string]any{"error": "invalid_request", "error_description": http.StatusText(status), "code": 38189, "title": "Invalid parameters"}
+	if status == http.StatusUnauthorized {
+		response = map[string]any{"error": "invalid_client", "error_description": "Client credentials are invalid", "code": 38187, "title": "Invalid parameters"}
+	}
+
+	return response
+}
+
+// Checks for a 3-letter IATA airport code
+func isAirportCode(code string) bool {
+
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
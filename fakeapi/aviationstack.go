@@ -0,0 +1,145 @@
+package fakeapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fake of the AviationStack realtime flights API
+type AviationStack struct {
+	// Base URL of the fake server, to be used in place of the real API
+	Url string
+
+	// The /v1/flights endpoint
+	Flights Endpoint
+
+	mutex   sync.Mutex
+	flights []Flight
+}
+
+// A scheduled flight, in the format returned by the AviationStack flights endpoint
+type Flight struct {
+	Date      string     `json:"flight_date"`
+	Status    string     `json:"flight_status"`
+	Departure FlightTime `json:"departure"`
+	Arrival   FlightTime `json:"arrival"`
+	Airline   FlightCode `json:"airline"`
+	Number    FlightCode `json:"flight"`
+}
+
+// Airport and scheduled time of a departure or arrival
+type FlightTime struct {
+	Airport string `json:"iata"`
+	Time    string `json:"scheduled"`
+}
+
+// IATA code of an airline or flight
+type FlightCode struct {
+	Code string `json:"iata"`
+}
+
+// Starts a fake AviationStack server, which is closed when the test finishes
+func NewAviationStack(t testing.TB) *AviationStack {
+
+	fake := AviationStack{flights: make([]Flight, 0)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/flights", fake.handleFlights)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	fake.Url = server.URL
+	return &fake
+}
+
+// Creates a flight in the "scheduled" state. As with the real API, the local departure and arrival times
+// are given as if they were UTC.
+func NewFlight(airline string, number string, origin string, destination string, departure time.Time, duration time.Duration) Flight {
+
+	const layout = "2006-01-02T15:04:05+00:00"
+	arrival := departure.Add(duration)
+
+	return Flight{
+		Date:      departure.Format(time.DateOnly),
+		Status:    "scheduled",
+		Departure: FlightTime{Airport: origin, Time: departure.Format(layout)},
+		Arrival:   FlightTime{Airport: destination, Time: arrival.Format(layout)},
+		Airline:   FlightCode{Code: airline},
+		Number:    FlightCode{Code: airline + number},
+	}
+}
+
+// Adds flights to the schedule. Requests return the scheduled flights from the requested airport.
+func (fake *AviationStack) AddFlights(flights ...Flight) {
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.flights = append(fake.flights, flights...)
+}
+
+func (fake *AviationStack) handleFlights(w http.ResponseWriter, r *http.Request) {
+
+	fake.Flights.serve(w, r, aviationStackError, func() (int, any) {
+
+		if r.URL.Query().Get("dep_iata") == "" {
+			return http.StatusUnprocessableEntity, aviationStackError(http.StatusUnprocessableEntity)
+		}
+
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+
+		origin := r.URL.Query().Get("dep_iata")
+		status := r.URL.Query().Get("flight_status")
+		flights := make([]Flight, 0)
+		for _, flight := range fake.flights {
+			if flight.Departure.Airport == origin && (status == "" || flight.Status == status) {
+				flights = append(flights, flight)
+			}
+		}
+
+		type pagination struct {
+			Limit  int `json:"limit"`
+			Offset int `json:"offset"`
+			Count  int `json:"count"`
+			Total  int `json:"total"`
+		}
+		response := struct {
+			Page    pagination `json:"pagination"`
+			Flights []Flight   `json:"data"`
+		}{
+			Page:    pagination{Limit: 100, Count: len(flights), Total: len(flights)},
+			Flights: flights,
+		}
+
+		return http.StatusOK, response
+	})
+}
+
+// Gets an error response in the AviationStack format
+func aviationStackError(status int) any {
+
+	codes := map[int]string{
+		http.StatusUnauthorized:        "invalid_access_key",
+		http.StatusForbidden:           "function_access_restricted",
+		http.StatusNotFound:            "invalid_api_function",
+		http.StatusUnprocessableEntity: "validation_error",
+		http.StatusTooManyRequests:     "usage_limit_reached",
+	}
+	code, found := codes[status]
+	if !found {
+		code = "internal_error"
+	}
+
+	type errorDetails struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	return struct {
+		Error errorDetails `json:"error"`
+	}{errorDetails{Code: code, Message: http.StatusText(status)}}
+}
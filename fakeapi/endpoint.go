@@ -0,0 +1,131 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// In-process fakes of the AviationStack and Amadeus APIs, for testing the whole application without any
+// network access or API quota. Each fake is an httptest server, whose base URL can be passed to the
+// clients in place of the real one. The data returned by each endpoint can be set up by the test, and
+// each endpoint can be made to fail in the ways the real APIs do (error codes, rate limiting, slow
+// responses and malformed payloads).
+
+// The behavior of a single fake endpoint. By default, the endpoint responds with the data set up in the
+// fake server, but this can be overridden to test the error handling.
+type Endpoint struct {
+	mutex sync.Mutex
+
+	// If set (to anything other than 200), every request is answered with this status code
+	StatusCode int
+
+	// If set, every request is answered with this body, instead of the data set up in the fake server
+	Body string
+
+	// Delay before each response is sent
+	Latency time.Duration
+
+	// The number of upcoming requests to be rejected with 429 Too Many Requests, before the endpoint
+	// starts responding normally again
+	RateLimited int
+
+	// If set, the JSON response is cut off half-way through
+	Malformed bool
+
+	// Difference between the time in the Date header and the actual time, as if the server's clock was wrong
+	ClockSkew time.Duration
+
+	requests int
+}
+
+// Gets the number of requests which have been made to the endpoint, including any rejected ones
+func (endpoint *Endpoint) Requests() int {
+
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+
+	return endpoint.requests
+}
+
+// Sets the number of upcoming requests to be rejected with 429 Too Many Requests. This can be called
+// while the server is in use, unlike setting the RateLimited field directly.
+func (endpoint *Endpoint) SetRateLimited(count int) {
+
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+
+	endpoint.RateLimited = count
+}
+
+// Responds to the request according to the configured behavior, using the given function to get the
+// response data for a normal response. The error bodies are in the format used by the API.
+func (endpoint *Endpoint) serve(w http.ResponseWriter, r *http.Request, errorBody func(status int) any, getData func() (int, any)) {
+
+	endpoint.mutex.Lock()
+	endpoint.requests++
+	rateLimited := endpoint.RateLimited > 0
+	if rateLimited {
+		endpoint.RateLimited--
+	}
+	statusCode, body, latency, malformed := endpoint.StatusCode, endpoint.Body, endpoint.Latency, endpoint.Malformed
+	clockSkew := endpoint.ClockSkew
+	endpoint.mutex.Unlock()
+
+	if clockSkew != 0 {
+		w.Header().Set("Date", time.Now().Add(clockSkew).UTC().Format(http.TimeFormat))
+	}
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if rateLimited {
+		writeJson(w, http.StatusTooManyRequests, errorBody(http.StatusTooManyRequests), false)
+		return
+	}
+
+	if statusCode != 0 && statusCode != http.StatusOK {
+		if body != "" {
+			writeRaw(w, statusCode, []byte(body), malformed)
+		} else {
+			writeJson(w, statusCode, errorBody(statusCode), malformed)
+		}
+		return
+	}
+
+	if body != "" {
+		writeRaw(w, http.StatusOK, []byte(body), malformed)
+		return
+	}
+
+	status, data := getData()
+	writeJson(w, status, data, malformed)
+}
+
+func writeJson(w http.ResponseWriter, status int, data any, malformed bool) {
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeRaw(w, status, body, malformed)
+}
+
+func writeRaw(w http.ResponseWriter, status int, body []byte, malformed bool) {
+
+	if malformed {
+		body = body[:len(body)/2]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -1,64 +1,459 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
+	"flynow/airports"
+	"flynow/amadeus"
+	"flynow/apierror"
+	"flynow/cassette"
+	"flynow/config"
+	"flynow/credentials"
 	"flynow/pricing"
+	"flynow/quota"
 	"flynow/schedule"
+	"flynow/transport"
+	"flynow/where"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"sort"
+	"slices"
 	"strings"
+	"time"
 )
 
 func main() {
 
-	// TODO: Pass in these values (e.g. as part of a REST call)
-	const origin = "OSL"
-	const currency = "NOK"
-	const orderBy = "price"
-
-	fmt.Printf("Searching for potential destinations from %s...\n", origin)
-
-	// Get a list of destination airports, based on real-time scheduled flights
-	scheduleClient := schedule.GetClient()
-	destinations, err := scheduleClient.GetScheduledDestinations(origin)
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		fmt.Println(err)
+		if hint := getErrorHint(err); hint != "" {
+			fmt.Println(hint)
+		}
 		os.Exit(1)
 	}
+}
+
+// Gets a suggestion for what to do about a failure reported by one of the APIs, or the position of the mistake
+// in a --where expression, if there is one
+func getErrorHint(err error) string {
+
+	switch {
+	case errors.Is(err, apierror.ErrUnauthorized):
+		return `The API credentials were rejected; run "flynow doctor" to check them`
+	case errors.Is(err, apierror.ErrQuotaExceeded):
+		return "The monthly AviationStack quota has been used up; add a fallback schedule source (e.g. --schedule-source aviationstack,learned)"
+	case errors.Is(err, apierror.ErrRateLimited):
+		return "The API rate limit was exceeded; try again later, or lower --concurrency"
+	case errors.Is(err, apierror.ErrInvalidAirport):
+		return "An airport code was rejected; check that --origin and --destination are valid airports"
+	}
 
-	fmt.Println("\nSearching for flights departing today to:")
-	fmt.Println(strings.Join(destinations, ","))
+	// Show where the mistake in a --where expression is
+	var syntaxErr *where.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Pointer()
+	}
+	return ""
+}
 
-	// Perform a series of flight searches to find the cheapest option for each of the possible destinations
-	flightOptions, err := pricing.FindPrices(origin, destinations, currency)
+// Runs the application with the given command-line arguments, reading any input (such as a passphrase) from
+// the given reader and writing the output to the given writer
+func run(args []string, in io.Reader, out io.Writer) error {
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "airport":
+			return runAirport(args[1:], out)
+		case "credentials":
+			return runCredentials(args[1:], in, out)
+		case "doctor":
+			return runDoctor(args[1:], in, out)
+		case "explain":
+			return runExplain(args[1:], in, out)
+		}
+	}
+
+	// TODO: Pass in this value (e.g. as part of a REST call)
+	const reconfirmCount = 3
+
+	search, err := setupSearch("flynow", args, in, out, nil)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
+	}
+	defer search.restore()
+
+	// When searching the airports near home, getting to the airport is part of the cost
+	nearby := search.cfg.Nearby.Radius > 0
+	ranking := search.cfg.GetRanking()
+	priceClient, searchOptions := search.priceClient, search.options
+
+	fmt.Fprintf(out, "Searching for potential destinations from %s...\n", search.cfg.GetOrigin())
+
+	// Get a list of destination airports for each origin airport, based on real-time scheduled flights
+	routes, err := findDestinations(search)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nSearching for flights departing today to:")
+	printRoutes(out, routes)
+
+	// Perform a series of flight searches to find the cheapest option for each of the possible destinations,
+	// showing each one as it finishes, along with the best flights so far
+	total := 0
+	for _, route := range routes {
+		total += len(route.destinations)
+	}
+	condition, err := search.cfg.GetCondition()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nSearching for prices to %d destinations...\n", total)
+	// A failed search for some of the destinations still leaves the flights found for the others, so the
+	// results are shown before the error is returned
+	flightOptions, searchErr := findPrices(search, routes, newProgress(out, total, ranking, condition).report)
+	if searchErr != nil && len(flightOptions) == 0 {
+		return searchErr
+	}
+
+	// Keep only the flights which match the --where expression, before spending any requests on reconfirming them
+	if condition != nil {
+		found := len(flightOptions)
+		if flightOptions, err = condition.Filter(flightOptions, time.Now()); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\n%d of %d flights match %s\n", len(flightOptions), found, search.cfg.Where)
 	}
 
-	// Sort by the desired field
-	switch orderBy {
-	case "price":
-		sort.Sort(pricing.ByPrice(flightOptions))
-	case "time":
-		sort.Sort(pricing.ByTime(flightOptions))
-	case "dest":
-		sort.Sort(pricing.ByDest(flightOptions))
+	// Sort by the configured keys
+	ranking.Sort(flightOptions)
+
+	// The search prices are cached, so reconfirm the best few before choosing a winner
+	if n := min(reconfirmCount, len(flightOptions)); n > 0 {
+		fmt.Fprintf(out, "\nReconfirming the prices of the best %d flights...\n", n)
+		confirmed, err := priceClient.ReconfirmPrices(flightOptions[:n], searchOptions)
+		if err != nil {
+			fmt.Fprintln(out, err)
+		}
+		copy(flightOptions, confirmed)
+		ranking.Sort(flightOptions)
 	}
 
 	// Output the results
-	fmt.Println("\nFound the following flights")
-	printResults(flightOptions)
+	fmt.Fprintln(out, "\nFound the following flights")
+	printResults(out, flightOptions)
+
+	if dimensions := search.cfg.GetParetoDimensions(); len(dimensions) > 0 {
+		printTradeOffs(out, flightOptions, dimensions)
+	}
+
+	if nearby {
+		fmt.Fprintln(out, "\nDoor to door")
+		for _, f := range flightOptions {
+			fmt.Fprintln(out, f.GetDoorToDoorString())
+		}
+	}
+
+	for _, f := range flightOptions {
+		if f.Confirmation.Status != pricing.OfferUnavailable {
+			fmt.Fprintln(out, "\nBest option:")
+			fmt.Fprintln(out, f.GetMultilineString())
+			break
+		}
+	}
+
+	return searchErr
+}
+
+// The clients and options for a search, as set up from the command line
+type search struct {
+	cfg            *config.Config
+	scheduleClient schedule.ScheduleClient
+	priceClient    *pricing.Client
+	options        pricing.SearchOptions
+
+	// Restores the logging and HTTP transport once the search is done
+	restore func()
+}
+
+// Parses the command line of a search (which is shared by the search itself and the explain command), and
+// sets up the clients. If an audit is given, the decision about each scheduled flight is recorded in it.
+func setupSearch(name string, args []string, in io.Reader, out io.Writer, audit *schedule.Audit) (*search, error) {
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	record := flags.String("record", "", "save every API request and response in the given cassette `directory`")
+	replay := flags.String("replay", "", "serve every API request from the given cassette `directory`, without any network access")
+	logging := registerLogFlags(flags)
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg, err := configFlags.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Replayed requests don't need the real secrets, since they are never recorded
+	var secrets credentials.Provider = getCredentialProvider(cfg, getPassphraseReader(bufio.NewReader(in), out))
+	if *replay != "" {
+		secrets = credentials.Static{
+			credentials.AviationStackApiKey: "REDACTED",
+			credentials.AmadeusClientId:     "REDACTED",
+			credentials.AmadeusClientSecret: "REDACTED",
+		}
+	}
+	if err := credentials.Check(secrets, cfg.GetRequiredCredentials()...); err != nil {
+		return nil, fmt.Errorf("checking credentials: %w", err)
+	}
+
+	// Fare and airline preferences, which have already been validated with the rest of the config
+	fareFilter := cfg.GetFareFilter()
+	airlineFilter := cfg.GetAirlineFilter()
+
+	// The destination filters can only be checked here, since the schedule package depends on the config
+	destinationFilters, err := schedule.NewDestinationFilters(cfg.Pipeline.Destinations, airlineFilter)
+	if err != nil {
+		return nil, fmt.Errorf("pipeline.destinations: %w", err)
+	}
+	offerFilters, enrichers := cfg.GetPricingStages()
+
+	// The transport must be set up before the clients are created, since they keep using the same one. The
+	// original transport is kept, since the logging only restores the one it replaced (e.g. the cassette).
+	originalTransport := transport.Current()
+	if err := useCassette(out, *record, *replay); err != nil {
+		return nil, err
+	}
+	restoreLogging := logging.setup()
+	restore := func() {
+		restoreLogging()
+		transport.Use(originalTransport)
+	}
+
+	// The database doesn't include every airport, so an unknown origin is still searched
+	for _, airport := range cfg.GetOrigin().Airports {
+		if err := airports.Validate(airport); err != nil {
+			slog.Warn("The origin isn't in the airport database, so it may not be valid", "origin", airport)
+		}
+	}
+
+	// The Amadeus client is shared by the schedule and price searches, so that they share the token and rate limit
+	amadeusClient := amadeus.NewClient(cfg.Endpoints.AmadeusUrl, secrets)
+	priceClient := pricing.NewClient(amadeusClient, cfg.Concurrency)
+	endpoints := schedule.Endpoints{
+		AviationStackUrl: cfg.Endpoints.AviationStackUrl,
+		Amadeus:          amadeusClient,
+		Credentials:      secrets,
+		Audit:            audit,
+	}
+
+	// Replayed requests don't use up any quota
+	if *replay == "" {
+		endpoints.AviationStackQuota = quota.NewTracker(cfg.GetQuotaPath(), config.AviationStackSource, cfg.Quota.AviationStackMonthly)
+	}
+
+	scheduleClient, err := schedule.GetClient(destinationFilters, cfg.Schedule, endpoints)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+
+	return &search{
+		cfg:            cfg,
+		scheduleClient: scheduleClient,
+		priceClient:    priceClient,
+		options: pricing.SearchOptions{
+			Airlines:     airlineFilter,
+			CheckedBags:  cfg.CheckedBags,
+			Fares:        fareFilter,
+			OfferFilters: offerFilters,
+			Enrichers:    enrichers,
+		},
+		restore: restore,
+	}, nil
+}
+
+// Sets up the HTTP transport to record to, or replay from, a cassette directory, if either has been requested
+func useCassette(out io.Writer, recordDir string, replayDir string) error {
+
+	switch {
+	case recordDir != "" && replayDir != "":
+		return errors.New("--record and --replay can't be used together")
+
+	case recordDir != "":
+		recorder, err := cassette.NewRecorder(recordDir, transport.Current())
+		if err != nil {
+			return err
+		}
+		transport.Use(recorder)
+		fmt.Fprintf(out, "Recording API requests to %s\n", recordDir)
+
+	case replayDir != "":
+		replayer, err := cassette.NewReplayer(replayDir)
+		if err != nil {
+			return err
+		}
+		transport.Use(replayer)
+		fmt.Fprintf(out, "Replaying API requests from %s\n", replayDir)
+	}
+
+	return nil
+}
+
+// The destinations to search from one of the origin's airports
+type originDestinations struct {
+	origin       string
+	destinations []schedule.Destination
+}
+
+// Finds the destinations to search from each of the origin's airports. When a destination has been given,
+// its airports are searched directly, without using the schedule sources (or their quota).
+func findDestinations(search *search) ([]originDestinations, error) {
+
+	origin := search.cfg.GetOrigin()
+	destination, hasDestination := search.cfg.GetDestination()
+
+	routes := make([]originDestinations, 0, len(origin.Airports))
+	for _, airport := range origin.Airports {
+		var destinations []schedule.Destination
+		if hasDestination {
+			destinations = make([]schedule.Destination, 0, len(destination.Airports))
+			for _, code := range destination.Airports {
+				destinations = append(destinations, schedule.Destination{Airport: code, Providers: []string{"destination"}})
+			}
+		} else {
+			var err error
+			if destinations, err = schedule.GetSourcedDestinations(airport, search.scheduleClient, "schedule"); err != nil {
+				return nil, err
+			}
+		}
+
+		// A flight between two of the origin's airports is no escape
+		destinations = slices.DeleteFunc(destinations, func(d schedule.Destination) bool { return origin.Contains(d.Airport) })
+		routes = append(routes, originDestinations{origin: airport, destinations: destinations})
+	}
+
+	return routes, nil
+}
+
+// Searches for the cheapest flight to each destination from each of the origin's airports, keeping only the
+// cheapest journey to each destination (including the transfer to the airport, if one is configured). The
+// searches for the other destinations go ahead when one fails. If a function is given, it is called with the
+// outcome for each destination as soon as its search finishes.
+func findPrices(search *search, routes []originDestinations, onResult func(result pricing.DestinationResult)) ([]pricing.FlightForPurchase, error) {
+
+	// Any of the origin's airports is accepted, whichever one was searched from
+	options := search.options
+	options.NearbyOrigins = search.cfg.GetOrigin().Airports
+
+	results := make([]pricing.FlightForPurchase, 0)
+	var errs []error
+	for _, route := range routes {
+		destinations := make([]string, 0, len(route.destinations))
+		for _, dest := range route.destinations {
+			destinations = append(destinations, dest.Airport)
+		}
+
+		err := search.priceClient.StreamPrices(route.origin, destinations, search.cfg.Currency, options, func(result pricing.DestinationResult) bool {
+
+			// The transfer depends on the airport the flight leaves from, which may not be the one searched
+			if result.Found {
+				transfer := search.cfg.GetTransfer(result.Flight.Origin)
+				result.Flight.Transfer = pricing.GroundTransfer{Duration: time.Duration(transfer.Minutes) * time.Minute, Cost: float32(transfer.Cost)}
+				results = append(results, result.Flight)
+			}
+			if result.Err != nil {
+				errs = append(errs, result.Err)
+			}
+			if onResult != nil {
+				onResult(result)
+			}
+			return true
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return pricing.KeepCheapest(results), errors.Join(errs...)
+}
+
+// Prints the destinations to search, under each origin airport if there are several
+func printRoutes(out io.Writer, routes []originDestinations) {
+
+	for _, route := range routes {
+		if len(routes) > 1 {
+			fmt.Fprintf(out, "From %s:\n", route.origin)
+			if len(route.destinations) == 0 {
+				fmt.Fprintln(out, "(none)")
+			}
+		}
+		printDestinations(out, route.destinations)
+	}
+}
+
+// Print the destinations grouped by the provider which supplied them
+func printDestinations(out io.Writer, sourcedDestinations []schedule.Destination) {
+
+	byProvider := make(map[string][]string)
+	providers := make([]string, 0)
+	destinations := make([]string, 0, len(sourcedDestinations))
+
+	for _, dest := range sourcedDestinations {
+		destinations = append(destinations, dest.Airport)
+		for _, provider := range dest.Providers {
+			if _, found := byProvider[provider]; !found {
+				providers = append(providers, provider)
+			}
+			byProvider[provider] = append(byProvider[provider], dest.Airport)
+		}
+	}
+
+	if len(providers) == 1 {
+		fmt.Fprintln(out, strings.Join(destinations, ","))
+		return
+	}
+	for _, provider := range providers {
+		fmt.Fprintf(out, "%s: %s\n", provider, strings.Join(byProvider[provider], ","))
+	}
+}
+
+// Print the flights which are the best trade-offs between the given measures, leaving out those which are
+// clearly worse than one of them, and any which can no longer be booked
+func printTradeOffs(out io.Writer, flightOptions []pricing.FlightForPurchase, dimensions []pricing.Dimension) {
+
+	available := make([]pricing.FlightForPurchase, 0, len(flightOptions))
+	for _, f := range flightOptions {
+		if f.Confirmation.Status != pricing.OfferUnavailable {
+			available = append(available, f)
+		}
+	}
+	front := pricing.FindParetoFront(available, dimensions)
+
+	names := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		names = append(names, dimension.String())
+	}
+	fmt.Fprintf(out, "\nBest trade-offs between %s (%d of %d flights)\n", strings.Join(names, ", "), len(front), len(available))
+	for _, f := range front {
+		fmt.Fprintln(out, f)
+	}
 }
 
 // Print a formatted table, showing the resulting flight options
-func printResults(flightOptions []pricing.FlightForPurchase) {
-	fmt.Printf("%s\t%s\t%s\t%s\t\t%s\t\t%s\n", "Flight", "From", "To", "Departing", "Arriving", "Price")
-	fmt.Println("________________________________________________________________________________")
+func printResults(out io.Writer, flightOptions []pricing.FlightForPurchase) {
+	fmt.Fprintf(out, "%s\t%s\t%s\t%s\t\t%s\t\t%s\t\t%s\t\t%s\n", "Flight", "From", "To", "Departing", "Arriving", "Price", "Fare", "Reconfirmed")
+	fmt.Fprintln(out, "________________________________________________________________________________")
 
 	for _, f := range flightOptions {
-		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", f.FlightNumber, f.Origin, f.Destination, f.Departure.Format("2006-01-02 15:04"), f.Arrival.Format("2006-01-02 15:04"), f.GetFormattedPrice())
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t\t%s\t\t%s\n", f.FlightNumber, f.Origin, f.Destination, f.Departure.Format("2006-01-02 15:04"), f.Arrival.Format("2006-01-02 15:04"), f.GetFormattedPrice(), f.Fare.GetBrandName(), f.Confirmation.Status)
 	}
 
-	fmt.Println("________________________________________________________________________________")
+	fmt.Fprintln(out, "________________________________________________________________________________")
 }
@@ -0,0 +1,1142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flynow/apierror"
+	"flynow/fakeapi"
+	"flynow/transport"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Starts fake AviationStack and Amadeus servers with flights from OSL to CPH, ARN and BGO, and offers for
+// the first two. The user's config file isn't used, and the route database and quota usage are kept in a
+// temporary cache directory.
+func setupFakeApis(t *testing.T) (*fakeapi.AviationStack, *fakeapi.Amadeus) {
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("FLYNOW_CACHE_DIR", t.TempDir())
+	t.Setenv("FLYNOW_CONFIG", "")
+	t.Setenv("FLYNOW_ORIGIN", "OSL")
+	t.Setenv("FLYNOW_CURRENCY", "NOK")
+	t.Setenv("FLYNOW_SCHEDULE_SOURCE", "aviationstack")
+	t.Setenv("FLYNOW_CREDENTIAL_PROVIDERS", "env")
+	t.Setenv("FLYNOW_AVIATIONSTACK_API_KEY", "test-key")
+	t.Setenv("FLYNOW_AMADEUS_CLIENT_ID", "test-id")
+	t.Setenv("FLYNOW_AMADEUS_CLIENT_SECRET", "test-secret")
+	setLogOutput(t, io.Discard)
+
+	departure := time.Now().Add(2 * time.Hour).Truncate(time.Minute)
+
+	aviationStack := fakeapi.NewAviationStack(t)
+	aviationStack.AddFlights(
+		fakeapi.NewFlight("DY", "932", "OSL", "CPH", departure, 70*time.Minute),
+		fakeapi.NewFlight("SK", "484", "OSL", "ARN", departure, 55*time.Minute),
+		fakeapi.NewFlight("DY", "610", "OSL", "BGO", departure, 50*time.Minute),
+		fakeapi.NewFlight("SK", "1470", "BGO", "CPH", departure, 80*time.Minute),
+	)
+
+	amadeus := fakeapi.NewAmadeus(t)
+	amadeus.AddOffers(
+		fakeapi.NewOffer("DY", "932", "OSL", "CPH", departure, 70*time.Minute, 899, "NOK"),
+		fakeapi.NewOffer("SK", "1456", "OSL", "CPH", departure.Add(time.Hour), 70*time.Minute, 1249, "NOK"),
+		fakeapi.NewOffer("SK", "484", "OSL", "ARN", departure, 55*time.Minute, 650, "NOK"),
+	)
+
+	return aviationStack, amadeus
+}
+
+// Sends the log records to the given writer until the end of the test
+func setLogOutput(t *testing.T, w io.Writer) {
+
+	previous := logOutput
+	logOutput = w
+	t.Cleanup(func() { logOutput = previous })
+}
+
+func runWithFakes(aviationStack *fakeapi.AviationStack, amadeus *fakeapi.Amadeus) (string, error) {
+
+	var out bytes.Buffer
+	err := run([]string{"--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, strings.NewReader(""), &out)
+
+	return out.String(), err
+}
+
+// Gets the part of the output after the search progress, i.e. the final results
+func getResults(output string) string {
+
+	_, results, _ := strings.Cut(output, "\nFound the following flights\n")
+	return results
+}
+
+func TestRunFindsCheapestFlights(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := []string{
+		"ARN,BGO,CPH\n",
+		"SK484\tOSL\tARN\t",
+		"650 NOK\t\t\t\tconfirmed\n",
+		"DY932\tOSL\tCPH\t",
+		"899 NOK\t\t\t\tconfirmed\n",
+		"Best option:\nSK484",
+		"Distance 385 km, about 74 kg CO2 per passenger on a BOEING 737-800 (WINGLETS), 169 NOK per 100 km\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, output)
+		}
+	}
+	if strings.Index(output, "SK484\tOSL") > strings.Index(output, "DY932\tOSL") {
+		t.Errorf("Results aren't sorted by price:\n%s", output)
+	}
+	if strings.Contains(output, "SK1456") {
+		t.Errorf("Output contains more expensive offer:\n%s", output)
+	}
+
+	if actual := amadeus.Offers.Requests(); actual != 3 {
+		t.Errorf("Made %d flight searches; Expected 3", actual)
+	}
+	if actual := amadeus.Pricing.Requests(); actual != 2 {
+		t.Errorf("Made %d pricing requests; Expected 2", actual)
+	}
+}
+
+func TestRunSortsByConfiguredKeys(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{"descending price", map[string]string{"FLYNOW_SORT": "-price"}, "DY932"},
+		{"score by duration", map[string]string{"FLYNOW_WEIGHTS": "price=1,duration=3"}, "SK484"},
+		{"score by soonness", map[string]string{"FLYNOW_WEIGHTS": "soonness=1", "FLYNOW_SORT": "score,-price"}, "DY932"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, amadeus := setupFakeApis(t)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			// Act
+			output, err := runWithFakes(aviationStack, amadeus)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if !strings.Contains(output, "Best option:\n"+test.expected) {
+				t.Errorf("Best option isn't %s:\n%s", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestRunShowsTradeOffs(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	departure := time.Now().Add(4 * time.Hour).Truncate(time.Minute)
+	aviationStack.AddFlights(fakeapi.NewFlight("FR", "123", "OSL", "AGP", departure, 4*time.Hour))
+	amadeus.AddOffers(fakeapi.NewOffer("FR", "123", "OSL", "AGP", departure, 4*time.Hour, 499, "NOK"))
+	t.Setenv("FLYNOW_PARETO", "price,soonness")
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	_, tradeOffs, found := strings.Cut(output, "\nBest trade-offs between price, soonness (2 of 3 flights)\n")
+	if !found {
+		t.Fatalf("Output doesn't show the trade-offs:\n%s", output)
+	}
+	tradeOffs, _, _ = strings.Cut(tradeOffs, "\n\n")
+	if !strings.HasPrefix(tradeOffs, "FR123 : OSL-AGP") || !strings.Contains(tradeOffs, "\nSK484 : OSL-ARN") || strings.Contains(tradeOffs, "DY932") {
+		t.Errorf("Trade-offs aren't FR123 and SK484:\n%s", tradeOffs)
+	}
+}
+
+func TestRunFiltersWithWhere(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	t.Setenv("FLYNOW_WHERE", `dest.country == "SE" && price < 1000 NOK && depart > now`)
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(output, "\n1 of 2 flights match dest.country") || !strings.Contains(output, "SK484\tOSL\tARN\t") {
+		t.Errorf("Output doesn't show the matching flight:\n%s", output)
+	}
+	if strings.Contains(getResults(output), "DY932") {
+		t.Errorf("Results contain the flight to Denmark:\n%s", output)
+	}
+	if !strings.Contains(output, "OSL-CPH: DY932 899 NOK, which doesn't match --where\n") {
+		t.Errorf("Progress doesn't show that the flight to Denmark doesn't match:\n%s", output)
+	}
+	if actual := amadeus.Pricing.Requests(); actual != 1 {
+		t.Errorf("Made %d pricing requests; Expected only the matching flight to be reconfirmed", actual)
+	}
+}
+
+func TestRunExcludesAirlines(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+
+	// Act
+	var out bytes.Buffer
+	args := []string{"--exclude-airlines", "DY", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}
+	err := run(args, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := out.String()
+	if strings.Contains(output, "DY932") || !strings.Contains(getResults(output), "SK484\tOSL\tARN\t") {
+		t.Errorf("Output doesn't show only the SAS flight:\n%s", output)
+	}
+	if actual := amadeus.Offers.Requests(); actual != 1 {
+		t.Errorf("Made %d flight searches; Expected 1, since only ARN has a scheduled flight by another airline", actual)
+	}
+}
+
+func TestRunAddsCheckedBagFees(t *testing.T) {
+
+	tests := []struct {
+		checkedBags string
+		expected    string
+	}{
+		{"0", "FR1234\tOSL\tAGP\t"},
+		{"1", "DY1830\tOSL\tAGP\t"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.checkedBags, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, amadeus := setupFakeApis(t)
+			departure := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+			lowFare := fakeapi.NewOffer("FR", "1234", "OSL", "AGP", departure, 225*time.Minute, 499, "NOK")
+			lowFare.CheckedBagPrice = 350
+			withBag := fakeapi.NewOffer("DY", "1830", "OSL", "AGP", departure, 220*time.Minute, 799, "NOK")
+			withBag.IncludedBags = 1
+			amadeus.AddOffers(lowFare, withBag)
+
+			// Act
+			var out bytes.Buffer
+			args := []string{"--checked-bags", test.checkedBags, "--destination", "AGP", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}
+			err := run(args, strings.NewReader(""), &out)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			output := out.String()
+			if !strings.Contains(getResults(output), test.expected) {
+				t.Errorf("Output doesn't show %q as the cheapest flight:\n%s", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestRunExcludesFareBrands(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	departure := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+	light := fakeapi.NewOffer("DY", "936", "OSL", "CPH", departure, 70*time.Minute, 599, "NOK")
+	light.Brand = "LOWFARE"
+	amadeus.AddOffers(light)
+
+	// Act
+	var out bytes.Buffer
+	args := []string{"--exclude-brands", "lowfare", "--destination", "CPH", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}
+	err := run(args, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := out.String()
+	if strings.Contains(output, "DY936") || !strings.Contains(getResults(output), "DY932\tOSL\tCPH\t") {
+		t.Errorf("Output doesn't skip the excluded fare brand:\n%s", output)
+	}
+}
+
+func TestRunShowsProgress(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	progress, _, found := strings.Cut(output, "\nFound the following flights\n")
+	if !found {
+		t.Fatalf("Output doesn't contain the results:\n%s", output)
+	}
+	expected := []string{
+		`\nSearching for prices to 3 destinations\.\.\.\n`,
+		`\[[1-3]/3\] OSL-CPH: DY932 899 NOK\n`,
+		`\[[1-3]/3\] OSL-ARN: SK484 650 NOK\n`,
+		`\[[1-3]/3\] OSL-BGO: no flight found\n`,
+		`\[3/3\] `,
+		`  Best so far: 1\. SK484 OSL-ARN 650 NOK, 2\. DY932 OSL-CPH 899 NOK\n`,
+	}
+	for _, e := range expected {
+		if !regexp.MustCompile(e).MatchString(progress) {
+			t.Errorf("Progress doesn't match %q:\n%s", e, output)
+		}
+	}
+}
+
+func TestRunReportsWhereMistake(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		where    string
+		expected string
+		column   int
+	}{
+		{"syntax", "price < 1000 NOK && dest.country ==", "where: column 36: expected a value", 36},
+		{"currency", "duration < 2h && price < 100 EUR", "where: column 26: the amount is in EUR, but the prices are in NOK", 26},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, amadeus := setupFakeApis(t)
+			t.Setenv("FLYNOW_WHERE", test.where)
+
+			// Act
+			_, err := runWithFakes(aviationStack, amadeus)
+
+			// Assert
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Fatalf("Got error %v; Expected the mistake to be reported", err)
+			}
+			expected := test.where + "\n" + strings.Repeat(" ", test.column-1) + "^"
+			if hint := getErrorHint(err); hint != expected {
+				t.Errorf("Got hint\n%s\nExpected\n%s", hint, expected)
+			}
+			if actual := amadeus.Offers.Requests(); actual != 0 {
+				t.Errorf("Made %d flight searches; Expected none", actual)
+			}
+		})
+	}
+}
+
+func TestRunUsesConfiguredPipeline(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	stages := `{ "pipeline": { "destinations": [ { "stage": "origin" }, { "stage": "exclude", "params": { "countries": ["SE"] } } ] } }`
+	if err := os.WriteFile(configFile, []byte(stages), 0o600); err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+	t.Setenv("FLYNOW_CONFIG", configFile)
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if strings.Contains(output, "SK484") || !strings.Contains(output, "DY932") {
+		t.Errorf("Output doesn't show only the flights outside Sweden:\n%s", output)
+	}
+	if actual := amadeus.Offers.Requests(); actual != 2 {
+		t.Errorf("Made %d flight searches; Expected 2, since ARN is excluded", actual)
+	}
+}
+
+func TestRunReportsUnknownPipelineStage(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	stages := `{ "pipeline": { "destinations": [ { "stage": "exclude-countries" } ] } }`
+	if err := os.WriteFile(configFile, []byte(stages), 0o600); err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+	t.Setenv("FLYNOW_CONFIG", configFile)
+
+	// Act
+	_, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	expected := `pipeline.destinations: unknown destination filter "exclude-countries" (expected one of airlines, exclude, origin)`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("Got error %v; Expected %q", err, expected)
+	}
+}
+
+func TestRunRetriesRateLimitedSearches(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	amadeus.Offers.RateLimited = 2
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(output, "Best option:\nSK484") {
+		t.Errorf("Output doesn't contain best option:\n%s", output)
+	}
+	if actual := amadeus.Offers.Requests(); actual != 5 {
+		t.Errorf("Made %d flight search requests; Expected 5", actual)
+	}
+}
+
+func TestRunSkipsUnavailableOffers(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	amadeus.Pricing.StatusCode = 400
+	amadeus.Pricing.Body = fakeapi.OfferUnavailableError
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(output, "650 NOK\t\t\t\tunavailable\n") {
+		t.Errorf("Output doesn't show offer as unavailable:\n%s", output)
+	}
+	if strings.Contains(output, "Best option:") {
+		t.Errorf("Output contains an unavailable best option:\n%s", output)
+	}
+}
+
+func TestRunReportsRejectedReconfirmation(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	amadeus.Pricing.StatusCode = 400
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(output, "reconfirming SK484: unexpected response code (400)") {
+		t.Errorf("Output doesn't show the reconfirmation error:\n%s", output)
+	}
+	if strings.Contains(output, "unavailable\n") || !strings.Contains(output, "Best option:") {
+		t.Errorf("Output shows the offer as unavailable, rather than unconfirmed:\n%s", output)
+	}
+}
+
+func TestRunStopsAtAviationStackQuota(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	t.Setenv("FLYNOW_AVIATIONSTACK_QUOTA", "1")
+
+	// Act
+	_, firstErr := runWithFakes(aviationStack, amadeus)
+	_, secondErr := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if firstErr != nil {
+		t.Fatalf("First run failed: %v", firstErr)
+	}
+	if secondErr == nil || !strings.Contains(secondErr.Error(), "monthly quota exceeded") {
+		t.Errorf("Got error %v from second run; Expected quota to be exceeded", secondErr)
+	}
+	if actual := aviationStack.Flights.Requests(); actual != 1 {
+		t.Errorf("Made %d schedule requests; Expected 1", actual)
+	}
+}
+
+func TestRunSkipsUnknownDestinations(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	aviationStack.AddFlights(fakeapi.NewFlight("WF", "123", "OSL", "X1Z", time.Now().Add(time.Hour), time.Hour))
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(output, "Best option:\nSK484") {
+		t.Errorf("Got output:\n%s\nExpected the search to go ahead without X1Z", output)
+	}
+}
+
+func TestRunSearchesMetroArea(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	departure := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+	aviationStack.AddFlights(
+		fakeapi.NewFlight("WF", "313", "TRF", "CPH", departure, 70*time.Minute),
+		fakeapi.NewFlight("FR", "123", "TRF", "AGP", departure, 4*time.Hour),
+		fakeapi.NewFlight("WF", "100", "TRF", "OSL", departure, 30*time.Minute),
+	)
+	amadeus.AddOffers(
+		fakeapi.NewOffer("WF", "313", "TRF", "CPH", departure, 70*time.Minute, 750, "NOK"),
+		fakeapi.NewOffer("FR", "123", "TRF", "AGP", departure, 4*time.Hour, 499, "NOK"),
+	)
+
+	// Act
+	var out bytes.Buffer
+	err := run([]string{"--origin", "Oslo", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := out.String()
+	expected := []string{
+		"Searching for potential destinations from Oslo (OSL, TRF, RYG)...\n",
+		"From OSL:\nARN,BGO,CPH\nFrom TRF:\nAGP,CPH\nFrom RYG:\n(none)\n",
+		"FR123\tTRF\tAGP\t",
+		"WF313\tTRF\tCPH\t",
+		"SK484\tOSL\tARN\t",
+	}
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, output)
+		}
+	}
+	if strings.Contains(getResults(output), "DY932") {
+		t.Errorf("Results contain the more expensive flight to CPH from OSL:\n%s", output)
+	}
+	if actual := aviationStack.Flights.Requests(); actual != 3 {
+		t.Errorf("Made %d AviationStack requests; Expected 1 for each airport", actual)
+	}
+}
+
+func TestRunUsesAmadeusRoutes(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	amadeus.AddRoutes("OSL", "CPH", "STO", "OSL")
+
+	// Act
+	var out bytes.Buffer
+	args := []string{"--schedule-source", "amadeus", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}
+	err := run(args, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := out.String()
+	for _, e := range []string{"Searching for prices to 4 destinations", "DY932\tOSL\tCPH\t", "SK484\tOSL\tARN\t"} {
+		if !strings.Contains(output, e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, output)
+		}
+	}
+	if actual := aviationStack.Flights.Requests(); actual != 0 {
+		t.Errorf("Made %d AviationStack requests; Expected the Amadeus routes to be used instead", actual)
+	}
+	if actual := amadeus.Routes.Requests(); actual != 1 {
+		t.Errorf("Made %d Amadeus routes requests; Expected 1", actual)
+	}
+}
+
+func TestRunSearchesGivenDestination(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+
+	// Act
+	var out bytes.Buffer
+	err := run([]string{"--destination", "stockholm", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := out.String()
+	for _, e := range []string{"ARN,BMA,NYO\n", "SK484\tOSL\tARN\t"} {
+		if !strings.Contains(output, e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, output)
+		}
+	}
+	if strings.Contains(output, "CPH") {
+		t.Errorf("Output contains a destination outside Stockholm:\n%s", output)
+	}
+	if actual := aviationStack.Flights.Requests(); actual != 0 {
+		t.Errorf("Made %d AviationStack requests; Expected the schedule not to be used", actual)
+	}
+	if actual := amadeus.Offers.Requests(); actual != 3 {
+		t.Errorf("Made %d flight searches; Expected 3", actual)
+	}
+}
+
+func TestRunSearchesNearbyAirports(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	departure := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+	aviationStack.AddFlights(
+		fakeapi.NewFlight("WF", "313", "TRF", "CPH", departure, 70*time.Minute),
+		fakeapi.NewFlight("FR", "123", "TRF", "AGP", departure, 4*time.Hour),
+	)
+	amadeus.AddOffers(
+		fakeapi.NewOffer("WF", "313", "TRF", "CPH", departure, 70*time.Minute, 800, "NOK"),
+		fakeapi.NewOffer("FR", "123", "TRF", "AGP", departure, 4*time.Hour, 499, "NOK"),
+	)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	transfers := `{ "nearby": { "transfers": { "OSL": { "minutes": 40, "cost": 100 }, "TRF": { "minutes": 90, "cost": 250 } } } }`
+	if err := os.WriteFile(configFile, []byte(transfers), 0o600); err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+
+	// Act
+	var out bytes.Buffer
+	args := []string{"--config", configFile, "--radius", "130", "--home", "59.91,10.75", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}
+	err := run(args, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := out.String()
+	expected := []string{
+		"Searching for potential destinations from Within 130 km of 59.91,10.75 (OSL, RYG, TRF)...\n",
+		"\nDoor to door\nFR123 TRF-AGP: 749 NOK, 5h30m door to door (250 NOK, 1h30m to TRF)\nSK484 OSL-ARN: 750 NOK, 1h35m door to door (100 NOK, 0h40m to OSL)\nDY932 OSL-CPH: 999 NOK",
+	}
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, output)
+		}
+	}
+	if strings.Contains(getResults(output), "WF313") {
+		t.Errorf("Results contain the flight from TRF to CPH, which costs more door to door:\n%s", output)
+	}
+}
+
+func TestRunLogsSearchDetails(t *testing.T) {
+
+	testCases := []struct {
+		name       string
+		flags      []string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:       "warnings only",
+			unexpected: []string{"level=DEBUG"},
+		},
+		{
+			name:       "verbose",
+			flags:      []string{"--verbose"},
+			expected:   []string{`msg="Searched for flights" origin=OSL destination=CPH offers=2 latency=`, `msg="Reconfirmed price" flight=SK484 offer=1 searched=650 confirmed=650 status=confirmed`, `msg="AviationStack request" origin=OSL status=200`},
+			unexpected: []string{"HTTP response"},
+		},
+		{
+			name:       "trace",
+			flags:      []string{"--trace"},
+			expected:   []string{`msg="HTTP response" component=http method=POST url=`, "access_key=REDACTED", "client_secret=REDACTED", "Authorization:[REDACTED]", `access_token\":\"REDACTED`},
+			unexpected: []string{"test-key", "test-secret", fakeapi.AccessToken},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, amadeus := setupFakeApis(t)
+			var logs bytes.Buffer
+			setLogOutput(t, &logs)
+			args := append([]string{"--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, tc.flags...)
+
+			// Act
+			err := run(args, strings.NewReader(""), io.Discard)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			for _, e := range tc.expected {
+				if !strings.Contains(logs.String(), e) {
+					t.Errorf("Logs don't contain %q:\n%s", e, logs.String())
+				}
+			}
+			for _, u := range tc.unexpected {
+				if strings.Contains(logs.String(), u) {
+					t.Errorf("Logs contain %q:\n%s", u, logs.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunReportsApiErrors(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		setup       func(*fakeapi.AviationStack, *fakeapi.Amadeus)
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "schedule server error",
+			setup:    func(a *fakeapi.AviationStack, _ *fakeapi.Amadeus) { a.Flights.StatusCode = 500 },
+			expected: "unexpected response code (500)",
+		},
+		{
+			name:        "invalid credentials",
+			setup:       func(_ *fakeapi.AviationStack, a *fakeapi.Amadeus) { a.Token.StatusCode = 401 },
+			expected:    "authenticating with Amadeus",
+			expectedErr: apierror.ErrUnauthorized,
+		},
+		{
+			name:        "invalid API key",
+			setup:       func(a *fakeapi.AviationStack, _ *fakeapi.Amadeus) { a.Flights.StatusCode = 401 },
+			expected:    "invalid_access_key",
+			expectedErr: apierror.ErrUnauthorized,
+		},
+		{
+			name:        "schedule quota used up",
+			setup:       func(a *fakeapi.AviationStack, _ *fakeapi.Amadeus) { a.Flights.StatusCode = 429 },
+			expected:    "usage_limit_reached",
+			expectedErr: apierror.ErrQuotaExceeded,
+		},
+		{
+			name:     "missing credentials",
+			setup:    func(*fakeapi.AviationStack, *fakeapi.Amadeus) { os.Unsetenv("FLYNOW_AMADEUS_CLIENT_SECRET") },
+			expected: "amadeus-client-secret: credential not found in environment",
+		},
+		{
+			name:     "malformed offers",
+			setup:    func(_ *fakeapi.AviationStack, a *fakeapi.Amadeus) { a.Offers.Malformed = true },
+			expected: "parsing response body",
+		},
+		{
+			name: "slow offers",
+			setup: func(_ *fakeapi.AviationStack, a *fakeapi.Amadeus) {
+				a.Offers.Latency = 50 * time.Millisecond
+				a.Offers.StatusCode = 503
+			},
+			expected: "unexpected response code (503)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, amadeus := setupFakeApis(t)
+			tc.setup(aviationStack, amadeus)
+
+			// Act
+			_, err := runWithFakes(aviationStack, amadeus)
+
+			// Assert
+			if err == nil {
+				t.Fatal("Run succeeded; Expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Got error %q; Expected it to contain %q", err, tc.expected)
+			}
+			if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Got error %q; Expected it to be %v", err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestRunShowsResultsDespiteFailedDestination(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	amadeus.FailingDestinations = map[string]int{"ARN": 500}
+
+	// Act
+	output, err := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "unexpected response code (500)") {
+		t.Errorf("Got error %v; Expected the failed search to be reported", err)
+	}
+	results := getResults(output)
+	if !strings.Contains(results, "DY932\tOSL\tCPH\t") || !strings.Contains(results, "\nBest option:\nDY932") {
+		t.Errorf("Output doesn't show the flights found for the other destinations:\n%s", output)
+	}
+	if !strings.Contains(output, "OSL-ARN: failed") {
+		t.Errorf("Progress doesn't show the failed search:\n%s", output)
+	}
+}
+
+func TestRunRestoresTransport(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	original := transport.Current()
+	args := []string{"--record", t.TempDir(), "--trace", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}
+
+	// Act
+	err := run(args, strings.NewReader(""), io.Discard)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if actual := transport.Current(); actual != original {
+		t.Errorf("Transport is %T after the run; Expected the original %T to be restored", actual, original)
+	}
+}
+
+// Sends the requests for the default API hosts to the fake servers instead, so that a cassette can be
+// recorded with the URLs of the real APIs
+type redirectTransport struct {
+	hosts map[string]string
+}
+
+func (redirect redirectTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	target, err := url.Parse(redirect.hosts[request.URL.Host])
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("unexpected request to %s", request.URL.Host)
+	}
+
+	redirected := request.Clone(request.Context())
+	redirected.URL.Scheme, redirected.URL.Host = target.Scheme, target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
+
+// The sample cassette, which was recorded by this test with the sample AviationStack and Amadeus responses.
+// Only the Norwegian (D8 and DY) flights are searched, to keep it small.
+const sampleCassette = "testdata/sample-cassette"
+
+func TestRunRecordsAndReplaysSampleSearch(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	t.Setenv("FLYNOW_CURRENCY", "EUR")
+	for file, body := range map[string]*string{"schedule/sample-scheduled-flights.json": &aviationStack.Flights.Body, "pricing/sample-flight-offers.json": &amadeus.Offers.Body} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unable to read sample: %v", err)
+		}
+		*body = string(data)
+	}
+	original := transport.Current()
+	transport.Use(redirectTransport{hosts: map[string]string{
+		"api.aviationstack.com": aviationStack.Url,
+		"test.api.amadeus.com":  amadeus.Url,
+	}})
+	t.Cleanup(func() { transport.Use(original) })
+	cassette := t.TempDir()
+
+	search := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := run(args, strings.NewReader(""), &out); err != nil {
+			t.Fatalf("Run with %v failed: %v", args, err)
+		}
+		return getResults(out.String())
+	}
+
+	// Act
+	recorded := search("--include-airlines", "D8,DY", "--record", cassette)
+	requests := aviationStack.Flights.Requests() + amadeus.Offers.Requests() + amadeus.Pricing.Requests()
+	replayed := search("--include-airlines", "D8,DY", "--replay", cassette)
+	sample := search("--include-airlines", "D8,DY", "--replay", sampleCassette)
+
+	// Assert
+	if !strings.Contains(recorded, "DY932\tOSL\tCPH\t") {
+		t.Errorf("Recorded search doesn't show the cheapest sample offer:\n%s", recorded)
+	}
+	if replayed != recorded {
+		t.Errorf("Replayed results\n%s\nExpected the recorded results\n%s", replayed, recorded)
+	}
+	if sample != recorded {
+		t.Errorf("Results from %s\n%s\nExpected the recorded results (the sample cassette may need recording again)\n%s", sampleCassette, sample, recorded)
+	}
+	if actual := aviationStack.Flights.Requests() + amadeus.Offers.Requests() + amadeus.Pricing.Requests(); actual != requests {
+		t.Errorf("Made %d requests while replaying; Expected none", actual-requests)
+	}
+}
+
+func TestCredentialsCommand(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	os.Unsetenv("FLYNOW_AMADEUS_CLIENT_SECRET")
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("FLYNOW_CREDENTIAL_PROVIDERS", "env,file")
+	t.Setenv("FLYNOW_CREDENTIALS_FILE", file)
+
+	// Act
+	var before, set, after bytes.Buffer
+	checkErr := run([]string{"credentials", "check"}, strings.NewReader(""), &before)
+	setErr := run([]string{"credentials", "set", "amadeus-client-secret"}, strings.NewReader("file-secret\n"), &set)
+	recheckErr := run([]string{"credentials", "check"}, strings.NewReader(""), &after)
+	_, runErr := runWithFakes(aviationStack, amadeus)
+
+	// Assert
+	if checkErr == nil || !strings.Contains(before.String(), "amadeus-client-secret: MISSING") {
+		t.Errorf("Got error %v and output:\n%s\nExpected the missing secret to be reported", checkErr, before.String())
+	}
+	if setErr != nil {
+		t.Fatalf("Unable to set secret: %v", setErr)
+	}
+	if recheckErr != nil || !strings.Contains(after.String(), "amadeus-client-secret: found in file "+file) {
+		t.Errorf("Got error %v and output:\n%s\nExpected the secret to be found in the file", recheckErr, after.String())
+	}
+	if strings.Contains(set.String()+after.String(), "file-secret") {
+		t.Errorf("Secret appears in the output")
+	}
+	if runErr != nil {
+		t.Errorf("Run failed with the secret from the file: %v", runErr)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		args             []string
+		setup            func(*fakeapi.AviationStack, *fakeapi.Amadeus)
+		expected         []string
+		expectedRequests int
+		fails            bool
+	}{
+		{
+			name:     "healthy without probe",
+			expected: []string{"[OK  ] Origin: OSL Oslo Gardermoen Airport", "[OK  ] Credentials", "[OK  ] Amadeus token", "[SKIP] AviationStack", "[OK  ] Clock", "[OK  ] Schedule providers: aviationstack", "No problems found"},
+		},
+		{
+			name:             "healthy with probe",
+			args:             []string{"--probe-aviationstack"},
+			expected:         []string{"[OK  ] AviationStack: the API key was accepted", "[OK  ] AviationStack quota: 1 of 100 requests used"},
+			expectedRequests: 1,
+		},
+		{
+			name:     "token rejected",
+			setup:    func(_ *fakeapi.AviationStack, a *fakeapi.Amadeus) { a.Token.StatusCode = 401 },
+			expected: []string{"[FAIL] Amadeus token: the client ID or secret was rejected", "FIX: Check the API key and secret"},
+			fails:    true,
+		},
+		{
+			name:             "API key rejected",
+			args:             []string{"--probe-aviationstack"},
+			setup:            func(a *fakeapi.AviationStack, _ *fakeapi.Amadeus) { a.Flights.StatusCode = 401 },
+			expected:         []string{"[FAIL] AviationStack: the API key was rejected", "invalid_access_key", "FIX: Copy the access key"},
+			expectedRequests: 1,
+			fails:            true,
+		},
+		{
+			name:     "unknown origin",
+			args:     []string{"--origin", "XQZ"},
+			expected: []string{"[WARN] Origin: XQZ isn't in the airport database", "No problems found"},
+		},
+		{
+			name:     "clock skew",
+			setup:    func(_ *fakeapi.AviationStack, a *fakeapi.Amadeus) { a.Token.ClockSkew = -5 * time.Minute },
+			expected: []string{"[WARN] Clock: the local clock is 5m", "FIX: Synchronize the system clock"},
+		},
+		{
+			name:     "missing credentials",
+			setup:    func(*fakeapi.AviationStack, *fakeapi.Amadeus) { os.Unsetenv("FLYNOW_AMADEUS_CLIENT_ID") },
+			expected: []string{"[FAIL] Credentials: amadeus-client-id is missing", `FIX: Run "flynow credentials set amadeus-client-id"`, "[SKIP] Amadeus token"},
+			fails:    true,
+		},
+		{
+			name:     "invalid configuration",
+			args:     []string{"--concurrency", "0"},
+			expected: []string{"[FAIL] Configuration: invalid configuration: concurrency: 0 is less than 1", "FIX: Correct the settings"},
+			fails:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, amadeus := setupFakeApis(t)
+			if tc.setup != nil {
+				tc.setup(aviationStack, amadeus)
+			}
+			args := append([]string{"doctor", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, tc.args...)
+
+			// Act
+			var out bytes.Buffer
+			err := run(args, strings.NewReader(""), &out)
+
+			// Assert
+			if tc.fails != (err != nil) {
+				t.Errorf("Got error %v; Expected failure: %v", err, tc.fails)
+			}
+			for _, e := range tc.expected {
+				if !strings.Contains(out.String(), e) {
+					t.Errorf("Output doesn't contain %q:\n%s", e, out.String())
+				}
+			}
+			if actual := aviationStack.Flights.Requests(); actual != tc.expectedRequests {
+				t.Errorf("Made %d AviationStack requests; Expected %d", actual, tc.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestAirportCommand(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+		fails    bool
+	}{
+		{
+			name:     "code",
+			args:     []string{"bgo"},
+			expected: []string{"BGO\tENBR\tBergen Airport Flesland, Bergen, NO\t60.2934, 5.2181\tEurope/Oslo\n"},
+		},
+		{
+			name:     "city with several airports",
+			args:     []string{"london"},
+			expected: []string{"LHR\tEGLL", "LGW\tEGKK", "LCY\tEGLC"},
+		},
+		{
+			name:     "name in several words",
+			args:     []string{"sandefjord", "airport"},
+			expected: []string{"TRF\tENTO"},
+		},
+		{
+			name:  "no match",
+			args:  []string{"atlantis"},
+			fails: true,
+		},
+		{
+			name:  "no query",
+			fails: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			// Act
+			var out bytes.Buffer
+			err := run(append([]string{"airport"}, tc.args...), strings.NewReader(""), &out)
+
+			// Assert
+			if tc.fails != (err != nil) {
+				t.Errorf("Got error %v; Expected failure: %v", err, tc.fails)
+			}
+			for _, e := range tc.expected {
+				if !strings.Contains(out.String(), e) {
+					t.Errorf("Output doesn't contain %q:\n%s", e, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	departure := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+	aviationStack.AddFlights(fakeapi.NewFlight("WF", "123", "OSL", "X1Z", departure, time.Hour))
+	amadeus.AddOffers(fakeapi.NewOffer("DY", "610", "OSL", "BGO", departure, 50*time.Minute, 99, "EUR"))
+
+	// Act
+	var out bytes.Buffer
+	err := run([]string{"explain", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+
+	expected := []string{
+		"  aviationstack\tDY932\tOSL-CPH\tused: passed every destination filter (origin, airlines)\n",
+		"CPH (from aviationstack)\n  offer 1\tDY932\tOSL-CPH\t899 NOK\taccepted: the cheapest matching offer\n  offer 2\tSK1456\tOSL-CPH\t1249 NOK\trejected: costs more than offer 1\n",
+		"  offer 1\tDY610\tOSL-BGO\t99.00 EUR\trejected: wrong currency (searched for NOK)\n",
+		"X1Z (from aviationstack)\n  skipped, since Amadeus doesn't accept it as a destination",
+		"  Schedule destinations: 4 (ARN, BGO, CPH, X1Z)\n",
+		"  Priced destinations:   2 (ARN, CPH)\n",
+		"  Without a price:       2\n    BGO: none of the 1 offers met the criteria\n    X1Z: skipped",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, out.String())
+		}
+	}
+	if amadeus.Pricing.Requests() != 0 {
+		t.Errorf("Made %d pricing requests; Expected the prices not to be reconfirmed", amadeus.Pricing.Requests())
+	}
+}
+
+func TestExplainShowsFailedPriceSearch(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeus := setupFakeApis(t)
+	amadeus.Token.StatusCode = 401
+
+	// Act
+	var out bytes.Buffer
+	err := run([]string{"explain", "--aviationstack-url", aviationStack.Url, "--amadeus-url", amadeus.Url}, strings.NewReader(""), &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	expected := []string{
+		"  Without a price:       3\n    ARN: not searched\n    BGO: not searched\n    CPH: not searched\n",
+		"\nErrors\n  ",
+		"authenticating with Amadeus",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("Output doesn't contain %q:\n%s", e, out.String())
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"flynow/transport"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Where the log records are written, which is kept apart from the search results
+var logOutput io.Writer = os.Stderr
+
+// The logging flags, shared by the commands which call the APIs
+type logFlags struct {
+	verbose *bool
+	trace   *bool
+}
+
+func registerLogFlags(flags *flag.FlagSet) logFlags {
+
+	return logFlags{
+		verbose: flags.Bool("verbose", false, "log the details of each search, such as rejected offers and API latencies"),
+		trace:   flags.Bool("trace", false, "log every HTTP request and response, with the secrets removed (implies --verbose)"),
+	}
+}
+
+// Sets up the default logger used by all the packages, and the HTTP trace if requested. Only warnings are
+// logged unless --verbose is given. The returned function restores the previous logger and transport.
+func (f logFlags) setup() (restore func()) {
+
+	level := slog.LevelWarn
+	if *f.verbose || *f.trace {
+		level = slog.LevelDebug
+	}
+
+	previousLogger := slog.Default()
+	previousTransport := transport.Current()
+
+	logger := slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	if *f.trace {
+		transport.Use(transport.NewTracer(previousTransport, logger.With("component", "http")))
+	}
+
+	return func() {
+		slog.SetDefault(previousLogger)
+		transport.Use(previousTransport)
+	}
+}
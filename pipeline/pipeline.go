@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Composable stages which decide which destinations and offers are used, and add details to the results.
+// Each kind of stage (e.g. the offer filters in the pricing package) has a registry, where the built-in
+// stages are registered by name, and where other packages can register their own. The stages to use, in
+// order, and their parameters, are declared in the config file.
+
+// A stage as declared in the config file: the name it was registered under, and its parameters (if any)
+type Stage struct {
+	Name   string          `json:"stage"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+func (stage Stage) String() string {
+	return stage.Name
+}
+
+// Creates a stage from its parameters in the config file, which are empty if none were given
+type Factory[T any] func(params json.RawMessage) (T, error)
+
+// A stage which has been created, with its name for explaining its decisions
+type Named[T any] struct {
+	Name  string
+	Stage T
+}
+
+// The stages of one kind which can be declared in the config file. A Registry can be used from several
+// goroutines.
+type Registry[T any] struct {
+	kind      string
+	mutex     sync.Mutex
+	factories map[string]Factory[T]
+}
+
+// Creates a registry for the given kind of stage (e.g. "offer filter"), which is used in the errors
+func NewRegistry[T any](kind string) *Registry[T] {
+	registry := Registry[T]{kind: kind, factories: make(map[string]Factory[T])}
+	return &registry
+}
+
+// Makes a stage available under the given name. Like http.Handle, this panics if the name has already
+// been registered, since that is a programming error.
+func (registry *Registry[T]) Register(name string, factory Factory[T]) {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, found := registry.factories[name]; found {
+		panic(fmt.Sprintf("%s %q is already registered", registry.kind, name))
+	}
+	registry.factories[name] = factory
+}
+
+// Gets the sorted names of the registered stages
+func (registry *Registry[T]) GetNames() []string {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Creates the given stages, in the same order
+func (registry *Registry[T]) Build(stages []Stage) ([]Named[T], error) {
+
+	results := make([]Named[T], 0, len(stages))
+	for _, stage := range stages {
+		registry.mutex.Lock()
+		factory, found := registry.factories[stage.Name]
+		registry.mutex.Unlock()
+		if !found {
+			return nil, fmt.Errorf("unknown %s %q (expected one of %s)", registry.kind, stage.Name, strings.Join(registry.GetNames(), ", "))
+		}
+
+		created, err := factory(stage.Params)
+		if err != nil {
+			return nil, fmt.Errorf("creating %s %q: %w", registry.kind, stage.Name, err)
+		}
+		results = append(results, Named[T]{Name: stage.Name, Stage: created})
+	}
+
+	return results, nil
+}
+
+// Gets the stages with the given names and no parameters, e.g. for the default stages
+func GetStages(names ...string) []Stage {
+
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		stages = append(stages, Stage{Name: name})
+	}
+	return stages
+}
+
+// Reads the parameters of a stage into the given value, rejecting any unknown ones. Nothing is read if no
+// parameters were given.
+func DecodeParams(params json.RawMessage, target any) error {
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("parsing parameters: %w", err)
+	}
+	return nil
+}
+
+// Checks that a stage has no parameters, for the stages which don't take any
+func NoParams(params json.RawMessage) error {
+
+	if len(bytes.TrimSpace(params)) > 0 && !bytes.Equal(bytes.TrimSpace(params), []byte("null")) {
+		return fmt.Errorf("no parameters are expected, but got %s", params)
+	}
+	return nil
+}
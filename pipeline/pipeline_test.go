@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func getTestRegistry() *Registry[string] {
+
+	registry := NewRegistry[string]("test stage")
+	registry.Register("upper", func(params json.RawMessage) (string, error) {
+		return "UPPER", NoParams(params)
+	})
+	registry.Register("prefix", func(params json.RawMessage) (string, error) {
+		var settings struct {
+			Text string `json:"text"`
+		}
+		err := DecodeParams(params, &settings)
+		return settings.Text, err
+	})
+	return registry
+}
+
+func TestBuild(t *testing.T) {
+
+	// Arrange
+	registry := getTestRegistry()
+	var stages []Stage
+	if err := json.Unmarshal([]byte(`[{"stage": "prefix", "params": {"text": "> "}}, {"stage": "upper", "params": null}, {"stage": "prefix"}]`), &stages); err != nil {
+		t.Fatalf("Unable to parse stages: %v", err)
+	}
+
+	// Act
+	actual, err := registry.Build(stages)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	expected := []Named[string]{{"prefix", "> "}, {"upper", "UPPER"}, {"prefix", ""}}
+	if len(actual) != len(expected) {
+		t.Fatalf("Got %v; Expected %v", actual, expected)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("Got stage %d %v; Expected %v", i, actual[i], expected[i])
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+
+	tests := []struct {
+		stages   string
+		expected string
+	}{
+		{`[{"stage": "lower"}]`, `unknown test stage "lower" (expected one of prefix, upper)`},
+		{`[{"stage": "upper", "params": {"text": "x"}}]`, `creating test stage "upper": no parameters are expected, but got {"text": "x"}`},
+		{`[{"stage": "prefix", "params": {"txt": "x"}}]`, `creating test stage "prefix": parsing parameters: json: unknown field "txt"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.stages, func(t *testing.T) {
+
+			// Arrange
+			registry := getTestRegistry()
+			var stages []Stage
+			if err := json.Unmarshal([]byte(test.stages), &stages); err != nil {
+				t.Fatalf("Unable to parse stages: %v", err)
+			}
+
+			// Act
+			_, err := registry.Build(stages)
+
+			// Assert
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Got error %v; Expected %q", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsDuplicateName(t *testing.T) {
+
+	// Arrange
+	registry := getTestRegistry()
+	defer func() {
+
+		// Assert
+		if recovered := recover(); recovered == nil || !strings.Contains(recovered.(string), `test stage "upper" is already registered`) {
+			t.Errorf("Got %v; Expected a panic for the duplicate name", recovered)
+		}
+	}()
+
+	// Act
+	registry.Register("upper", func(params json.RawMessage) (string, error) { return "", nil })
+}
@@ -1,17 +1,19 @@
 package pricing
 
-// Relevant partial models for the Amadeus REST API responses
+import "encoding/json"
 
-// Response model for requests to get API Bearer token
-type amadeusToken struct {
-	AccessToken string `json:"access_token"`
-	Expiry      int    `json:"expires_in"`
-}
+// Relevant partial models for the Amadeus REST API responses
 
 // Top-level response model for flight searches
 type flightSearchResponse struct {
-	Metadata offersMetadata `json:"meta"`
-	Flights  []flightOffer  `json:"data"`
+	Metadata     offersMetadata `json:"meta"`
+	Flights      []flightOffer  `json:"data"`
+	Dictionaries dictionaries   `json:"dictionaries"`
+}
+
+// The names of the codes used in the offers
+type dictionaries struct {
+	Aircraft map[string]string `json:"aircraft"`
 }
 
 // General information about flight offers returned
@@ -21,15 +23,109 @@ type offersMetadata struct {
 
 // A single offer within the flight search response
 type flightOffer struct {
-	SeatsAvailable int         `json:"numberOfBookableSeats"`
-	Price          price       `json:"price"`
-	Itineraries    []itinerary `json:"itineraries"`
+	Id                string            `json:"id"`
+	LastTicketingDate string            `json:"lastTicketingDate"`
+	SeatsAvailable    int               `json:"numberOfBookableSeats"`
+	Price             price             `json:"price"`
+	Itineraries       []itinerary       `json:"itineraries"`
+	TravelerPricings  []travelerPricing `json:"travelerPricings"`
+
+	// The complete offer, exactly as Amadeus returned it, which must be sent back unchanged
+	// in order to reconfirm the price
+	raw json.RawMessage
+}
+
+// Keeps a copy of the complete offer while parsing the partial model
+func (offer *flightOffer) UnmarshalJSON(data []byte) error {
+
+	type partialOffer flightOffer
+	if err := json.Unmarshal(data, (*partialOffer)(offer)); err != nil {
+		return err
+	}
+
+	offer.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Sends the complete offer back to Amadeus, if it was parsed from an Amadeus response
+func (offer flightOffer) MarshalJSON() ([]byte, error) {
+
+	if offer.raw != nil {
+		return offer.raw, nil
+	}
+
+	type partialOffer flightOffer
+	return json.Marshal(partialOffer(offer))
+}
+
+// Request model for the flight offers price endpoint
+type flightPriceRequest struct {
+	Data flightPriceData `json:"data"`
+}
+
+// Response model for the flight offers price endpoint
+type flightPriceResponse struct {
+	Data flightPriceData `json:"data"`
+}
+
+// The offers to be priced (in the request) or the offers with the confirmed prices (in the response)
+type flightPriceData struct {
+	Type   string        `json:"type"`
+	Offers []flightOffer `json:"flightOffers"`
 }
 
 // Price of the flight offer
 type price struct {
-	Total    string `json:"grandTotal"`
-	Currency string `json:"currency"`
+	Total              string              `json:"grandTotal"`
+	FareTotal          string              `json:"total"`
+	Base               string              `json:"base"`
+	Fees               []fee               `json:"fees"`
+	AdditionalServices []additionalService `json:"additionalServices"`
+	Currency           string              `json:"currency"`
+}
+
+// Fee included in the total price (e.g. SUPPLIER or TICKETING)
+type fee struct {
+	Amount string `json:"amount"`
+	Type   string `json:"type"`
+}
+
+// Price of an optional service which is not included in the total price (e.g. CHECKED_BAGS)
+type additionalService struct {
+	Amount string `json:"amount"`
+	Type   string `json:"type"`
+}
+
+// Fare information for a single traveler
+type travelerPricing struct {
+	TravelerType string        `json:"travelerType"`
+	Segments     []fareDetails `json:"fareDetailsBySegment"`
+}
+
+// Fare information for a single traveler on a single flight
+type fareDetails struct {
+	SegmentId    string      `json:"segmentId"`
+	Cabin        string      `json:"cabin"`
+	FareBasis    string      `json:"fareBasis"`
+	Brand        string      `json:"brandedFare"`
+	BrandLabel   string      `json:"brandedFareLabel"`
+	Class        string      `json:"class"`
+	IncludedBags checkedBags `json:"includedCheckedBags"`
+	Amenities    []amenity   `json:"amenities"`
+}
+
+// Service which is part of the fare brand, and may or may not cost extra
+type amenity struct {
+	Description  string `json:"description"`
+	IsChargeable bool   `json:"isChargeable"`
+	Type         string `json:"amenityType"`
+}
+
+// Checked baggage allowance, given either as a number of bags or as a total weight
+type checkedBags struct {
+	Quantity   int    `json:"quantity"`
+	Weight     int    `json:"weight"`
+	WeightUnit string `json:"weightUnit"`
 }
 
 // Full multi-segment flight information
@@ -43,6 +139,12 @@ type flight struct {
 	Arrival   flightTime `json:"arrival"`
 	Airline   string     `json:"carrierCode"`
 	Number    string     `json:"number"`
+	Aircraft  aircraft   `json:"aircraft"`
+}
+
+// Type of aircraft used for a flight
+type aircraft struct {
+	Code string `json:"code"` // IATA aircraft code, e.g. 73H
 }
 
 // Arrival or Departure time and location
@@ -0,0 +1,127 @@
+package pricing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Decision about a single flight offer, i.e. whether it was chosen as the cheapest flight, and why
+type OfferVerdict struct {
+	OfferId      string
+	FlightNumber string
+	Route        string // e.g. OSL-CPH, or OSL-ARN-CPH for a connecting flight
+	Price        string
+	Accepted     bool
+	Reason       string
+}
+
+// Everything the search for a single destination considered
+type DestinationReport struct {
+	Origin      string
+	Destination string
+	Offers      []OfferVerdict
+
+	// Why the destination wasn't searched, or the search failed, if it did
+	Problem string
+}
+
+// Collects the decisions made about each destination and offer, so that a search can be explained. An
+// Audit is safe for concurrent use by the searches. A nil Audit records nothing.
+type Audit struct {
+	mutex   sync.Mutex
+	reports map[string]*DestinationReport // By route, e.g. OSL-CPH
+}
+
+func NewAudit() *Audit {
+	audit := Audit{reports: make(map[string]*DestinationReport)}
+	return &audit
+}
+
+// Gets the reports for all the routes searched, in order of destination and then origin
+func (audit *Audit) GetReports() []DestinationReport {
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	reports := make([]DestinationReport, 0, len(audit.reports))
+	for _, report := range audit.reports {
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Destination != reports[j].Destination {
+			return reports[i].Destination < reports[j].Destination
+		}
+		return reports[i].Origin < reports[j].Origin
+	})
+
+	return reports
+}
+
+// Records the offers considered for a destination
+func (audit *Audit) recordOffers(origin string, destination string, verdicts []OfferVerdict) {
+
+	if audit == nil {
+		return
+	}
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	report := audit.getReport(origin, destination)
+	report.Offers = append(report.Offers, verdicts...)
+}
+
+// Records why a destination wasn't searched, or why its search failed
+func (audit *Audit) recordProblem(origin string, destination string, problem string) {
+
+	if audit == nil {
+		return
+	}
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	audit.getReport(origin, destination).Problem = problem
+}
+
+// Gets the report for a route, creating it if needed. This is called while the mutex is held.
+func (audit *Audit) getReport(origin string, destination string) *DestinationReport {
+
+	key := origin + "-" + destination
+	report, found := audit.reports[key]
+	if !found {
+		report = &DestinationReport{Origin: origin, Destination: destination, Offers: make([]OfferVerdict, 0)}
+		audit.reports[key] = report
+	}
+	return report
+}
+
+// Gets the verdict for an offer, before its price is known
+func newOfferVerdict(offer *flightOffer) OfferVerdict {
+
+	verdict := OfferVerdict{OfferId: offer.Id, Price: offer.Price.Total + " " + offer.Price.Currency}
+
+	numbers := make([]string, 0)
+	airports := make([]string, 0)
+	for _, itinerary := range offer.Itineraries {
+		for _, segment := range itinerary.Segments {
+			numbers = append(numbers, segment.Airline+segment.Number)
+			if len(airports) == 0 {
+				airports = append(airports, segment.Departure.Airport)
+			}
+			airports = append(airports, segment.Arrival.Airport)
+		}
+	}
+	verdict.FlightNumber = strings.Join(numbers, "+")
+	verdict.Route = strings.Join(airports, "-")
+
+	return verdict
+}
+
+// Marks an offer as rejected, with the rule which rejected it
+func (verdict *OfferVerdict) reject(format string, args ...any) {
+	verdict.Accepted = false
+	verdict.Reason = fmt.Sprintf(format, args...)
+}
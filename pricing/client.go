@@ -0,0 +1,27 @@
+package pricing
+
+import "flynow/amadeus"
+
+// Client for the Amadeus-based price searches
+type Client struct {
+	amadeus *amadeus.Client
+
+	// Limits the number of searches performed at the same time
+	semaphore chan struct{}
+}
+
+// Creates a price client which uses the given Amadeus client (e.g. for a different environment, or a fake
+// server), and performs at most the given number of searches at the same time
+func NewClient(amadeusClient *amadeus.Client, concurrency int) *Client {
+
+	client := Client{amadeus: amadeusClient, semaphore: make(chan struct{}, max(concurrency, 1))}
+	return &client
+}
+
+// Waits until fewer than the maximum number of searches are in progress, and returns a function to be
+// called once the search is complete
+func (priceClient *Client) acquire() (release func()) {
+
+	priceClient.semaphore <- struct{}{}
+	return func() { <-priceClient.semaphore }
+}
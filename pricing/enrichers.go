@@ -0,0 +1,81 @@
+package pricing
+
+import (
+	"encoding/json"
+	"flynow/airports"
+	"flynow/emissions"
+	"flynow/pipeline"
+	"sync"
+)
+
+// The enrichers add details to the cheapest flight to each destination, after it has been chosen. The
+// built-in enrichers are registered here, and others can be added with RegisterEnricher. Unless the config
+// gives the enrichers to use, the defaults are used, in this order:
+//
+//	emissions  the great-circle distance, and the estimated CO2 per economy passenger
+
+// Adds details to a chosen flight
+type Enricher interface {
+	Enrich(search OfferSearch, flight *FlightForPurchase)
+}
+
+// Adapts an ordinary function to the Enricher interface
+type EnricherFunc func(search OfferSearch, flight *FlightForPurchase)
+
+func (f EnricherFunc) Enrich(search OfferSearch, flight *FlightForPurchase) {
+	f(search, flight)
+}
+
+var enrichers = newEnricherRegistry()
+
+var defaultEnrichers = []string{"emissions"}
+
+// Makes an enricher available to the config, under the given name. This panics if the name is already
+// used, e.g. by one of the built-in enrichers.
+func RegisterEnricher(name string, factory pipeline.Factory[Enricher]) {
+	enrichers.Register(name, factory)
+}
+
+// Gets the names of the enrichers which can be used in the config
+func GetEnricherNames() []string {
+	return enrichers.GetNames()
+}
+
+// Creates the given enrichers, in the same order, or the default enrichers if none are given
+func BuildEnrichers(stages []pipeline.Stage) ([]pipeline.Named[Enricher], error) {
+
+	if len(stages) == 0 {
+		stages = pipeline.GetStages(defaultEnrichers...)
+	}
+	return enrichers.Build(stages)
+}
+
+// The built-in enrichers take no parameters, so building the defaults can't fail
+var getDefaultEnrichers = sync.OnceValue(func() []pipeline.Named[Enricher] {
+
+	results, err := BuildEnrichers(nil)
+	if err != nil {
+		panic(err)
+	}
+	return results
+})
+
+func newEnricherRegistry() *pipeline.Registry[Enricher] {
+
+	registry := pipeline.NewRegistry[Enricher]("enricher")
+	registry.Register("emissions", func(params json.RawMessage) (Enricher, error) {
+		return EnricherFunc(addEmissions), pipeline.NoParams(params)
+	})
+	return registry
+}
+
+// Adds the distance and CO2 estimate, if both airports are in the airport database
+func addEmissions(search OfferSearch, flight *FlightForPurchase) {
+
+	origin, originFound := airports.Lookup(flight.Origin)
+	destination, destinationFound := airports.Lookup(flight.Destination)
+	if originFound && destinationFound {
+		flight.Distance = origin.GetDistanceTo(destination)
+		flight.Co2 = emissions.Estimate(flight.Distance, flight.Aircraft)
+	}
+}
@@ -0,0 +1,196 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Details of the fare brand and booking class of a flight
+type FareDetails struct {
+	Brand        string // e.g. LOWFARE
+	BrandLabel   string // e.g. SAS GO LIGHT
+	FareBasis    string
+	BookingClass string
+	Cabin        string // e.g. ECONOMY
+	Amenities    []Amenity
+}
+
+// A service which is part of the fare brand. Chargeable amenities are available, but cost extra.
+type Amenity struct {
+	Description string
+	Type        string
+	Category    string // One of the Amenity* constants, or empty if the amenity isn't recognized
+	Chargeable  bool
+}
+
+// Categories of amenities, which can be used to filter fares. Airlines describe their amenities
+// in free text, so these are recognized by keywords at the start of a word in the description (or the
+// amenity type).
+const (
+	AmenityCabinBag   = "cabin-bag"
+	AmenityCheckedBag = "checked-bag"
+	AmenitySeat       = "seat"
+	AmenityFastTrack  = "fast-track"
+	AmenityChangeable = "changeable"
+	AmenityRefundable = "refundable"
+	AmenityMeal       = "meal"
+	AmenityWifi       = "wifi"
+)
+
+var amenityKeywords = []struct {
+	category string
+	keywords []string
+}{
+	// Note: "UNDERSEAT CARRY ON" is a personal item, rather than a cabin bag, so the more
+	// specific matches need to come first
+	{"", []string{"UNDERSEAT"}},
+	{AmenityCabinBag, []string{"CABIN BAG", "CARRY ON", "CARRY-ON", "CARRY"}},
+	{AmenityCheckedBag, []string{"CHECKED BAG", "CHARGEABLE BAG"}},
+	{AmenityFastTrack, []string{"FAST TRACK"}},
+	{AmenityChangeable, []string{"CHANGEABLE", "CHANGE"}},
+	{AmenityRefundable, []string{"REFUND"}},
+	{AmenityWifi, []string{"INTERNET", "WIFI", "WI-FI"}},
+}
+
+var amenityTypes = map[string]string{
+	"PRE_RESERVED_SEAT": AmenitySeat,
+	"MEAL":              AmenityMeal,
+}
+
+// Gets the category of the amenity with the given description and Amadeus amenity type
+func getAmenityCategory(description string, amenityType string) string {
+
+	// Keywords only match at the start of a word, so that e.g. CHANGE matches CHANGEABLE but not EXCHANGE.
+	// Hyphenated words count as one, so NON-REFUNDABLE isn't taken as refundable.
+	words := " " + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return ' '
+	}, strings.ToUpper(description))
+
+	for _, k := range amenityKeywords {
+		for _, keyword := range k.keywords {
+			if strings.Contains(words, " "+keyword) {
+				return k.category
+			}
+		}
+	}
+
+	return amenityTypes[amenityType]
+}
+
+// Gets the fare details of the offer. Only single-flight offers are supported, so the details
+// for the first traveler's first flight are used.
+func getFareDetails(offer *flightOffer) (details FareDetails) {
+
+	if len(offer.TravelerPricings) == 0 || len(offer.TravelerPricings[0].Segments) == 0 {
+		return details
+	}
+	fare := offer.TravelerPricings[0].Segments[0]
+
+	details.Brand = fare.Brand
+	details.BrandLabel = fare.BrandLabel
+	details.FareBasis = fare.FareBasis
+	details.BookingClass = fare.Class
+	details.Cabin = fare.Cabin
+
+	details.Amenities = make([]Amenity, 0, len(fare.Amenities))
+	for _, a := range fare.Amenities {
+		details.Amenities = append(details.Amenities, Amenity{
+			Description: a.Description,
+			Type:        a.Type,
+			Category:    getAmenityCategory(a.Description, a.Type),
+			Chargeable:  a.IsChargeable,
+		})
+	}
+
+	return details
+}
+
+// Checks whether the fare includes an amenity of the given category free of charge
+func (details FareDetails) Includes(category string) bool {
+
+	for _, a := range details.Amenities {
+		if a.Category == category && !a.Chargeable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Gets the amenities which are available, but cost extra
+func (details FareDetails) GetChargeableAmenities() []Amenity {
+
+	chargeable := make([]Amenity, 0, len(details.Amenities))
+	for _, a := range details.Amenities {
+		if a.Chargeable {
+			chargeable = append(chargeable, a)
+		}
+	}
+
+	return chargeable
+}
+
+// Gets a display name for the fare brand
+func (details FareDetails) GetBrandName() string {
+
+	if details.BrandLabel != "" {
+		return details.BrandLabel
+	}
+	return details.Brand
+}
+
+// Fare requirements for a search. Empty fields are not checked.
+type FareFilter struct {
+	ExcludedBrands    []string // e.g. LOWFARE
+	Cabins            []string // e.g. ECONOMY or PREMIUM_ECONOMY
+	RequiredAmenities []string // Amenity categories which must be included free of charge
+}
+
+// Checks that the required amenities are known categories
+func (filter FareFilter) Validate() error {
+
+	known := []string{AmenityCabinBag, AmenityCheckedBag, AmenitySeat, AmenityFastTrack, AmenityChangeable, AmenityRefundable, AmenityMeal, AmenityWifi}
+
+	for _, required := range filter.RequiredAmenities {
+		if !containsFold(known, required) {
+			return fmt.Errorf("unknown amenity %q (expected one of: %s)", required, strings.Join(known, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Checks whether the given fare satisfies the filter. If not, the reason is returned.
+func (filter FareFilter) Allows(details FareDetails) (allowed bool, reason string) {
+
+	if containsFold(filter.ExcludedBrands, details.Brand) {
+		return false, fmt.Sprintf("fare brand %s is excluded", details.Brand)
+	}
+
+	if len(filter.Cabins) > 0 && !containsFold(filter.Cabins, details.Cabin) {
+		return false, fmt.Sprintf("cabin %s is not allowed", details.Cabin)
+	}
+
+	for _, required := range filter.RequiredAmenities {
+		if !details.Includes(strings.ToLower(required)) {
+			return false, fmt.Sprintf("fare does not include %s", required)
+		}
+	}
+
+	return true, ""
+}
+
+func containsFold(values []string, value string) bool {
+
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
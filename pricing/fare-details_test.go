@@ -0,0 +1,36 @@
+package pricing
+
+import "testing"
+
+func TestGetAmenityCategory(t *testing.T) {
+
+	tests := []struct {
+		description string
+		amenityType string
+		expected    string
+	}{
+		{"CHANGEABLE TICKET", "BRANDED_FARES", AmenityChangeable},
+		{"CHANGE BEFORE DEPARTURE", "BRANDED_FARES", AmenityChangeable},
+		{"EXCHANGE FEE APPLIES", "BRANDED_FARES", ""},
+		{"REFUNDABLE TICKET", "BRANDED_FARES", AmenityRefundable},
+		{"NON-REFUNDABLE TICKET", "BRANDED_FARES", ""},
+		{"CARRY8KG 18LB UPTO 45LI 115LCM", "BAGGAGE", AmenityCabinBag},
+		{"UNDERSEAT CARRY ON UP TO 88CM", "BAGGAGE", ""},
+		{"1 CHECKED BAG UP TO 23KG", "BAGGAGE", AmenityCheckedBag},
+		{"Wi-Fi on board", "BRANDED_FARES", AmenityWifi},
+		{"PRE RESERVED SEAT ASSIGNMENT", "PRE_RESERVED_SEAT", AmenitySeat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+
+			// Act
+			actual := getAmenityCategory(test.description, test.amenityType)
+
+			// Assert
+			if actual != test.expected {
+				t.Errorf("Got category %q; Expected %q", actual, test.expected)
+			}
+		})
+	}
+}
@@ -1,140 +1,133 @@
 package pricing
 
 import (
-	"encoding/json"
 	"errors"
-	"flynow/config"
+	"flynow/amadeus"
+	"flynow/apierror"
 	"fmt"
-	"io"
-	"math/rand"
-	"net/http"
+	"log/slog"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// The outcome of the search for a single destination: the cheapest flight which met the criteria, if any,
+// or why the search failed
+type DestinationResult struct {
+	Origin      string
+	Destination string
+	Flight      FlightForPurchase
+	Found       bool
+	Err         error
+}
+
 // Given a departure airport code, and a list of possible destination airports,
 // search for flight options, and identify the cheapest flight to each one.
 // Note: Although the Amadeus API does include an open-ended flight search, it does
 // not appear to be supported for OSL
-func FindPrices(origin string, destinations []string, currencyCode string) (results []FlightForPurchase, err error) {
-
-	// Get Authorization token for Amadeus API
-	token, err := getAmadeusToken()
+func (priceClient *Client) FindPrices(origin string, destinations []string, currencyCode string, options SearchOptions) (results []FlightForPurchase, err error) {
+
+	// Create a slice with all the results, and combine any errors (hopefully none🤞)
+	results = make([]FlightForPurchase, 0, len(destinations))
+	var errs []error
+	err = priceClient.StreamPrices(origin, destinations, currencyCode, options, func(result DestinationResult) bool {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		} else if result.Found {
+			results = append(results, result.Flight)
+		}
+		return true
+	})
 	if err != nil {
-		return results, fmt.Errorf("creating HTTP request: %w", err)
-	}
-	// TODO: If this method is called more than once (i.e. as part of a service), cache the token based on its expiry
-
-	// Create channels to store flight options and error information
-	flights := make(chan FlightForPurchase)
-	errs := make(chan error)
-
-	// Perform a flight search for each destination
-	wg := new(sync.WaitGroup)
-	for _, destCode := range destinations {
-		wg.Add(1)
-		go func(destCode string) {
-			defer wg.Done()
-			getCheapestFlight(origin, destCode, currencyCode, token, flights, errs)
-		}(destCode)
+		return nil, err
 	}
 
-	// Wait until all searches have completed
-	go func(wg *sync.WaitGroup, flights chan FlightForPurchase, errs chan error) {
-		wg.Wait()
-		close(flights)
-		close(errs)
-	}(wg, flights, errs)
-
-	// Combine any errors (hopefully none🤞)
 	if len(errs) > 0 {
-		err = errors.New("finding flight prices: ")
-		for nextErr := range errs {
+		err = errors.New("finding flight prices")
+		for _, nextErr := range errs {
 			err = fmt.Errorf("%w; %w", err, nextErr)
 		}
 		return nil, err
 	}
 
-	// Create a slice with all the results
-	results = make([]FlightForPurchase, 0, len(flights))
-	for f := range flights {
-		// Skip any unitialized struct values - Is there a best practice for this?
-		if f.FlightNumber != "" {
-			results = append(results, f)
-		}
-	}
-
 	return results, nil
 }
 
-// Performs a REST call to the Amadeus token endpoint to get a Bearer token for use in all
-// subsequent Amadeus API requests. Note that a valid client ID and client secret must be provided
-// but have not been checked into source code.
-func getAmadeusToken() (string, error) {
-
-	const tokenEndpoint = "https://test.api.amadeus.com/v1/security/oauth2/token"
-
-	// TODO: Read these from secure storage
-	clientId, clientSecret := config.GetAmadeusCredentials()
-
-	// Construct the POST request body as URL-encoded form data
-	bodyData := url.Values{}
-	bodyData.Set("grant_type", "client_credentials")
-	bodyData.Set("client_id", clientId)
-	bodyData.Set("client_secret", clientSecret)
-	body := bodyData.Encode()
+// Searches for the cheapest flight to each destination in the same way as FindPrices, but hands over the
+// result for each destination as soon as its search finishes, including a failed search, rather than
+// waiting for all of them. The results are given to yield one at a time (never at the same time), in the
+// order the searches finish. If yield returns false, no more searches are started, and the ones already
+// running are left to finish without their results being given. An error is only returned if the search
+// couldn't be started at all, e.g. because the credentials aren't accepted.
+func (priceClient *Client) StreamPrices(origin string, destinations []string, currencyCode string, options SearchOptions, yield func(result DestinationResult) bool) error {
 
-	request, err := http.NewRequest(http.MethodPost, tokenEndpoint, strings.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("creating HTTP request: %w", err)
+	if err := options.Airlines.Validate(); err != nil {
+		return fmt.Errorf("checking airline filter: %w", err)
+	}
+	if err := options.Fares.Validate(); err != nil {
+		return fmt.Errorf("checking fare filter: %w", err)
 	}
 
-	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	// Get Authorization token for Amadeus API, so that an invalid client ID or secret is reported
+	// once, rather than once per search
+	client := priceClient.amadeus
+	if err := client.Authenticate(); err != nil {
+		return fmt.Errorf("authenticating with Amadeus: %w", err)
+	}
 
-	var httpClient = &http.Client{}
+	// The channel is buffered, so that a search never blocks once the results are no longer wanted
+	results := make(chan DestinationResult, len(destinations))
+	var stopped atomic.Bool
 
-	// Call the token API
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return "", fmt.Errorf("requesting Amadeus token: %w", err)
+	// Perform a flight search for each destination, with at most the configured number at the same time
+	wg := new(sync.WaitGroup)
+	for _, destCode := range destinations {
+		wg.Add(1)
+		go func(destCode string) {
+			defer wg.Done()
+			defer priceClient.acquire()()
+			if stopped.Load() {
+				return
+			}
+			found, flight, err := getCheapestFlight(client, origin, destCode, currencyCode, options)
+			results <- DestinationResult{Origin: origin, Destination: destCode, Flight: flight, Found: found, Err: err}
+		}(destCode)
 	}
 
-	if response.StatusCode != 200 {
-		return "", fmt.Errorf("unexpected response code (%d) getting token", response.StatusCode)
-	}
+	// Wait until all searches have completed
+	go func(wg *sync.WaitGroup, results chan DestinationResult) {
+		wg.Wait()
+		close(results)
+	}(wg, results)
 
-	// Read and parse the response data
-	responseBody, err := io.ReadAll(response.Body)
-	if err != nil {
-		return "", fmt.Errorf("reading response body: %w", err)
+	// Hand over each result as it arrives
+	for result := range results {
+		if !stopped.Load() && !yield(result) {
+			stopped.Store(true)
+		}
 	}
 
-	var jsonToken amadeusToken
-	json.Unmarshal(responseBody, &jsonToken)
-
-	return jsonToken.AccessToken, nil
+	return nil
 }
 
 // Performs a REST call to the Amadeus flight search API to retrieve flight offers for direct flights on the given route,
 // departing today. The results are then evaluated to identify the cheapest option.
-func getCheapestFlight(originCode string, destCode string, currencyCode string, token string, flights chan FlightForPurchase, errs chan error) {
+func getCheapestFlight(client *amadeus.Client, originCode string, destCode string, currencyCode string, options SearchOptions) (found bool, result FlightForPurchase, err error) {
 
-	const searchEndpoint = "https://test.api.amadeus.com/v2/shopping/flight-offers"
+	const searchPath = "/v2/shopping/flight-offers"
 	today := time.Now()
 
-	// Construct the API request
-	request, err := http.NewRequest(http.MethodGet, searchEndpoint, nil)
-	if err != nil {
-		errs <- fmt.Errorf("creating HTTP request: %w", err)
+	// Skip the search entirely if the airline filter excludes every airline
+	allowedAirlines := options.Airlines.AllowedCodes()
+	if allowedAirlines != nil && len(allowedAirlines) == 0 {
+		options.Audit.recordProblem(originCode, destCode, "not searched, since the airline filter excludes every airline")
+		return false, result, nil
 	}
 
-	// Set the authorization header
-	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
 	// Set the query parameters
-	query := request.URL.Query()
+	query := url.Values{}
 	query.Add("originLocationCode", originCode)
 	query.Add("destinationLocationCode", destCode)
 	query.Add("departureDate", today.Format("2006-01-02"))
@@ -142,107 +135,136 @@ func getCheapestFlight(originCode string, destCode string, currencyCode string,
 	query.Add("travelClass", "ECONOMY")
 	query.Add("nonStop", "true")
 	query.Add("currencyCode", currencyCode)
-	request.URL.RawQuery = query.Encode()
-
-	httpClient := &http.Client{}
-
-	var response *http.Response
-	backoffSeconds := int32(1)
-	for response == nil || response.StatusCode == 429 {
 
-		// Call the API
-		response, err = httpClient.Do(request)
-		if err != nil {
-			errs <- fmt.Errorf("getting Amadeus flights: %w", err)
-			return
-		}
-
-		// Perform an exponential backoff & retry on 429
-		if response.StatusCode == 429 {
-			backoff := ((backoffSeconds * 1000) + rand.Int31n(500))
-			duration := time.Duration(backoff) * time.Millisecond
-			time.Sleep(duration)
-			backoffSeconds = backoffSeconds * 2
-		}
+	// Amadeus does not allow included and excluded airlines to be combined, but since the
+	// allow-list has already had the exclusions removed, only one of them is ever needed
+	if allowedAirlines != nil {
+		query.Add("includedAirlineCodes", strings.Join(allowedAirlines, ","))
+	} else if excluded := options.Airlines.ExcludedCodes(); len(excluded) > 0 {
+		query.Add("excludedAirlineCodes", strings.Join(excluded, ","))
 	}
 
-	if response.StatusCode != 200 {
-		errs <- fmt.Errorf("unexpected response code (%d) searching for flights to %s", response.StatusCode, destCode)
-		return
-	}
+	// Call the API
+	var flightResults flightSearchResponse
+	start := time.Now()
+	if err := client.Get(searchPath, query, &flightResults); err != nil {
+
+		// A destination which Amadeus doesn't know (e.g. a heliport listed in the schedule) is skipped, rather
+		// than failing the whole search
+		var apiErr *apierror.Error
+		if errors.As(err, &apiErr) && errors.Is(err, apierror.ErrInvalidAirport) && apiErr.Parameter == "destinationLocationCode" {
+			slog.Warn("Skipping destination which Amadeus doesn't accept", "origin", originCode, "destination", destCode, "error", err)
+			options.Audit.recordProblem(originCode, destCode, "skipped, since Amadeus doesn't accept it as a destination: "+err.Error())
+			return false, result, nil
+		}
 
-	// Read and parse the response
-	responseBody, err := io.ReadAll(response.Body)
-	if err != nil {
-		errs <- fmt.Errorf("reading response body: %w", err)
-		return
+		options.Audit.recordProblem(originCode, destCode, "search failed: "+err.Error())
+		return false, result, fmt.Errorf("searching for flights to %s: %w", destCode, err)
 	}
 
-	var flightResults flightSearchResponse
-	json.Unmarshal(responseBody, &flightResults)
+	slog.Debug("Searched for flights", "origin", originCode, "destination", destCode, "offers", len(flightResults.Flights), "latency", time.Since(start))
 
 	// Find the cheapest option (if any) that actually matches the input criteria
-	if found, flight := evaluateFlights(&flightResults, originCode, destCode, currencyCode); found {
-		flights <- flight
-	}
+	found, result = evaluateFlights(&flightResults, originCode, destCode, currencyCode, options)
+	return found, result, nil
 }
 
 // Given the parsed JSON response from the flight search, identify the cheapest flight offer that
 // matches the given input parameters. Although the flight search *should* return only direct flights
 // between the origin and destination, there is some room for discrepancy. For example, Amadeus may
-// return flights from TRF, even though the IATA code "OSL" specifically designates Gardermoen
-func evaluateFlights(response *flightSearchResponse, originCode string, destCode string, currencyCode string) (found bool, result FlightForPurchase) {
+// return flights from TRF, even though the IATA code "OSL" specifically designates Gardermoen. These are
+// only accepted when TRF is one of the nearby origins, i.e. when searching from the whole Oslo area.
+// The checks are made by the offer filters (see offer-filters.go), and the chosen flight is then passed to
+// the enrichers.
+func evaluateFlights(response *flightSearchResponse, originCode string, destCode string, currencyCode string, options SearchOptions) (found bool, result FlightForPurchase) {
+
+	logger := slog.With("origin", originCode, "destination", destCode)
 
 	// Confirm the count is correct
 	if response.Metadata.Count != len(response.Flights) {
-		logWarning(fmt.Sprintf("Response contained %d flight offers, but  Count was %d", len(response.Flights), response.Metadata.Count))
+		logger.Warn("Flight offer count doesn't match the offers", "offers", len(response.Flights), "count", response.Metadata.Count)
 	}
 
 	// Return false if there are no results
 	if len(response.Flights) == 0 {
+		options.Audit.recordProblem(originCode, destCode, "no offers were returned")
 		return false, result
 	}
 
+	search := OfferSearch{Origin: originCode, Destination: destCode, Currency: currencyCode, Options: options}
+	filters := options.OfferFilters
+	if filters == nil {
+		filters = getDefaultOfferFilters()
+	}
+
 	var cheapestFlight *flightOffer = nil
+	var cheapestPrice PriceBreakdown
+
+	// The decision about each offer, for explaining the search
+	verdicts := make([]OfferVerdict, len(response.Flights))
+	priced := make([]int, 0)
+	cheapestIndex := -1
 
 	// Loop over the offers to find the cheapest one
-	for i := 0; i < response.Metadata.Count; i++ {
+	for i := 0; i < len(response.Flights); i++ {
 		offer := response.Flights[i]
-
-		// Verify that it's a single-leg flight
-		if len(offer.Itineraries) > 1 || len(offer.Itineraries[0].Segments) > 1 {
-			logWarning("Offer contained more than one flight")
+		offerLogger := logger.With("offer", offer.Id)
+		verdict := &verdicts[i]
+		*verdict = newOfferVerdict(&offer)
+
+		// Run the offer through the filters
+		candidate := newOffer(&offer)
+		if len(candidate.getOutbound()) == 0 {
+			offerLogger.Debug("Rejected offer without any flights")
+			verdict.reject("no flights in the offer")
+			continue
+		}
+		if accepted, filter, reason := checkOffer(filters, search, &candidate); !accepted {
+			offerLogger.Debug("Rejected offer", "filter", filter, "reason", reason)
+			verdict.reject("%s", reason)
 			continue
 		}
-		flightInfo := offer.Itineraries[0].Segments[0]
 
-		// Verify the airport codes (i.e. NOT TORP!!! 😜)
-		if flightInfo.Departure.Airport != originCode || flightInfo.Arrival.Airport != destCode {
-			logWarning(fmt.Sprintf("Offer contained incorrect flight: %s - %s", flightInfo.Departure.Airport, flightInfo.Arrival.Airport))
+		// Check the price, including any checked bags which aren't part of the fare
+		breakdown, err := getPriceBreakdown(&offer, options.CheckedBags)
+		if err != nil {
+			offerLogger.Warn("Unable to price offer", "error", err)
+			verdict.reject("unable to price: %v", err)
 			continue
 		}
+		verdict.Price = formatPrice(breakdown.Total, offer.Price.Currency)
+		priced = append(priced, i)
+		if cheapestFlight == nil || breakdown.Total < cheapestPrice.Total {
+			cheapestFlight = &offer
+			cheapestPrice = breakdown
+			cheapestIndex = i
+		}
+	}
 
-		// Check the price
-		if cheapestFlight == nil || offer.Price.Total < cheapestFlight.Price.Total {
-			if offer.Price.Currency != currencyCode {
-				logWarning(fmt.Sprintf("Offer was in incorrect currency: %s", offer.Price.Currency))
-				continue
-			} else {
-				cheapestFlight = &offer
-			}
+	// Of the offers which met all the criteria, only the cheapest is used
+	for _, i := range priced {
+		if i == cheapestIndex {
+			verdicts[i].Accepted = true
+			verdicts[i].Reason = "the cheapest matching offer"
+		} else {
+			verdicts[i].reject("costs more than offer %s", verdicts[cheapestIndex].OfferId)
 		}
 	}
+	options.Audit.recordOffers(originCode, destCode, verdicts)
 
 	if cheapestFlight != nil {
-		result = convert(cheapestFlight)
+		result = convert(cheapestFlight, cheapestPrice)
+		result.AircraftName = response.Dictionaries.Aircraft[result.Aircraft]
+
+		enrichers := options.Enrichers
+		if enrichers == nil {
+			enrichers = getDefaultEnrichers()
+		}
+		for _, enricher := range enrichers {
+			enricher.Stage.Enrich(search, &result)
+		}
 		return true, result
 	}
 
 	return false, result
 }
-
-// Placeholder function for future logging enhancements
-func logWarning(message string) {
-	// TODO: Introduce a more robust logging infrastructure
-	//fmt.Printf("WARNING: %s\n", message)
-}
@@ -0,0 +1,248 @@
+package pricing
+
+import (
+	"encoding/json"
+	"flynow/airlines"
+	"flynow/amadeus"
+	"flynow/credentials"
+	"flynow/fakeapi"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluateFlights(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		options        SearchOptions
+		expectedFlight string
+		expectedPrice  float32
+	}{
+		{"cheapest fare", SearchOptions{}, "DY932", 47.41},
+		{"cheapest fare with a checked bag", SearchOptions{CheckedBags: 1}, "DY932", 64.41},
+		{"cheapest fare with an airline filter", SearchOptions{Airlines: airlines.Filter{Alliances: []string{"SkyTeam"}}}, "SK1477", 56.08},
+		{"cheapest fare with two checked bags", SearchOptions{CheckedBags: 2, Airlines: airlines.Filter{Include: []string{"SK"}}}, "SK1475", 96.08},
+		{"cheapest fare with an excluded brand", SearchOptions{Fares: FareFilter{ExcludedBrands: []string{"LOWFARE"}}}, "SK1477", 56.08},
+		{"cheapest fare with a free amenity", SearchOptions{Fares: FareFilter{RequiredAmenities: []string{AmenityWifi}}}, "DY932", 47.41},
+		{"fare from another airport in the metro area", SearchOptions{NearbyOrigins: []string{"TRF", "RYG"}, Airlines: airlines.Filter{Include: []string{"WF"}}}, "WF313", 65.00},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			fakeResponse, err := getFakeResponseData()
+			if err != nil {
+				t.Fatalf("Unable to parse test data: %v", err)
+			}
+
+			// Act
+			found, actual := evaluateFlights(&fakeResponse, "OSL", "CPH", "EUR", test.options)
+
+			// Assert
+			if !found {
+				t.Fatal("No flight found")
+			}
+			if actual.FlightNumber != test.expectedFlight {
+				t.Errorf("Found flight %s; Expected %s", actual.FlightNumber, test.expectedFlight)
+			}
+			if fmt.Sprintf("%.2f", actual.Price) != fmt.Sprintf("%.2f", test.expectedPrice) {
+				t.Errorf("Found price %.2f; Expected %.2f", actual.Price, test.expectedPrice)
+			}
+		})
+	}
+}
+
+func TestEvaluateFlightsExplainsEachOffer(t *testing.T) {
+
+	// Arrange
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Fatalf("Unable to parse test data: %v", err)
+	}
+	audit := NewAudit()
+	options := SearchOptions{Airlines: airlines.Filter{Exclude: []string{"SK"}}, Audit: audit}
+
+	// Act
+	_, cheapest := evaluateFlights(&fakeResponse, "OSL", "CPH", "EUR", options)
+	reports := audit.GetReports()
+
+	// Assert
+	if len(reports) != 1 || len(reports[0].Offers) != len(fakeResponse.Flights) {
+		t.Fatalf("Got reports %+v; Expected one verdict for each of the %d offers", reports, len(fakeResponse.Flights))
+	}
+	accepted := 0
+	for _, offer := range reports[0].Offers {
+		switch {
+		case offer.Accepted:
+			accepted++
+			if offer.OfferId != cheapest.OfferId {
+				t.Errorf("Accepted offer %s; Expected the cheapest offer %s", offer.OfferId, cheapest.OfferId)
+			}
+		case offer.Reason == "":
+			t.Errorf("Offer %s was rejected without a reason", offer.OfferId)
+		case strings.HasPrefix(offer.FlightNumber, "SK") && offer.Reason != "airline SK is excluded by the airline filter":
+			t.Errorf("Offer %s was rejected because %q; Expected the airline filter", offer.OfferId, offer.Reason)
+		}
+	}
+	if accepted != 1 {
+		t.Errorf("Accepted %d offers; Expected 1", accepted)
+	}
+}
+
+func TestStreamPricesStopsWhenAsked(t *testing.T) {
+
+	// Arrange
+	fake := fakeapi.NewAmadeus(t)
+	departure := time.Now().Add(2 * time.Hour)
+	fake.AddOffers(
+		fakeapi.NewOffer("DY", "932", "OSL", "CPH", departure, 70*time.Minute, 899, "NOK"),
+		fakeapi.NewOffer("SK", "484", "OSL", "ARN", departure, 55*time.Minute, 650, "NOK"),
+		fakeapi.NewOffer("DY", "610", "OSL", "BGO", departure, 50*time.Minute, 990, "NOK"),
+	)
+	fake.Offers.Latency = 50 * time.Millisecond
+	secrets := credentials.Static{credentials.AmadeusClientId: "id", credentials.AmadeusClientSecret: "secret"}
+	client := NewClient(amadeus.NewClient(fake.Url, secrets), 1)
+	destinations := []string{"CPH", "ARN", "BGO"}
+
+	// Act
+	results := make([]DestinationResult, 0)
+	err := client.StreamPrices("OSL", destinations, "NOK", SearchOptions{}, func(result DestinationResult) bool {
+		results = append(results, result)
+		return false
+	})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("StreamPrices failed: %v", err)
+	}
+	if len(results) != 1 || !results[0].Found || results[0].Flight.Destination != results[0].Destination {
+		t.Errorf("Got results %+v; Expected only the first flight", results)
+	}
+	if requests := fake.Offers.Requests(); requests >= len(destinations) {
+		t.Errorf("Made %d searches; Expected the searches which hadn't started to be skipped", requests)
+	}
+}
+
+func TestPriceBreakdown(t *testing.T) {
+
+	// Arrange
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Fatalf("Unable to parse test data: %v", err)
+	}
+
+	// Act
+	actual, err := getPriceBreakdown(&fakeResponse.Flights[0], 1)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "30.00 17.41 0.00 17.00 64.41"
+	if s := fmt.Sprintf("%.2f %.2f %.2f %.2f %.2f", actual.Base, actual.Taxes, actual.Fees, actual.Bags, actual.Total); s != expected {
+		t.Errorf("Found breakdown %s; Expected %s", s, expected)
+	}
+}
+
+func TestConvertUsesAirportTimeZones(t *testing.T) {
+
+	// Arrange
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Fatalf("Unable to parse test data: %v", err)
+	}
+
+	// Act
+	actual := convert(&fakeResponse.Flights[0], PriceBreakdown{})
+
+	// Assert
+	expectedDeparture := "2024-04-15T05:40:00Z"
+	if s := actual.Departure.UTC().Format(time.RFC3339); s != expectedDeparture {
+		t.Errorf("Found departure %s (UTC); Expected %s", s, expectedDeparture)
+	}
+	if s := actual.Arrival.Format("15:04 MST"); s != "08:50 CEST" {
+		t.Errorf("Found arrival %s; Expected 08:50 CEST", s)
+	}
+}
+
+func TestEnricherEstimatesDistanceAndEmissions(t *testing.T) {
+
+	// Arrange
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Fatalf("Unable to parse test data: %v", err)
+	}
+	actual := convert(&fakeResponse.Flights[0], PriceBreakdown{Total: 51.7})
+
+	// Act
+	for _, enricher := range getDefaultEnrichers() {
+		enricher.Stage.Enrich(OfferSearch{Origin: "OSL", Destination: "CPH", Currency: "EUR"}, &actual)
+	}
+
+	// Assert
+	if actual.Aircraft != "73H" {
+		t.Errorf("Found aircraft %s; Expected 73H", actual.Aircraft)
+	}
+	if s := fmt.Sprintf("%.0f km, %.0f kg, %.2f per 100 km", actual.Distance, actual.Co2, actual.GetPricePer100Km()); s != "517 km, 74 kg, 10.00 per 100 km" {
+		t.Errorf("Found %s; Expected 517 km, 74 kg, 10.00 per 100 km", s)
+	}
+}
+
+func TestKeepCheapest(t *testing.T) {
+
+	// Arrange
+	flights := []FlightForPurchase{
+		{FlightNumber: "DY932", Origin: "OSL", Destination: "CPH", Price: 899},
+		{FlightNumber: "SK484", Origin: "OSL", Destination: "ARN", Price: 650},
+		{FlightNumber: "WF313", Origin: "TRF", Destination: "CPH", Price: 720},
+		{FlightNumber: "FR123", Origin: "TRF", Destination: "ARN", Price: 650},
+	}
+
+	// Act
+	actual := KeepCheapest(flights)
+
+	// Assert
+	numbers := make([]string, 0, len(actual))
+	for _, flight := range actual {
+		numbers = append(numbers, flight.FlightNumber)
+	}
+	if s := strings.Join(numbers, ","); s != "SK484,WF313" {
+		t.Errorf("Kept %s; Expected SK484,WF313", s)
+	}
+}
+
+func TestKeepCheapestIncludesTransfer(t *testing.T) {
+
+	// Arrange
+	flights := []FlightForPurchase{
+		{FlightNumber: "DY932", Origin: "OSL", Destination: "CPH", Price: 899},
+		{FlightNumber: "WF313", Origin: "TRF", Destination: "CPH", Price: 720, Transfer: GroundTransfer{Duration: 90 * time.Minute, Cost: 250}},
+	}
+
+	// Act
+	actual := KeepCheapest(flights)
+
+	// Assert
+	if len(actual) != 1 || actual[0].FlightNumber != "DY932" {
+		t.Errorf("Kept %v; Expected DY932, since getting to TRF costs more than the flight saves", actual)
+	}
+}
+
+func getFakeResponseData() (fakeOffers flightSearchResponse, err error) {
+
+	data, err := os.ReadFile("sample-flight-offers.json")
+	if err != nil {
+		return fakeOffers, fmt.Errorf("reading json file: %w", err)
+	}
+
+	err = json.Unmarshal(data, &fakeOffers)
+	if err != nil {
+		return fakeOffers, fmt.Errorf("parsing test data: %w", err)
+	}
+
+	return fakeOffers, nil
+}
@@ -1,8 +1,10 @@
 package pricing
 
 import (
+	"encoding/json"
+	"flynow/airports"
 	"fmt"
-	"strconv"
+	"log/slog"
 	"time"
 )
 
@@ -15,43 +17,131 @@ type FlightForPurchase struct {
 	Arrival      time.Time
 	Price        float32
 	Currency     string
+	PriceDetails PriceBreakdown
+	Fare         FareDetails
+
+	// The IATA aircraft code (e.g. 73H) and its name (e.g. BOEING 737-800), if known
+	Aircraft     string
+	AircraftName string
+
+	// The great-circle distance in km, and the estimated CO2 per economy passenger in kg, or 0 if either
+	// airport isn't in the airport database (or the emissions enricher isn't used)
+	Distance float64
+	Co2      float64
+
+	// The number of stops on the way, which is always 0 unless the direct filter is left out
+	Stops int
+
+	OfferId           string
+	LastTicketingDate string
+	Confirmation      Confirmation
+
+	// The journey from home to the origin airport, when searching from several airports
+	Transfer GroundTransfer
+
+	// The original Amadeus offer, which is needed to reconfirm the price
+	offer json.RawMessage
 }
 
 // Converts the Amadeus JSON model for a flight offer into the shared data model
 // (Given how common this kind of conversion is, I expect there is a standard way
 // to do it, but I didn't find an example right away. And it was a good exercise to
 // practice basic type conversions.)
-func convert(offer *flightOffer) FlightForPurchase {
+func convert(offer *flightOffer, breakdown PriceBreakdown) FlightForPurchase {
 
-	singleFlight := offer.Itineraries[0].Segments[0]
+	// Unless the direct filter has been left out, these are the same flight
+	segments := offer.Itineraries[0].Segments
+	firstFlight, lastFlight := segments[0], segments[len(segments)-1]
 
 	var flight FlightForPurchase
 
-	flight.FlightNumber = singleFlight.Airline + singleFlight.Number
-	flight.Origin = singleFlight.Departure.Airport
-	flight.Destination = singleFlight.Arrival.Airport
+	flight.FlightNumber = firstFlight.Airline + firstFlight.Number
+	flight.Origin = firstFlight.Departure.Airport
+	flight.Destination = lastFlight.Arrival.Airport
 
-	if dep, err := time.Parse("2006-01-02T15:04:05", singleFlight.Departure.Time); err == nil {
+	// Amadeus gives the local times at each airport, without an offset
+	departureZone, arrivalZone := airports.GetLocation(flight.Origin), airports.GetLocation(flight.Destination)
+	if dep, err := time.ParseInLocation("2006-01-02T15:04:05", firstFlight.Departure.Time, departureZone); err == nil {
 		flight.Departure = dep
 	} else {
-		logWarning(fmt.Sprintf("Unexpected value for Departure time: %s", singleFlight.Departure.Time))
+		slog.Warn("Unexpected departure time", "offer", offer.Id, "time", firstFlight.Departure.Time)
 	}
-	if arr, err := time.Parse("2006-01-02T15:04:05", singleFlight.Arrival.Time); err == nil {
+	if arr, err := time.ParseInLocation("2006-01-02T15:04:05", lastFlight.Arrival.Time, arrivalZone); err == nil {
 		flight.Arrival = arr
 	} else {
-		logWarning(fmt.Sprintf("Unexpected value for Arrival time: %s", singleFlight.Arrival.Time))
+		slog.Warn("Unexpected arrival time", "offer", offer.Id, "time", lastFlight.Arrival.Time)
 	}
 
-	if p, err := strconv.ParseFloat(offer.Price.Total, 64); err == nil {
-		flight.Price = float32(p)
-	} else {
-		logWarning(fmt.Sprintf("Unexpected value for TotalPrice: %s", offer.Price.Total))
-	}
+	flight.Stops = len(segments) - 1
+	flight.Aircraft = firstFlight.Aircraft.Code
+
+	flight.Price = breakdown.Total
+	flight.PriceDetails = breakdown
 	flight.Currency = offer.Price.Currency
+	flight.Fare = getFareDetails(offer)
+
+	flight.OfferId = offer.Id
+	flight.LastTicketingDate = offer.LastTicketingDate
+	flight.offer = offer.raw
 
 	return flight
 }
 
+// The time and cost of getting to the airport, e.g. by train or car
+type GroundTransfer struct {
+	Duration time.Duration
+	Cost     float32 // In the currency of the flight
+}
+
+// Gets the cost of the whole journey, i.e. the flight and the transfer to the airport
+func (flight FlightForPurchase) GetDoorToDoorPrice() float32 {
+	return flight.Price + flight.Transfer.Cost
+}
+
+// Gets the time from leaving home to landing at the destination (not counting any wait at the airport)
+func (flight FlightForPurchase) GetDoorToDoorTime() time.Duration {
+	return flight.Transfer.Duration + flight.Arrival.Sub(flight.Departure)
+}
+
+// Gets the price per 100 km flown, for finding the best value rather than the cheapest flight, or 0 if the
+// distance isn't known
+func (flight FlightForPurchase) GetPricePer100Km() float32 {
+
+	if flight.Distance == 0 {
+		return 0
+	}
+	return flight.Price / float32(flight.Distance/100)
+}
+
+// Describes the distance and emissions, e.g. "517 km, about 74 kg CO2 per passenger on a BOEING 737-800,
+// 174 NOK per 100 km", or an empty string if the distance isn't known
+func (flight FlightForPurchase) getDistanceString() string {
+
+	if flight.Distance == 0 {
+		return ""
+	}
+
+	aircraft := ""
+	if flight.AircraftName != "" {
+		aircraft = " on a " + flight.AircraftName
+	} else if flight.Aircraft != "" {
+		aircraft = " on a " + flight.Aircraft
+	}
+	return fmt.Sprintf("Distance %.0f km, about %.0f kg CO2 per passenger%s, %s per 100 km\n",
+		flight.Distance, flight.Co2, aircraft, formatPrice(flight.GetPricePer100Km(), flight.Currency))
+}
+
+// Describes the whole journey, e.g. "FR123 TRF-AGP: 749 NOK, 5h30m door to door (250 NOK, 1h30m to TRF)"
+func (flight FlightForPurchase) GetDoorToDoorString() string {
+
+	s := fmt.Sprintf("%s %s-%s: %s, %s door to door", flight.FlightNumber, flight.Origin, flight.Destination,
+		formatPrice(flight.GetDoorToDoorPrice(), flight.Currency), formatDuration(flight.GetDoorToDoorTime()))
+	if flight.Transfer != (GroundTransfer{}) {
+		s += fmt.Sprintf(" (%s, %s to %s)", formatPrice(flight.Transfer.Cost, flight.Currency), formatDuration(flight.Transfer.Duration), flight.Origin)
+	}
+	return s
+}
+
 // Stringer implementation, providing basic information
 func (flight FlightForPurchase) String() string {
 	return fmt.Sprintf("%s : %s-%s %v -- %v", flight.FlightNumber, flight.Origin, flight.Destination, flight.Departure.Format("15:04"), flight.GetFormattedPrice())
@@ -59,44 +149,123 @@ func (flight FlightForPurchase) String() string {
 
 // Returns the full flight information in a user-friendly multi-line representation
 func (flight FlightForPurchase) GetMultilineString() string {
-	s1 := fmt.Sprintf("%s : %s - %s\n", flight.FlightNumber, flight.Origin, flight.Destination)
-	s2 := fmt.Sprintf("Departing %v\nArriving %v\n", flight.Departure.Format("2006-01-02 15:04"), flight.Arrival.Format("2006-01-02 15:04"))
-	s3 := flight.GetFormattedPrice()
-	return s1 + s2 + s3
+	s1 := fmt.Sprintf("%s : %s - %s\n", flight.FlightNumber, airports.Describe(flight.Origin), airports.Describe(flight.Destination))
+	s2 := fmt.Sprintf("Departing %v\nArriving %v\n", flight.Departure.Format("2006-01-02 15:04 MST"), flight.Arrival.Format("2006-01-02 15:04 MST"))
+	s3 := flight.getDistanceString()
+	s4 := flight.getFareDetailsString()
+	s5 := flight.getPriceDetailsString()
+	s6 := "Total " + flight.GetFormattedPrice()
+	s7 := flight.getConfirmationString()
+	return s1 + s2 + s3 + s4 + s5 + s6 + s7
+}
+
+// Describes the outcome of reconfirming the price, if that has been done
+func (flight FlightForPurchase) getConfirmationString() string {
+
+	confirmation := flight.Confirmation
+	s := ""
+	switch confirmation.Status {
+	case PriceConfirmed:
+		s = "\nPrice confirmed"
+	case PriceChanged:
+		s = fmt.Sprintf("\nPrice changed (was %s)", formatPrice(confirmation.SearchedPrice, flight.Currency))
+	case OfferUnavailable:
+		return fmt.Sprintf("\nNo longer available (%s)", confirmation.Message)
+	}
+
+	if flight.LastTicketingDate != "" {
+		s += fmt.Sprintf("\nMust be booked by %s", flight.LastTicketingDate)
+	}
+
+	return s
+}
+
+// Lists the fare brand and its amenities, flagging those which cost extra
+func (flight FlightForPurchase) getFareDetailsString() string {
+
+	fare := flight.Fare
+	if fare.Brand == "" && fare.Cabin == "" {
+		return ""
+	}
+
+	s := fmt.Sprintf("Fare %s (%s, class %s, %s)\n", fare.GetBrandName(), fare.Cabin, fare.BookingClass, fare.FareBasis)
+	for _, a := range fare.Amenities {
+		if a.Chargeable {
+			s += fmt.Sprintf("  - %s (extra cost)\n", a.Description)
+		} else {
+			s += fmt.Sprintf("  + %s\n", a.Description)
+		}
+	}
+
+	return s
+}
+
+// Lists the components of the total price, one per line
+func (flight FlightForPurchase) getPriceDetailsString() string {
+
+	details := flight.PriceDetails
+	s := fmt.Sprintf("Base fare %s\n", formatPrice(details.Base, flight.Currency))
+	s += fmt.Sprintf("Taxes %s\n", formatPrice(details.Taxes, flight.Currency))
+	if details.Fees != 0 {
+		s += fmt.Sprintf("Fees %s\n", formatPrice(details.Fees, flight.Currency))
+	}
+
+	if details.CheckedBags > 0 {
+		switch {
+		case details.Bags > 0:
+			s += fmt.Sprintf("%d checked bag(s) %s (%d included in fare)\n", details.CheckedBags, formatPrice(details.Bags, flight.Currency), details.IncludedBags)
+		default:
+			s += fmt.Sprintf("%d checked bag(s) included in fare\n", details.CheckedBags)
+		}
+	}
+
+	return s
 }
 
 // Provides price information in a currency-specific display format
 func (flight FlightForPurchase) GetFormattedPrice() string {
+	return formatPrice(flight.Price, flight.Currency)
+}
+
+func formatPrice(amount float32, currency string) string {
 
-	switch c := flight.Currency; c {
+	switch currency {
 	case "NOK":
-		return fmt.Sprintf("%d NOK", int(flight.Price))
+		return fmt.Sprintf("%d NOK", int(amount))
 	case "EUR":
-		return fmt.Sprintf("€%.2f", flight.Price)
+		return fmt.Sprintf("€%.2f", amount)
 	case "USD":
-		return fmt.Sprintf("$%.2f", flight.Price)
+		return fmt.Sprintf("$%.2f", amount)
 	default:
-		return fmt.Sprintf("%.2f %s", flight.Price, c)
+		return fmt.Sprintf("%.2f %s", amount, currency)
 	}
 }
 
-// Enable sorting by price
-type ByPrice []FlightForPurchase
+// Formats a duration in hours and minutes, e.g. 5h30m
+func formatDuration(d time.Duration) string {
 
-func (a ByPrice) Len() int           { return len(a) }
-func (a ByPrice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByPrice) Less(i, j int) bool { return a[i].Price < a[j].Price }
-
-// Enable sorting by destination
-type ByDest []FlightForPurchase
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
 
-func (a ByDest) Len() int           { return len(a) }
-func (a ByDest) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByDest) Less(i, j int) bool { return a[i].Destination < a[j].Destination }
+// Keeps only the cheapest flight to each destination, e.g. when the same destination was searched from
+// several of the airports in a metro area. The cost includes the transfer to the airport, so that a cheap
+// flight from a distant airport doesn't win if getting there costs more than it saves. The flights are
+// otherwise kept in the same order.
+func KeepCheapest(flights []FlightForPurchase) []FlightForPurchase {
 
-// Enable sorting by departure time
-type ByTime []FlightForPurchase
+	cheapest := make(map[string]int)
+	for i, flight := range flights {
+		if j, found := cheapest[flight.Destination]; !found || flight.GetDoorToDoorPrice() < flights[j].GetDoorToDoorPrice() {
+			cheapest[flight.Destination] = i
+		}
+	}
 
-func (a ByTime) Len() int           { return len(a) }
-func (a ByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByTime) Less(i, j int) bool { return a[i].Departure.Before(a[j].Departure) }
+	results := make([]FlightForPurchase, 0, len(cheapest))
+	for i, flight := range flights {
+		if cheapest[flight.Destination] == i {
+			results = append(results, flight)
+		}
+	}
+	return results
+}
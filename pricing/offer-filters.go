@@ -0,0 +1,211 @@
+package pricing
+
+import (
+	"encoding/json"
+	"flynow/pipeline"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// The offer filters decide which of the offers returned for a destination can be chosen, before the
+// cheapest one is picked. The built-in filters are registered here, and others can be added with
+// RegisterOfferFilter. Unless the config gives the filters to use, the defaults are used, in this order:
+//
+//	direct    only flights without any stops
+//	route     only flights between the airports searched for (or the nearby origins)
+//	airlines  only flights operated by the airlines allowed by the airline filter
+//	fares     only fares allowed by the fare filter
+//	currency  only prices in the currency searched for
+//
+// Every offer must also have a valid price, which is always checked after the filters.
+
+// The search an offer was returned for
+type OfferSearch struct {
+	Origin      string
+	Destination string
+	Currency    string
+	Options     SearchOptions
+}
+
+// A flight offer, as seen by the offer filters
+type Offer struct {
+	Id string
+
+	// The flights of each itinerary, i.e. more than one itinerary for a return flight, and more than one
+	// flight in an itinerary for a connecting flight
+	Itineraries [][]Segment
+
+	Currency string
+	Fare     FareDetails
+}
+
+// A single flight within an offer
+type Segment struct {
+	FlightNumber string
+	Airline      string
+	Origin       string
+	Destination  string
+	Aircraft     string
+}
+
+// Decides whether an offer can be chosen, and if not, gives the reason for the audit (e.g. "not a direct flight")
+type OfferFilter interface {
+	CheckOffer(search OfferSearch, offer *Offer) (accepted bool, reason string)
+}
+
+// Adapts an ordinary function to the OfferFilter interface
+type OfferFilterFunc func(search OfferSearch, offer *Offer) (accepted bool, reason string)
+
+func (f OfferFilterFunc) CheckOffer(search OfferSearch, offer *Offer) (accepted bool, reason string) {
+	return f(search, offer)
+}
+
+var offerFilters = newOfferFilterRegistry()
+
+var defaultOfferFilters = []string{"direct", "route", "airlines", "fares", "currency"}
+
+// Makes an offer filter available to the config, under the given name. This panics if the name is
+// already used, e.g. by one of the built-in filters.
+func RegisterOfferFilter(name string, factory pipeline.Factory[OfferFilter]) {
+	offerFilters.Register(name, factory)
+}
+
+// Gets the names of the offer filters which can be used in the config
+func GetOfferFilterNames() []string {
+	return offerFilters.GetNames()
+}
+
+// Creates the given offer filters, in the same order, or the default filters if none are given
+func BuildOfferFilters(stages []pipeline.Stage) ([]pipeline.Named[OfferFilter], error) {
+
+	if len(stages) == 0 {
+		stages = pipeline.GetStages(defaultOfferFilters...)
+	}
+	return offerFilters.Build(stages)
+}
+
+// The built-in filters take no parameters, so building the defaults can't fail
+var getDefaultOfferFilters = sync.OnceValue(func() []pipeline.Named[OfferFilter] {
+
+	filters, err := BuildOfferFilters(nil)
+	if err != nil {
+		panic(err)
+	}
+	return filters
+})
+
+func newOfferFilterRegistry() *pipeline.Registry[OfferFilter] {
+
+	registry := pipeline.NewRegistry[OfferFilter]("offer filter")
+	registry.Register("direct", withoutParams(checkDirect))
+	registry.Register("route", withoutParams(checkRoute))
+	registry.Register("airlines", withoutParams(checkAirlines))
+	registry.Register("fares", withoutParams(checkFare))
+	registry.Register("currency", withoutParams(checkCurrency))
+	return registry
+}
+
+// Gets the factory for a built-in filter which doesn't take any parameters
+func withoutParams(check OfferFilterFunc) pipeline.Factory[OfferFilter] {
+
+	return func(params json.RawMessage) (OfferFilter, error) {
+		return check, pipeline.NoParams(params)
+	}
+}
+
+// Verifies that it's a single-leg flight
+func checkDirect(search OfferSearch, offer *Offer) (bool, string) {
+
+	if len(offer.Itineraries) != 1 || len(offer.Itineraries[0]) != 1 {
+		return false, "not a direct flight"
+	}
+	return true, ""
+}
+
+// Verifies the airport codes (i.e. NOT TORP!!! 😜, unless searching from the whole Oslo area)
+func checkRoute(search OfferSearch, offer *Offer) (bool, string) {
+
+	outbound := offer.getOutbound()
+	from, to := outbound[0].Origin, outbound[len(outbound)-1].Destination
+	fromOrigin := from == search.Origin || slices.Contains(search.Options.NearbyOrigins, from)
+	if !fromOrigin || to != search.Destination {
+		return false, fmt.Sprintf("wrong airports (searched for %s-%s)", search.Origin, search.Destination)
+	}
+	return true, ""
+}
+
+// Verifies the airline of every flight, rather than relying only on the search parameters
+func checkAirlines(search OfferSearch, offer *Offer) (bool, string) {
+
+	for _, itinerary := range offer.Itineraries {
+		for _, segment := range itinerary {
+			if !search.Options.Airlines.Allows(segment.Airline) {
+				return false, fmt.Sprintf("airline %s is excluded by the airline filter", segment.Airline)
+			}
+		}
+	}
+	return true, ""
+}
+
+// Verifies the fare brand, cabin and amenities
+func checkFare(search OfferSearch, offer *Offer) (bool, string) {
+
+	if allowed, reason := search.Options.Fares.Allows(offer.Fare); !allowed {
+		return false, "fare filter: " + reason
+	}
+	return true, ""
+}
+
+// Verifies the currency. Amadeus should always use the currency searched for, so this is logged as a warning.
+func checkCurrency(search OfferSearch, offer *Offer) (bool, string) {
+
+	if offer.Currency != search.Currency {
+		slog.Warn("Rejected offer in another currency", "origin", search.Origin, "destination", search.Destination, "offer", offer.Id, "currency", offer.Currency)
+		return false, fmt.Sprintf("wrong currency (searched for %s)", search.Currency)
+	}
+	return true, ""
+}
+
+// Gets the offer as seen by the filters
+func newOffer(offer *flightOffer) Offer {
+
+	result := Offer{Id: offer.Id, Currency: offer.Price.Currency, Fare: getFareDetails(offer)}
+	result.Itineraries = make([][]Segment, 0, len(offer.Itineraries))
+	for _, itinerary := range offer.Itineraries {
+		segments := make([]Segment, 0, len(itinerary.Segments))
+		for _, s := range itinerary.Segments {
+			segments = append(segments, Segment{
+				FlightNumber: s.Airline + s.Number,
+				Airline:      s.Airline,
+				Origin:       s.Departure.Airport,
+				Destination:  s.Arrival.Airport,
+				Aircraft:     s.Aircraft.Code,
+			})
+		}
+		result.Itineraries = append(result.Itineraries, segments)
+	}
+
+	return result
+}
+
+// Gets the flights of the first itinerary, or nil if there aren't any
+func (offer *Offer) getOutbound() []Segment {
+
+	if len(offer.Itineraries) == 0 {
+		return nil
+	}
+	return offer.Itineraries[0]
+}
+
+// Runs the offer through the filters, in order, and gives the name of the filter which rejected it, if any
+func checkOffer(filters []pipeline.Named[OfferFilter], search OfferSearch, offer *Offer) (accepted bool, filter string, reason string) {
+
+	for _, f := range filters {
+		if accepted, reason := f.Stage.CheckOffer(search, offer); !accepted {
+			return false, f.Name, reason
+		}
+	}
+	return true, "", ""
+}
@@ -0,0 +1,157 @@
+package pricing
+
+import (
+	"encoding/json"
+	"flynow/pipeline"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// An offer filter which is registered in the same way as a team would add their own
+func init() {
+
+	RegisterOfferFilter("test-aircraft", func(params json.RawMessage) (OfferFilter, error) {
+		var settings struct {
+			Exclude []string `json:"exclude"`
+		}
+		if err := pipeline.DecodeParams(params, &settings); err != nil {
+			return nil, err
+		}
+
+		return OfferFilterFunc(func(search OfferSearch, offer *Offer) (bool, string) {
+			for _, segment := range offer.getOutbound() {
+				if slices.Contains(settings.Exclude, segment.Aircraft) {
+					return false, "aircraft " + segment.Aircraft + " is excluded"
+				}
+			}
+			return true, ""
+		}), nil
+	})
+}
+
+func TestEvaluateFlightsWithConfiguredFilters(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		stages         string
+		expectedFlight string
+		expectedReason string
+	}{
+		{"default filters", `[]`, "DY932", "wrong airports (searched for OSL-CPH)"},
+		{"custom filter", `[{"stage": "test-aircraft", "params": {"exclude": ["73H"]}}, {"stage": "route"}]`, "SK1477", "aircraft 73H is excluded"},
+		{"custom filter after the route", `[{"stage": "route"}, {"stage": "test-aircraft", "params": {"exclude": ["73H", "32N"]}}]`, "D83231", "wrong airports (searched for OSL-CPH)"},
+		{"without the route filter", `[{"stage": "test-aircraft", "params": {"exclude": ["73H", "32N", "7M8"]}}]`, "WF313", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			fakeResponse, err := getFakeResponseData()
+			if err != nil {
+				t.Fatalf("Unable to parse test data: %v", err)
+			}
+			var stages []pipeline.Stage
+			if err := json.Unmarshal([]byte(test.stages), &stages); err != nil {
+				t.Fatalf("Unable to parse stages: %v", err)
+			}
+			filters, err := BuildOfferFilters(stages)
+			if err != nil {
+				t.Fatalf("BuildOfferFilters failed: %v", err)
+			}
+			audit := NewAudit()
+
+			// Act
+			found, actual := evaluateFlights(&fakeResponse, "OSL", "CPH", "EUR", SearchOptions{OfferFilters: filters, Audit: audit})
+
+			// Assert
+			if !found || actual.FlightNumber != test.expectedFlight {
+				t.Errorf("Found flight %s; Expected %s", actual.FlightNumber, test.expectedFlight)
+			}
+			reasons := make([]string, 0)
+			for _, offer := range audit.GetReports()[0].Offers {
+				if offer.OfferId == "7" || offer.OfferId == "1" {
+					reasons = append(reasons, offer.Reason)
+				}
+			}
+			if test.expectedReason != "" && !slices.Contains(reasons, test.expectedReason) {
+				t.Errorf("Got reasons %q; Expected one of them to be %q", reasons, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestBuildPricingStagesErrors(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		build    func() error
+		expected string
+	}{
+		{
+			"unknown offer filter",
+			func() error { _, err := BuildOfferFilters(pipeline.GetStages("direct", "nonstop")); return err },
+			`unknown offer filter "nonstop" (expected one of airlines, currency, direct, fares, route, test-aircraft)`,
+		},
+		{
+			"parameters for a built-in filter",
+			func() error {
+				_, err := BuildOfferFilters([]pipeline.Stage{{Name: "fares", Params: json.RawMessage(`{"brands": ["LIGHT"]}`)}})
+				return err
+			},
+			`creating offer filter "fares": no parameters are expected`,
+		},
+		{
+			"unknown parameter",
+			func() error {
+				_, err := BuildOfferFilters([]pipeline.Stage{{Name: "test-aircraft", Params: json.RawMessage(`{"include": ["73H"]}`)}})
+				return err
+			},
+			`creating offer filter "test-aircraft": parsing parameters: json: unknown field "include"`,
+		},
+		{
+			"unknown enricher",
+			func() error { _, err := BuildEnrichers(pipeline.GetStages("co2")); return err },
+			`unknown enricher "co2" (expected one of emissions)`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Act
+			err := test.build()
+
+			// Assert
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Got error %v; Expected it to contain %q", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestEnrichersRunInOrder(t *testing.T) {
+
+	// Arrange
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Fatalf("Unable to parse test data: %v", err)
+	}
+	label := func(search OfferSearch, flight *FlightForPurchase) {
+		flight.AircraftName = fmt.Sprintf("%s (%.0f km)", flight.AircraftName, flight.Distance)
+	}
+	options := SearchOptions{Enrichers: []pipeline.Named[Enricher]{
+		getDefaultEnrichers()[0],
+		{Name: "label", Stage: EnricherFunc(label)},
+	}}
+
+	// Act
+	_, actual := evaluateFlights(&fakeResponse, "OSL", "CPH", "EUR", options)
+
+	// Assert
+	if actual.AircraftName != "BOEING 737-800 (WINGLETS) (517 km)" {
+		t.Errorf("Found aircraft name %q; Expected the distance to have been added by the emissions enricher first", actual.AircraftName)
+	}
+}
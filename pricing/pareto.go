@@ -0,0 +1,88 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A measure to compare the flights by when finding the best trade-offs, and whether higher values of it
+// are better, e.g. for a later arrival
+type Dimension struct {
+	Measure string
+	Highest bool
+}
+
+// Parses a list of measures (price, soonness, duration, arrival or stops), each with a leading "-" if higher
+// values are better, e.g. ["price", "soonness", "-arrival"]
+func ParseDimensions(items []string) ([]Dimension, error) {
+
+	dimensions := make([]Dimension, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		dimension := Dimension{Measure: strings.TrimPrefix(item, "-"), Highest: strings.HasPrefix(item, "-")}
+		if _, found := measures[dimension.Measure]; !found {
+			return nil, fmt.Errorf("unknown measure %q (expected one of %s)", item, strings.Join(getNames(measures), ", "))
+		}
+		dimensions = append(dimensions, dimension)
+	}
+
+	return dimensions, nil
+}
+
+func (dimension Dimension) String() string {
+
+	if dimension.Highest {
+		return "-" + dimension.Measure
+	}
+	return dimension.Measure
+}
+
+// Gets the flights which are the best trade-offs between the given measures, i.e. the Pareto front: those
+// for which no other flight is at least as good by every measure and better by at least one. Every other
+// flight is clearly worse than one of these. The flights are kept in the same order.
+func FindParetoFront(flights []FlightForPurchase, dimensions []Dimension) []FlightForPurchase {
+
+	// Get each flight's values up front, with the values where higher is better negated, so that lower is
+	// always better
+	values := make([][]float64, len(flights))
+	for i := range flights {
+		values[i] = make([]float64, len(dimensions))
+		for j, dimension := range dimensions {
+			values[i][j] = measures[dimension.Measure](&flights[i])
+			if dimension.Highest {
+				values[i][j] = -values[i][j]
+			}
+		}
+	}
+
+	front := make([]FlightForPurchase, 0)
+	for i, flight := range flights {
+		dominated := false
+		for j := range flights {
+			if j != i && dominates(values[j], values[i]) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, flight)
+		}
+	}
+	return front
+}
+
+// Checks whether the first values are all at least as good as the second ones (i.e. as low), and at least
+// one of them is better
+func dominates(a []float64, b []float64) bool {
+
+	better := false
+	for k := range a {
+		if a[k] > b[k] {
+			return false
+		}
+		if a[k] < b[k] {
+			better = true
+		}
+	}
+	return better
+}
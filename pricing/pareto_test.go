@@ -0,0 +1,52 @@
+package pricing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindParetoFront(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		dimensions string
+		expected   string
+	}{
+		{"price alone", "price", "FR123"},
+		{"price and soonness", "price,soonness", "FR123,SK484,DY932"},
+		{"price and duration", "price,duration", "FR123,SK484"},
+		{"soonness and duration", "soonness,duration", "SK484,DY932"},
+		{"price and latest arrival", "price,-arrival", "FR123"},
+		{"all equal", "stops", "FR123,SK484,DY932,WF313"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			dimensions, err := ParseDimensions(strings.Split(test.dimensions, ","))
+			if err != nil {
+				t.Fatalf("Unable to parse measures: %v", err)
+			}
+
+			// Act
+			actual := FindParetoFront(getRankingTestFlights(), dimensions)
+
+			// Assert
+			if s := getFlightNumbers(actual); s != test.expected {
+				t.Errorf("Found trade-offs %s; Expected %s", s, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseDimensionsError(t *testing.T) {
+
+	// Act
+	_, err := ParseDimensions([]string{"price", "-comfort"})
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), `unknown measure "-comfort"`) {
+		t.Errorf("Got error %v; Expected the unknown measure to be reported", err)
+	}
+}
@@ -0,0 +1,120 @@
+package pricing
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Breakdown of the total price of a flight, including the cost of any checked bags which have
+// been requested but are not included in the fare
+type PriceBreakdown struct {
+	Total        float32 // The fare plus any additional bags
+	Fare         float32 // The grand total for the fare, as given by Amadeus
+	Base         float32
+	Taxes        float32
+	Fees         float32
+	Bags         float32
+	CheckedBags  int // The number of checked bags covered by the total price
+	IncludedBags int // The number of checked bags included in the fare itself
+}
+
+// Calculates the price breakdown of the given offer, for a traveler with the given number of checked bags.
+// Note: Amadeus only gives the price of a single additional checked bag, so it is assumed that
+// each further bag costs the same.
+func getPriceBreakdown(offer *flightOffer, checkedBags int) (breakdown PriceBreakdown, err error) {
+
+	breakdown.Fare, err = parseAmount(offer.Price.Total)
+	if err != nil {
+		return breakdown, fmt.Errorf("parsing grand total price: %w", err)
+	}
+
+	fareTotal, err := parseAmount(offer.Price.FareTotal, offer.Price.Total)
+	if err != nil {
+		return breakdown, fmt.Errorf("parsing total price: %w", err)
+	}
+
+	breakdown.Base, err = parseAmount(offer.Price.Base, offer.Price.FareTotal, offer.Price.Total)
+	if err != nil {
+		return breakdown, fmt.Errorf("parsing base price: %w", err)
+	}
+
+	for _, f := range offer.Price.Fees {
+		amount, err := parseAmount(f.Amount)
+		if err != nil {
+			return breakdown, fmt.Errorf("parsing %s fee: %w", f.Type, err)
+		}
+		breakdown.Fees += amount
+	}
+
+	breakdown.Taxes = fareTotal - breakdown.Base - breakdown.Fees
+	breakdown.CheckedBags = checkedBags
+	breakdown.IncludedBags = getIncludedBags(offer)
+
+	// Add the cost of any bags which aren't already included in the fare
+	if extraBags := checkedBags - breakdown.IncludedBags; extraBags > 0 {
+		bagPrice, found := getCheckedBagPrice(offer)
+		if !found {
+			return breakdown, fmt.Errorf("offer does not include the price of checked bags")
+		}
+		breakdown.Bags = bagPrice * float32(extraBags)
+	}
+
+	breakdown.Total = breakdown.Fare + breakdown.Bags
+
+	return breakdown, nil
+}
+
+// Gets the number of checked bags included on every flight in the offer. A weight-based allowance
+// is counted as a single bag.
+func getIncludedBags(offer *flightOffer) int {
+
+	included := -1
+	for _, traveler := range offer.TravelerPricings {
+		for _, segment := range traveler.Segments {
+			bags := segment.IncludedBags.Quantity
+			if bags == 0 && segment.IncludedBags.Weight > 0 {
+				bags = 1
+			}
+			if included == -1 || bags < included {
+				included = bags
+			}
+		}
+	}
+
+	if included == -1 {
+		return 0
+	}
+	return included
+}
+
+// Gets the price of an additional checked bag, if the offer includes it
+func getCheckedBagPrice(offer *flightOffer) (amount float32, found bool) {
+
+	for _, service := range offer.Price.AdditionalServices {
+		if service.Type != "CHECKED_BAGS" {
+			continue
+		}
+		if amount, err := parseAmount(service.Amount); err == nil {
+			return amount, true
+		}
+	}
+
+	return 0, false
+}
+
+// Parses a price amount, using the first of the given values which is not empty
+func parseAmount(values ...string) (float32, error) {
+
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected price value: %s", value)
+		}
+		return float32(amount), nil
+	}
+
+	return 0, fmt.Errorf("missing price value")
+}
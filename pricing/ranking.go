@@ -0,0 +1,297 @@
+package pricing
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The measures which can be combined into a score. Each of them is better when lower, unless its weight is
+// negative.
+const (
+	PriceMeasure    = "price"    // The door-to-door price
+	SoonnessMeasure = "soonness" // How soon the flight departs
+	DurationMeasure = "duration" // The door-to-door time
+	ArrivalMeasure  = "arrival"  // The local time of day when landing (counting on from 24:00 after midnight)
+	StopsMeasure    = "stops"    // The number of stops on the way
+)
+
+var measures = map[string]func(flight *FlightForPurchase) float64{
+	PriceMeasure:    func(f *FlightForPurchase) float64 { return float64(f.GetDoorToDoorPrice()) },
+	SoonnessMeasure: func(f *FlightForPurchase) float64 { return float64(f.Departure.Unix()) },
+	DurationMeasure: func(f *FlightForPurchase) float64 { return f.GetDoorToDoorTime().Minutes() },
+	ArrivalMeasure:  func(f *FlightForPurchase) float64 { return getLocalArrivalHours(f) },
+	StopsMeasure:    func(f *FlightForPurchase) float64 { return float64(f.Stops) },
+}
+
+// Gets the local time of day at the destination when landing, in hours. A flight landing after midnight (in
+// local time) counts on from 24, so that it isn't mistaken for an early arrival.
+func getLocalArrivalHours(flight *FlightForPurchase) float64 {
+
+	departureDate := time.Date(flight.Departure.Year(), flight.Departure.Month(), flight.Departure.Day(), 0, 0, 0, 0, time.UTC)
+	arrivalDate := time.Date(flight.Arrival.Year(), flight.Arrival.Month(), flight.Arrival.Day(), 0, 0, 0, 0, time.UTC)
+	days := arrivalDate.Sub(departureDate).Hours() / 24
+
+	return 24*days + float64(flight.Arrival.Hour()) + float64(flight.Arrival.Minute())/60
+}
+
+// The weight of each measure in the score, e.g. {"price": 1, "soonness": 0.5}. A negative weight prefers
+// a higher value, e.g. {"arrival": -1} for landing as late in the day as possible.
+type Weights map[string]float64
+
+// The weights used when none are given, which score the flights by price alone
+var defaultWeights = Weights{PriceMeasure: 1}
+
+// Parses a comma-separated list of weights, e.g. "price=1,soonness=0.5"
+func ParseWeights(text string) (Weights, error) {
+
+	weights := make(Weights)
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, value, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("%q is not of the form measure=weight", item)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q does not have a valid weight", item)
+		}
+		weights[strings.TrimSpace(name)] = weight
+	}
+
+	return weights, weights.Validate()
+}
+
+// Checks that every weight is for a known measure, and that they aren't all 0
+func (weights Weights) Validate() error {
+
+	var errs []error
+	total := 0.0
+	for name, weight := range weights {
+		if _, found := measures[name]; !found {
+			errs = append(errs, fmt.Errorf("unknown measure %q (expected one of %s)", name, strings.Join(getNames(measures), ", ")))
+		}
+		if math.IsNaN(weight) || math.IsInf(weight, 0) {
+			errs = append(errs, fmt.Errorf("the weight of %s is not a number", name))
+		}
+		total += math.Abs(weight)
+	}
+	if len(weights) > 0 && total == 0 {
+		errs = append(errs, errors.New("at least one weight must not be 0"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Scores each of the flights from 0 (the best) to 1 (the worst). Each measure is scaled to between 0 and 1,
+// from the best to the worst value among the given flights, and then the weighted average of the measures
+// is taken. The price alone is used if no weights have been given.
+func (weights Weights) Score(flights []FlightForPurchase) []float64 {
+
+	if len(weights) == 0 {
+		weights = defaultWeights
+	}
+
+	scores := make([]float64, len(flights))
+	total := 0.0
+	for name, weight := range weights {
+		measure, found := measures[name]
+		if !found || weight == 0 {
+			continue
+		}
+		total += math.Abs(weight)
+
+		values := make([]float64, len(flights))
+		for i := range flights {
+			values[i] = measure(&flights[i])
+		}
+		lowest, highest := slices.Min(values), slices.Max(values)
+		if highest == lowest {
+			continue
+		}
+
+		for i, value := range values {
+			scaled := (value - lowest) / (highest - lowest)
+			if weight < 0 {
+				scaled = 1 - scaled
+			}
+			scores[i] += math.Abs(weight) * scaled
+		}
+	}
+
+	if total > 0 {
+		for i := range scores {
+			scores[i] /= total
+		}
+	}
+	return scores
+}
+
+// The fields which the flights can be sorted by
+const (
+	SortByPrice      = "price"
+	SortByTime       = "time" // The departure time
+	SortByArrival    = "arrival"
+	SortByDuration   = "duration" // The time in the air
+	SortByStops      = "stops"
+	SortByDest       = "dest"
+	SortByDistance   = "distance"
+	SortByValue      = "value" // The price per 100 km
+	SortByEmissions  = "emissions"
+	SortByDoorToDoor = "door-to-door"
+	SortByScore      = "score" // The weighted score
+)
+
+// How to compare the flights by a field
+type sortField struct {
+	compare func(a *FlightForPurchase, b *FlightForPurchase) int
+
+	// Whether the field is known, for the fields which aren't always. The flights where it isn't known come
+	// last, whichever order they're sorted in.
+	known func(flight *FlightForPurchase) bool
+}
+
+var sortFields = map[string]sortField{
+	SortByPrice:   {compare: func(a, b *FlightForPurchase) int { return cmp.Compare(a.Price, b.Price) }},
+	SortByTime:    {compare: func(a, b *FlightForPurchase) int { return a.Departure.Compare(b.Departure) }},
+	SortByArrival: {compare: compareMeasure(ArrivalMeasure)},
+	SortByDuration: {compare: func(a, b *FlightForPurchase) int {
+		return cmp.Compare(a.Arrival.Sub(a.Departure), b.Arrival.Sub(b.Departure))
+	}},
+	SortByStops: {compare: func(a, b *FlightForPurchase) int { return cmp.Compare(a.Stops, b.Stops) }},
+	SortByDest:  {compare: func(a, b *FlightForPurchase) int { return cmp.Compare(a.Destination, b.Destination) }},
+	SortByDistance: {
+		compare: func(a, b *FlightForPurchase) int { return cmp.Compare(a.Distance, b.Distance) },
+		known:   func(f *FlightForPurchase) bool { return f.Distance != 0 },
+	},
+	SortByValue: {
+		compare: func(a, b *FlightForPurchase) int { return cmp.Compare(a.GetPricePer100Km(), b.GetPricePer100Km()) },
+		known:   func(f *FlightForPurchase) bool { return f.GetPricePer100Km() != 0 },
+	},
+	SortByEmissions: {
+		compare: func(a, b *FlightForPurchase) int { return cmp.Compare(a.Co2, b.Co2) },
+		known:   func(f *FlightForPurchase) bool { return f.Co2 != 0 },
+	},
+	SortByDoorToDoor: {compare: func(a, b *FlightForPurchase) int {
+		return cmp.Or(cmp.Compare(a.GetDoorToDoorPrice(), b.GetDoorToDoorPrice()), cmp.Compare(a.GetDoorToDoorTime(), b.GetDoorToDoorTime()))
+	}},
+
+	// The scores depend on all the flights, so they are compared separately
+	SortByScore: {},
+}
+
+func compareMeasure(name string) func(a *FlightForPurchase, b *FlightForPurchase) int {
+	return func(a, b *FlightForPurchase) int { return cmp.Compare(measures[name](a), measures[name](b)) }
+}
+
+// A field to sort by, and the direction
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Parses a list of sort keys in priority order, each of which is a field name, with a leading "-" for
+// descending order, e.g. ["score", "-time"]
+func ParseSortKeys(items []string) ([]SortKey, error) {
+
+	keys := make([]SortKey, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		key := SortKey{Field: strings.TrimPrefix(item, "-"), Descending: strings.HasPrefix(item, "-")}
+		if _, found := sortFields[key.Field]; !found {
+			return nil, fmt.Errorf("unknown sort key %q (expected one of %s)", item, strings.Join(getNames(sortFields), ", "))
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (key SortKey) String() string {
+
+	if key.Descending {
+		return "-" + key.Field
+	}
+	return key.Field
+}
+
+// The order of the flight options: by each of the sort keys in turn, where any ties are broken by the next
+// key, and with the weights used for sorting by score
+type Ranking struct {
+	Keys    []SortKey
+	Weights Weights
+}
+
+// Sorts the flights in place. Any flights which are equal on every key keep their original order.
+func (ranking Ranking) Sort(flights []FlightForPurchase) {
+
+	var scores []float64
+	if slices.ContainsFunc(ranking.Keys, func(key SortKey) bool { return key.Field == SortByScore }) {
+		scores = ranking.Weights.Score(flights)
+	}
+
+	// Sort the positions rather than the flights, so that they still match the scores
+	order := make([]int, len(flights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return ranking.compare(flights, scores, order[i], order[j]) < 0
+	})
+
+	sorted := make([]FlightForPurchase, len(flights))
+	for i, position := range order {
+		sorted[i] = flights[position]
+	}
+	copy(flights, sorted)
+}
+
+// Compares the flights at the given positions by each of the keys in turn
+func (ranking Ranking) compare(flights []FlightForPurchase, scores []float64, i int, j int) int {
+
+	a, b := &flights[i], &flights[j]
+	for _, key := range ranking.Keys {
+		var result int
+		if key.Field == SortByScore {
+			result = cmp.Compare(scores[i], scores[j])
+		} else {
+			field := sortFields[key.Field]
+			if field.known != nil {
+				if aKnown, bKnown := field.known(a), field.known(b); aKnown != bKnown {
+					if aKnown {
+						return -1
+					}
+					return 1
+				}
+			}
+			result = field.compare(a, b)
+		}
+
+		if key.Descending {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// Gets the sorted names of a map's entries, for listing the valid names in an error
+func getNames[T any](entries map[string]T) []string {
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
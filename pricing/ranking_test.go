@@ -0,0 +1,187 @@
+package pricing
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getRankingTestFlights() []FlightForPurchase {
+
+	at := func(hour int, minute int) time.Time { return time.Date(2024, 4, 15, hour, minute, 0, 0, time.UTC) }
+	return []FlightForPurchase{
+		{FlightNumber: "FR123", Destination: "AGP", Price: 499, Departure: at(18, 0), Arrival: at(22, 30)},
+		{FlightNumber: "SK484", Destination: "ARN", Price: 650, Departure: at(9, 0), Arrival: at(10, 0)},
+		{FlightNumber: "DY932", Destination: "CPH", Price: 899, Departure: at(7, 0), Arrival: at(8, 10)},
+		{FlightNumber: "WF313", Destination: "CPH", Price: 650, Departure: at(12, 0), Arrival: at(13, 15)},
+	}
+}
+
+func getFlightNumbers(flights []FlightForPurchase) string {
+
+	numbers := make([]string, 0, len(flights))
+	for _, flight := range flights {
+		numbers = append(numbers, flight.FlightNumber)
+	}
+	return strings.Join(numbers, ",")
+}
+
+func TestRankingSort(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		keys     string
+		weights  string
+		expected string
+	}{
+		{"price, keeping the order of ties", "price", "", "FR123,SK484,WF313,DY932"},
+		{"descending price", "-price", "", "DY932,SK484,WF313,FR123"},
+		{"tie broken by latest departure", "price,-time", "", "FR123,WF313,SK484,DY932"},
+		{"destination then time", "dest,time", "", "FR123,SK484,DY932,WF313"},
+		{"duration", "duration", "", "SK484,DY932,WF313,FR123"},
+		{"score without weights", "score", "", "FR123,SK484,WF313,DY932"},
+		{"score by soonness", "score", "soonness=1", "DY932,SK484,WF313,FR123"},
+		{"score by latest arrival", "score", "arrival=-1", "FR123,WF313,SK484,DY932"},
+		{"score by price and duration", "score", "price=1,duration=1", "SK484,WF313,FR123,DY932"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			keys, err := ParseSortKeys(strings.Split(test.keys, ","))
+			if err != nil {
+				t.Fatalf("Unable to parse sort keys: %v", err)
+			}
+			weights, err := ParseWeights(test.weights)
+			if err != nil {
+				t.Fatalf("Unable to parse weights: %v", err)
+			}
+			flights := getRankingTestFlights()
+
+			// Act
+			Ranking{Keys: keys, Weights: weights}.Sort(flights)
+
+			// Assert
+			if actual := getFlightNumbers(flights); actual != test.expected {
+				t.Errorf("Sorted %s; Expected %s", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestRankingSortPutsUnknownLast(t *testing.T) {
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"distance", "SK484,DY932,FR123,XX1"},
+		{"value", "FR123,SK484,DY932,XX1"},
+		{"emissions", "SK484,DY932,FR123,XX1"},
+		{"-emissions", "FR123,DY932,SK484,XX1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.keys, func(t *testing.T) {
+
+			// Arrange
+			keys, err := ParseSortKeys([]string{test.keys})
+			if err != nil {
+				t.Fatalf("Unable to parse sort keys: %v", err)
+			}
+			flights := []FlightForPurchase{
+				{FlightNumber: "XX1", Price: 500},
+				{FlightNumber: "DY932", Price: 899, Distance: 517, Co2: 80},
+				{FlightNumber: "FR123", Price: 499, Distance: 2400, Co2: 275},
+				{FlightNumber: "SK484", Price: 650, Distance: 400, Co2: 62},
+			}
+
+			// Act
+			Ranking{Keys: keys}.Sort(flights)
+
+			// Assert
+			if actual := getFlightNumbers(flights); actual != test.expected {
+				t.Errorf("Sorted %s; Expected %s", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestRankingSortByDoorToDoor(t *testing.T) {
+
+	// Arrange
+	departure := time.Date(2024, time.April, 15, 7, 0, 0, 0, time.UTC)
+	flights := []FlightForPurchase{
+		{FlightNumber: "FR123", Price: 499, Departure: departure, Arrival: departure.Add(4 * time.Hour), Transfer: GroundTransfer{Duration: 90 * time.Minute, Cost: 250}},
+		{FlightNumber: "DY1302", Price: 749, Departure: departure, Arrival: departure.Add(4 * time.Hour)},
+		{FlightNumber: "SK4565", Price: 700, Departure: departure, Arrival: departure.Add(5 * time.Hour)},
+	}
+
+	// Act
+	Ranking{Keys: []SortKey{{Field: SortByDoorToDoor}}}.Sort(flights)
+
+	// Assert
+	if actual := getFlightNumbers(flights); actual != "SK4565,DY1302,FR123" {
+		t.Errorf("Sorted %s; Expected SK4565,DY1302,FR123", actual)
+	}
+}
+
+func TestScore(t *testing.T) {
+
+	// Arrange
+	flights := getRankingTestFlights()
+	flights[0].Arrival = time.Date(2024, 4, 16, 0, 30, 0, 0, time.UTC)
+
+	// Act
+	byPrice := Weights{PriceMeasure: 2}.Score(flights)
+	byArrival := Weights{ArrivalMeasure: 1}.Score(flights)
+
+	// Assert
+	for i, expected := range []float64{0, 0.3775, 1, 0.3775} {
+		if math.Abs(byPrice[i]-expected) > 0.0001 {
+			t.Errorf("%s has price score %.4f; Expected %.4f", flights[i].FlightNumber, byPrice[i], expected)
+		}
+	}
+	if byArrival[0] != 1 {
+		t.Errorf("Landing after midnight has arrival score %.4f; Expected the latest arrival (1)", byArrival[0])
+	}
+}
+
+func TestRankingErrors(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		keys     string
+		weights  string
+		expected string
+	}{
+		{"unknown sort key", "price,-cheapest", "", `unknown sort key "-cheapest"`},
+		{"weight without value", "score", "price", `"price" is not of the form measure=weight`},
+		{"invalid weight", "score", "price=high", `"price=high" does not have a valid weight`},
+		{"unknown measure", "score", "comfort=1", `unknown measure "comfort"`},
+		{"all weights 0", "score", "price=0", "at least one weight must not be 0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Act
+			_, keysErr := ParseSortKeys(strings.Split(test.keys, ","))
+			_, weightsErr := ParseWeights(test.weights)
+
+			// Assert
+			err := keysErr
+			if err == nil {
+				err = weightsErr
+			}
+			if err == nil {
+				t.Fatal("Parsed successfully; Expected an error")
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Got error %q; Expected it to contain %q", err, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package pricing
+
+import (
+	"errors"
+	"flynow/amadeus"
+	"flynow/apierror"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Outcome of reconfirming the price of a flight
+type ConfirmationStatus string
+
+const (
+	NotConfirmed     ConfirmationStatus = ""
+	PriceConfirmed   ConfirmationStatus = "confirmed"
+	PriceChanged     ConfirmationStatus = "price changed"
+	OfferUnavailable ConfirmationStatus = "unavailable"
+)
+
+// Result of reconfirming the price of a flight
+type Confirmation struct {
+	Status        ConfirmationStatus
+	SearchedPrice float32 // The (cached) price from the original search
+	Message       string  // The reason the offer is unavailable, if it is
+}
+
+// Reconfirms the prices of the given flights using the Amadeus Flight Offers Price endpoint, since the
+// search results are based on cached data. The flights are returned in the same order, with updated
+// prices and the Confirmation set. Any flights which are no longer available are marked as such.
+func (priceClient *Client) ReconfirmPrices(flights []FlightForPurchase, options SearchOptions) (confirmed []FlightForPurchase, err error) {
+
+	client := priceClient.amadeus
+	if err := client.Authenticate(); err != nil {
+		return nil, fmt.Errorf("authenticating with Amadeus: %w", err)
+	}
+
+	confirmed = make([]FlightForPurchase, len(flights))
+	errs := make(chan error, len(flights))
+
+	// Reconfirm each flight in parallel, relying on the client to stay within the rate limit
+	wg := new(sync.WaitGroup)
+	for i, flight := range flights {
+		wg.Add(1)
+		go func(i int, flight FlightForPurchase) {
+			defer wg.Done()
+			defer priceClient.acquire()()
+			result, err := reconfirmPrice(client, flight, options)
+			if err != nil {
+				errs <- fmt.Errorf("reconfirming %s: %w", flight.FlightNumber, err)
+				result = flight
+			}
+			confirmed[i] = result
+		}(i, flight)
+	}
+	wg.Wait()
+	close(errs)
+
+	if len(errs) > 0 {
+		err = errors.New("reconfirming flight prices")
+		for nextErr := range errs {
+			err = fmt.Errorf("%w; %w", err, nextErr)
+		}
+		return confirmed, err
+	}
+
+	return confirmed, nil
+}
+
+// The Amadeus error codes and titles for offers which can no longer be booked, e.g. because the seats have
+// been sold since the search results were cached
+var (
+	unavailableCodes  = []string{"34651"}
+	unavailableTitles = []string{"SEGMENT SELL FAILURE", "NO FARE APPLICABLE", "NO LONGER AVAILABLE"}
+)
+
+// Checks whether Amadeus rejected the offer because it can no longer be booked. Amadeus reports these as
+// invalid requests, but so are other mistakes, which are errors rather than unavailable offers.
+func isOfferUnavailable(err error) bool {
+
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode < 400 || apiErr.StatusCode >= 500 {
+		return false
+	}
+	if slices.Contains(unavailableCodes, apiErr.Code) {
+		return true
+	}
+	for _, title := range unavailableTitles {
+		if strings.Contains(strings.ToUpper(apiErr.Title), title) || strings.Contains(strings.ToUpper(apiErr.Detail), title) {
+			return true
+		}
+	}
+	return false
+}
+
+// Performs a REST call to the Amadeus flight offers price endpoint, to get the current price of a single flight
+func reconfirmPrice(client *amadeus.Client, flight FlightForPurchase, options SearchOptions) (FlightForPurchase, error) {
+
+	const pricingPath = "/v1/shopping/flight-offers/pricing"
+
+	if flight.offer == nil {
+		return flight, errors.New("flight was not found by an Amadeus search")
+	}
+
+	request := flightPriceRequest{
+		Data: flightPriceData{
+			Type:   "flight-offers-pricing",
+			Offers: []flightOffer{{raw: flight.offer}},
+		},
+	}
+
+	flight.Confirmation = Confirmation{SearchedPrice: flight.Price}
+
+	var response flightPriceResponse
+	if err := client.Post(pricingPath, request, http.MethodGet, &response); err != nil {
+
+		if isOfferUnavailable(err) {
+			flight.Confirmation.Status = OfferUnavailable
+			flight.Confirmation.Message = err.Error()
+			slog.Debug("Offer is no longer available", "flight", flight.FlightNumber, "offer", flight.OfferId, "error", err)
+			return flight, nil
+		}
+		return flight, err
+	}
+
+	if len(response.Data.Offers) == 0 {
+		flight.Confirmation.Status = OfferUnavailable
+		flight.Confirmation.Message = "no offer was returned"
+		return flight, nil
+	}
+	offer := &response.Data.Offers[0]
+
+	breakdown, err := getPriceBreakdown(offer, options.CheckedBags)
+	if err != nil {
+		// The pricing response doesn't always repeat the bag prices, so keep the ones from the search
+		if breakdown, err = getPriceBreakdown(offer, 0); err != nil {
+			return flight, fmt.Errorf("parsing confirmed price: %w", err)
+		}
+		breakdown.CheckedBags = flight.PriceDetails.CheckedBags
+		breakdown.Bags = flight.PriceDetails.Bags
+		breakdown.Total = breakdown.Fare + breakdown.Bags
+	}
+
+	flight.Price = breakdown.Total
+	flight.PriceDetails = breakdown
+	flight.offer = offer.raw
+	if offer.LastTicketingDate != "" {
+		flight.LastTicketingDate = offer.LastTicketingDate
+	}
+
+	// Ignore any rounding differences
+	if math.Abs(float64(flight.Price-flight.Confirmation.SearchedPrice)) < 0.005 {
+		flight.Confirmation.Status = PriceConfirmed
+	} else {
+		flight.Confirmation.Status = PriceChanged
+	}
+	slog.Debug("Reconfirmed price", "flight", flight.FlightNumber, "offer", flight.OfferId,
+		"searched", flight.Confirmation.SearchedPrice, "confirmed", flight.Price, "status", flight.Confirmation.Status)
+
+	return flight, nil
+}
@@ -0,0 +1,30 @@
+package pricing
+
+import (
+	"flynow/airlines"
+	"flynow/pipeline"
+)
+
+// Optional criteria which narrow down the flight search, beyond the route and currency
+type SearchOptions struct {
+	Airlines airlines.Filter
+
+	// The number of checked bags per traveler. When this is more than the fare includes,
+	// the cost of the extra bags is added to the price, so that fares with and without
+	// included bags can be compared fairly.
+	CheckedBags int
+
+	Fares FareFilter
+
+	// Other airports which count as the origin, e.g. the rest of the metro area being searched from. Amadeus
+	// sometimes returns flights from a nearby airport (such as TRF for OSL), which are only accepted if they
+	// are listed here.
+	NearbyOrigins []string
+
+	// Decide which offers can be chosen, and add details to the chosen flights, or the defaults if nil
+	OfferFilters []pipeline.Named[OfferFilter]
+	Enrichers    []pipeline.Named[Enricher]
+
+	// Records why each destination and offer was or wasn't chosen, if set
+	Audit *Audit
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flynow/pricing"
+	"flynow/where"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The number of flights shown in the provisional ranking
+const provisionalCount = 3
+
+// Shows the progress of the price search as the search for each destination finishes, along with the best
+// flights found so far, so that a long search doesn't go quiet until the end
+type progress struct {
+	out     io.Writer
+	total   int
+	done    int
+	ranking pricing.Ranking
+
+	// The flights must match this to be ranked, if set
+	condition *where.Condition
+
+	flights []pricing.FlightForPurchase
+	leaders string
+}
+
+func newProgress(out io.Writer, total int, ranking pricing.Ranking, condition *where.Condition) *progress {
+	p := progress{out: out, total: total, ranking: ranking, condition: condition, flights: make([]pricing.FlightForPurchase, 0)}
+	return &p
+}
+
+// Prints the outcome for a destination, and the provisional ranking if it has changed
+func (p *progress) report(result pricing.DestinationResult) {
+
+	p.done++
+	prefix := fmt.Sprintf("[%d/%d] %s-%s:", p.done, p.total, result.Origin, result.Destination)
+
+	switch {
+	case result.Err != nil:
+		fmt.Fprintf(p.out, "%s failed (%v)\n", prefix, result.Err)
+		return
+	case !result.Found:
+		fmt.Fprintf(p.out, "%s no flight found\n", prefix)
+		return
+	}
+
+	flight := result.Flight
+	if p.condition != nil {
+
+		// An error (such as a price in another currency) counts as not matching, and is reported at the end
+		if matches, err := p.condition.Matches(flight, time.Now()); err != nil || !matches {
+			fmt.Fprintf(p.out, "%s %s %s, which doesn't match --where\n", prefix, flight.FlightNumber, flight.GetFormattedPrice())
+			return
+		}
+	}
+	fmt.Fprintf(p.out, "%s %s %s\n", prefix, flight.FlightNumber, flight.GetFormattedPrice())
+
+	p.flights = append(p.flights, flight)
+	if leaders := p.getLeaders(); leaders != p.leaders {
+		p.leaders = leaders
+		fmt.Fprintf(p.out, "  Best so far: %s\n", leaders)
+	}
+}
+
+// Describes the top few flights found so far, in the order of the configured ranking
+func (p *progress) getLeaders() string {
+
+	ranked := pricing.KeepCheapest(p.flights)
+	p.ranking.Sort(ranked)
+
+	leaders := make([]string, 0, provisionalCount)
+	for i, f := range ranked[:min(provisionalCount, len(ranked))] {
+		leaders = append(leaders, fmt.Sprintf("%d. %s %s-%s %s", i+1, f.FlightNumber, f.Origin, f.Destination, f.GetFormattedPrice()))
+	}
+	return strings.Join(leaders, ", ")
+}
@@ -0,0 +1,136 @@
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"flynow/apierror"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Keeps track of the number of requests made to an API with a monthly quota, so that the quota isn't
+// exceeded by accident. The usage of each API is kept in a shared file, and is reset at the start of each
+// calendar month (in UTC), which may not be exactly when the provider resets it.
+type Tracker struct {
+	path  string
+	api   string
+	limit int
+	mutex sync.Mutex
+}
+
+// Error returned when the monthly quota has been used up, before the request is made. This is also an
+// apierror.ErrQuotaExceeded, as when the API itself reports that the quota has been used up.
+var ErrExceeded = fmt.Errorf("monthly %w", apierror.ErrQuotaExceeded)
+
+// Usage of a single API in a single month
+type Usage struct {
+	Month string `json:"month"`
+	Used  int    `json:"used"`
+	Limit int    `json:"-"`
+}
+
+// Gets a tracker for the given API, which allows the given number of requests per month (or any number,
+// if the limit is 0). The usage is kept in the given file.
+func NewTracker(path string, api string, limit int) *Tracker {
+	tracker := Tracker{path: path, api: api, limit: limit}
+	return &tracker
+}
+
+// Records a request, or returns ErrExceeded if the quota for this month has already been used up
+func (tracker *Tracker) Use() error {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	usage, err := tracker.read()
+	if err != nil {
+		return err
+	}
+
+	month := getCurrentMonth()
+	current := usage[tracker.api]
+	if current.Month != month {
+		current = Usage{Month: month}
+	}
+
+	if tracker.limit > 0 && current.Used >= tracker.limit {
+		return fmt.Errorf("%s: %w (%d of %d requests used in %s)", tracker.api, ErrExceeded, current.Used, tracker.limit, month)
+	}
+
+	current.Used++
+	usage[tracker.api] = current
+
+	return tracker.write(usage)
+}
+
+// Gets the number of requests made so far this month
+func (tracker *Tracker) GetUsage() (Usage, error) {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	usage, err := tracker.read()
+	if err != nil {
+		return Usage{}, err
+	}
+
+	month := getCurrentMonth()
+	current := usage[tracker.api]
+	if current.Month != month {
+		current = Usage{Month: month}
+	}
+	current.Limit = tracker.limit
+
+	return current, nil
+}
+
+func (tracker *Tracker) read() (map[string]Usage, error) {
+
+	usage := make(map[string]Usage)
+
+	data, err := os.ReadFile(tracker.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading quota usage: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("parsing quota usage %s: %w", tracker.path, err)
+	}
+
+	return usage, nil
+}
+
+// Writes the usage to a temporary file first, so that an interrupted save can't corrupt it
+func (tracker *Tracker) write(usage map[string]Usage) error {
+
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("serializing quota usage: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(tracker.path), 0o755); err != nil {
+		return fmt.Errorf("creating quota directory: %w", err)
+	}
+
+	tempPath := tracker.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing quota usage: %w", err)
+	}
+
+	if err := os.Rename(tempPath, tracker.path); err != nil {
+		return fmt.Errorf("writing quota usage: %w", err)
+	}
+
+	return nil
+}
+
+func getCurrentMonth() string {
+	return time.Now().UTC().Format("2006-01")
+}
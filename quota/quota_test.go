@@ -0,0 +1,72 @@
+package quota
+
+import (
+	"errors"
+	"flynow/apierror"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseStopsAtLimit(t *testing.T) {
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "quota.json")
+	tracker := NewTracker(path, "aviationstack", 2)
+
+	// Act
+	first := tracker.Use()
+	second := tracker.Use()
+	third := tracker.Use()
+
+	// Assert
+	if first != nil || second != nil {
+		t.Fatalf("Got errors %v and %v; Expected the first two requests to be allowed", first, second)
+	}
+	if !errors.Is(third, ErrExceeded) || !errors.Is(third, apierror.ErrQuotaExceeded) {
+		t.Errorf("Got error %v; Expected the quota to be exceeded", third)
+	}
+
+	// The usage is kept between runs, and for each API separately
+	if err := NewTracker(path, "aviationstack", 2).Use(); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Got error %v; Expected the saved usage to be used", err)
+	}
+	if err := NewTracker(path, "other", 2).Use(); err != nil {
+		t.Errorf("Got error %v; Expected another API to have its own quota", err)
+	}
+}
+
+func TestUsageResetsEachMonth(t *testing.T) {
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "quota.json")
+	if err := os.WriteFile(path, []byte(`{"aviationstack":{"month":"2000-01","used":100}}`), 0o644); err != nil {
+		t.Fatalf("Unable to write quota file: %v", err)
+	}
+	tracker := NewTracker(path, "aviationstack", 100)
+
+	// Act
+	err := tracker.Use()
+	usage, usageErr := tracker.GetUsage()
+
+	// Assert
+	if err != nil {
+		t.Errorf("Got error %v; Expected a new month to have a new quota", err)
+	}
+	if usageErr != nil || usage.Used != 1 || usage.Limit != 100 {
+		t.Errorf("Got usage %+v (error %v); Expected 1 of 100", usage, usageErr)
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+
+	// Arrange
+	tracker := NewTracker(filepath.Join(t.TempDir(), "quota.json"), "aviationstack", 0)
+
+	// Act & Assert
+	for i := 0; i < 10; i++ {
+		if err := tracker.Use(); err != nil {
+			t.Fatalf("Got error %v; Expected no limit", err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package schedule
+
+import (
+	"flynow/airports"
+	"flynow/amadeus"
+	"flynow/config"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// Gets a schedule client based on the Amadeus Airport Routes endpoint, which lists every direct destination
+// from an airport. Unlike AviationStack, this doesn't use up a limited monthly quota, but the routes are not
+// specific to the current day, so some of the destinations may not actually have a flight today.
+// Note: The endpoint doesn't say which airlines fly each route, so the destination filters are given each
+// route without an airline.
+func GetAmadeusRoutesClient(amadeusClient *amadeus.Client, filters DestinationFilters) ScheduleClient {
+	client := amadeusRoutesClient{amadeus: amadeusClient, filters: filters}
+	return &client
+}
+
+type amadeusRoutesClient struct {
+	amadeus *amadeus.Client
+	filters DestinationFilters
+}
+
+// Performs a REST call to the Amadeus direct destinations endpoint to get the list of destinations with
+// direct flights from the given airport
+func (client *amadeusRoutesClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
+
+	const routesPath = "/v1/airport/direct-destinations"
+
+	query := url.Values{}
+	query.Add("departureAirportCode", origin)
+
+	var routes directDestinationsResponse
+	if err := client.amadeus.Get(routesPath, query, &routes); err != nil {
+		return nil, fmt.Errorf("requesting direct destinations: %w", err)
+	}
+
+	destinations = make([]string, 0, len(routes.Destinations))
+	found := make(map[string]bool)
+	for _, dest := range routes.Destinations {
+		for _, airport := range getAirports(dest) {
+			route := Flight{Source: config.AmadeusRoutesSource, Origin: origin, Destination: airport}
+			if airport != origin && !found[airport] && client.filters.Allows(origin, route) {
+				found[airport] = true
+				destinations = append(destinations, airport)
+			}
+		}
+	}
+	sort.Strings(destinations)
+
+	return destinations, nil
+}
+
+// Gets the airports of a destination. The endpoint gives the destinations as cities (e.g. LON), so a city
+// with several airports is expanded to all of them, since the offers and filters are for airports.
+func getAirports(dest location) []string {
+
+	if dest.IataCode == "" {
+		return nil
+	}
+	if dest.Subtype != "city" {
+		return []string{dest.IataCode}
+	}
+
+	place, err := airports.Resolve(dest.IataCode)
+	if err != nil {
+		return []string{dest.IataCode}
+	}
+	return place.Airports
+}
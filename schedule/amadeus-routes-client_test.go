@@ -0,0 +1,151 @@
+package schedule
+
+import (
+	"encoding/json"
+	"flynow/airlines"
+	"flynow/amadeus"
+	"flynow/config"
+	"flynow/credentials"
+	"flynow/fakeapi"
+	"flynow/pipeline"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Starts fake AviationStack and Amadeus servers, where AviationStack has flights from OSL to CPH and BGO
+// (and one from TRF), and Amadeus has direct routes from OSL to CPH and ARN
+func setupFakeEndpoints(t *testing.T) (*fakeapi.AviationStack, *fakeapi.Amadeus, Endpoints) {
+
+	departure := time.Now().Add(2 * time.Hour).Truncate(time.Minute)
+
+	aviationStack := fakeapi.NewAviationStack(t)
+	aviationStack.AddFlights(
+		fakeapi.NewFlight("DY", "932", "OSL", "CPH", departure, 70*time.Minute),
+		fakeapi.NewFlight("DY", "610", "OSL", "BGO", departure, 50*time.Minute),
+		fakeapi.NewFlight("FR", "1234", "TRF", "AGP", departure, 225*time.Minute),
+	)
+
+	amadeusFake := fakeapi.NewAmadeus(t)
+	amadeusFake.AddRoutes("OSL", "CPH", "ARN")
+
+	secrets := credentials.Static{
+		credentials.AviationStackApiKey: "test-key",
+		credentials.AmadeusClientId:     "test-id",
+		credentials.AmadeusClientSecret: "test-secret",
+	}
+	endpoints := Endpoints{
+		AviationStackUrl: aviationStack.Url,
+		Amadeus:          amadeus.NewClient(amadeusFake.Url, secrets),
+		Credentials:      secrets,
+	}
+
+	return aviationStack, amadeusFake, endpoints
+}
+
+func TestAmadeusRoutesClient(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		stages   string
+		expected []string
+	}{
+		{"default filters", `[]`, []string{"ARN", "CPH", "LCY", "LGW", "LHR", "LTN", "STN"}},
+		{"excluded country", `[{"stage": "exclude", "params": {"countries": ["GB"]}}]`, []string{"ARN", "CPH"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			_, amadeusFake, endpoints := setupFakeEndpoints(t)
+			amadeusFake.AddRoutes("OSL", "OSL", "", "LGW", "LON")
+			amadeusFake.AddRoutes("TRF", "AGP")
+			var stages []pipeline.Stage
+			if err := json.Unmarshal([]byte(test.stages), &stages); err != nil {
+				t.Fatalf("Unable to parse stages: %v", err)
+			}
+			filters, err := NewDestinationFilters(stages, airlines.Filter{Exclude: []string{"FR"}})
+			if err != nil {
+				t.Fatalf("NewDestinationFilters failed: %v", err)
+			}
+			client := GetAmadeusRoutesClient(endpoints.Amadeus, filters)
+
+			// Act
+			actual, err := client.GetScheduledDestinations("OSL")
+
+			// Assert
+			if err != nil {
+				t.Fatalf("GetScheduledDestinations failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Found %v; Expected %v, with the airports of each city, but without the origin itself or an empty code", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestCompareDestinations(t *testing.T) {
+
+	// Arrange
+	_, _, endpoints := setupFakeEndpoints(t)
+	first := GetAviationStackClient(endpoints.AviationStackUrl, endpoints.Credentials, DestinationFilters{}, nil, nil, nil)
+	second := GetAmadeusRoutesClient(endpoints.Amadeus, DestinationFilters{})
+
+	// Act
+	actual, err := CompareDestinations("OSL", first, second)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("CompareDestinations failed: %v", err)
+	}
+	expected := DestinationComparison{InBoth: []string{"CPH"}, OnlyInFirst: []string{"BGO"}, OnlyInSecond: []string{"ARN"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Got %+v; Expected %+v", actual, expected)
+	}
+}
+
+func TestCrossCheckSource(t *testing.T) {
+
+	// Arrange
+	aviationStack, amadeusFake, endpoints := setupFakeEndpoints(t)
+	settings := config.Schedule{Sources: []string{config.CrossCheckSource}, RouteDatabase: filepath.Join(t.TempDir(), "routes.json")}
+	client, err := GetClient(DestinationFilters{}, settings, endpoints)
+	if err != nil {
+		t.Fatalf("GetClient failed: %v", err)
+	}
+
+	// Act
+	actual, err := client.GetScheduledDestinations("OSL")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("GetScheduledDestinations failed: %v", err)
+	}
+	if !reflect.DeepEqual(actual, []string{"CPH"}) {
+		t.Errorf("Found %v; Expected only CPH, which is both scheduled today and a known route", actual)
+	}
+	if aviationStack.Flights.Requests() != 1 || amadeusFake.Routes.Requests() != 1 {
+		t.Errorf("Made %d AviationStack and %d Amadeus routes requests; Expected one of each", aviationStack.Flights.Requests(), amadeusFake.Routes.Requests())
+	}
+}
+
+func TestCrossCheckReportsFailedSource(t *testing.T) {
+
+	// Arrange
+	_, amadeusFake, endpoints := setupFakeEndpoints(t)
+	amadeusFake.Routes.StatusCode = 500
+	client := crossCheckClient{
+		first:  GetAviationStackClient(endpoints.AviationStackUrl, endpoints.Credentials, DestinationFilters{}, nil, nil, nil),
+		second: GetAmadeusRoutesClient(endpoints.Amadeus, DestinationFilters{}),
+	}
+
+	// Act
+	_, err := client.GetScheduledDestinations("OSL")
+
+	// Assert
+	if err == nil {
+		t.Error("GetScheduledDestinations succeeded; Expected the failed routes request to be reported")
+	}
+}
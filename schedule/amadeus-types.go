@@ -0,0 +1,16 @@
+package schedule
+
+// Relevant partial models for the Amadeus Airport Routes REST API response
+
+// Top-level response model for direct destination searches
+type directDestinationsResponse struct {
+	Destinations []location `json:"data"`
+}
+
+// A destination city or airport
+type location struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	Name     string `json:"name"`
+	IataCode string `json:"iataCode"`
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import "sync"
+
+// Decision about a single scheduled flight, i.e. whether its destination was used, and why
+type FlightVerdict struct {
+	Source       string
+	FlightNumber string
+	Origin       string
+	Destination  string
+	Accepted     bool
+	Reason       string
+}
+
+// Collects the decisions made about each scheduled flight, so that a search can be explained. An Audit can
+// be shared by several sources, and used from several goroutines. A nil Audit records nothing.
+type Audit struct {
+	mutex    sync.Mutex
+	verdicts []FlightVerdict
+}
+
+func NewAudit() *Audit {
+	audit := Audit{verdicts: make([]FlightVerdict, 0)}
+	return &audit
+}
+
+// Gets the decisions in the order they were made
+func (audit *Audit) GetVerdicts() []FlightVerdict {
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	return append([]FlightVerdict(nil), audit.verdicts...)
+}
+
+// Records the decision made about each of the flights given by a source, using the same filters as
+// findUniqueDestinations
+func (audit *Audit) recordFlights(source string, origin string, scheduledFlights []flightInfo, filters DestinationFilters) {
+
+	if audit == nil {
+		return
+	}
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	for _, flight := range scheduledFlights {
+		candidate := newFlight(source, flight)
+		accepted, reason := filters.Check(origin, candidate)
+		audit.verdicts = append(audit.verdicts, FlightVerdict{
+			Source:       source,
+			FlightNumber: candidate.FlightNumber,
+			Origin:       candidate.Origin,
+			Destination:  candidate.Destination,
+			Accepted:     accepted,
+			Reason:       reason,
+		})
+	}
+}
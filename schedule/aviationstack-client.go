@@ -3,24 +3,37 @@ package schedule
 import (
 	"encoding/json"
 	"errors"
+	"flynow/apierror"
 	"flynow/config"
+	"flynow/credentials"
+	"flynow/quota"
+	"flynow/transport"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
-// Interface for the flight schedule client, so that it can be mocked in consuming code
-
-type ScheduleClient interface {
-	GetScheduledDestinations(origin string) ([]string, error)
-}
-
-func GetClient() ScheduleClient {
-	client := aviationStackClient{}
+// Gets a schedule client based on the AviationStack realtime flights endpoint at the given base URL, which only
+// considers flights which pass the destination filters. If a route database is given, every response is
+// recorded in it, and if a quota tracker is given, no request is made once the monthly quota is used up. If an
+// audit is given, the decision about each flight is recorded in it.
+func GetAviationStackClient(baseUrl string, secrets credentials.Provider, filters DestinationFilters, routes *RouteDatabase, usage *quota.Tracker, audit *Audit) ScheduleClient {
+	client := aviationStackClient{baseUrl: strings.TrimSuffix(baseUrl, "/"), credentials: secrets, filters: filters, routes: routes, quota: usage, audit: audit}
 	return &client
 }
 
-type aviationStackClient struct{}
+type aviationStackClient struct {
+	baseUrl     string
+	credentials credentials.Provider
+	filters     DestinationFilters
+	routes      *RouteDatabase
+	quota       *quota.Tracker
+	audit       *Audit
+}
 
 // Performs a REST call to the AviationStack flights endpoint to get a list of realtime flights
 // scheduled to depart from the given airport. Note: Although this request uses paged results,
@@ -28,65 +41,112 @@ type aviationStackClient struct{}
 // free version.
 func (client *aviationStackClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
 
-	const flightsEndpoint = "http://api.aviationstack.com/v1/flights"
+	query := url.Values{}
+	query.Add("dep_iata", origin)
+	query.Add("flight_status", "scheduled")
 
-	// TODO: Read this from secure storage
-	apiKey := config.GetAviationStackCredentials()
+	responseBody, err := client.getFlights(query)
+	if err != nil {
+		return nil, fmt.Errorf("getting scheduled flights: %w", err)
+	}
+
+	var scheduledFlights flightsResponse
+	if err := json.Unmarshal(responseBody, &scheduledFlights); err != nil {
+		return nil, fmt.Errorf("parsing response body: %w", err)
+	}
 
-	request, err := http.NewRequest(http.MethodGet, flightsEndpoint, nil)
+	if scheduledFlights.Page.Count > 0 && scheduledFlights.Flights == nil {
+		err = errors.New("missing flight info in aviationstack response")
+		return nil, err
+	}
+
+	// Keep the flights for future predictions. This is best-effort, since the search can go ahead without it.
+	if client.routes != nil {
+		if err := client.routes.Record(origin, scheduledFlights.Flights); err != nil {
+			slog.Warn("Unable to record routes for predictions", "origin", origin, "error", err)
+		}
+	}
+
+	// Find the unique destinations based on the realtime flight data
+	client.audit.recordFlights(config.AviationStackSource, origin, scheduledFlights.Flights, client.filters)
+	return findUniqueDestinations(config.AviationStackSource, origin, scheduledFlights.Flights, client.filters), nil
+}
+
+// Makes the cheapest possible request to the AviationStack flights endpoint (for a single flight from the
+// given airport), to check that the API key is accepted. This uses up one request from the monthly quota.
+func ProbeAviationStack(baseUrl string, secrets credentials.Provider, usage *quota.Tracker, origin string) error {
+
+	client := aviationStackClient{baseUrl: strings.TrimSuffix(baseUrl, "/"), credentials: secrets, quota: usage}
+
+	query := url.Values{}
+	query.Add("dep_iata", origin)
+	query.Add("limit", "1")
+
+	_, err := client.getFlights(query)
+	return err
+}
+
+// Calls the flights endpoint with the given query parameters (as well as the API key), and returns the
+// response body
+func (client *aviationStackClient) getFlights(query url.Values) (responseBody []byte, err error) {
+
+	const flightsPath = "/v1/flights"
+
+	apiKey, err := credentials.Get(client.credentials, credentials.AviationStackApiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the quota first, so that a failed request is counted too, as it is by AviationStack
+	if client.quota != nil {
+		if err := client.quota.Use(); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodGet, client.baseUrl+flightsPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating HTTP request: %w", err)
 	}
 
-	query := request.URL.Query()
-	query.Add("access_key", apiKey)
-	query.Add("dep_iata", origin)
-	query.Add("flight_status", "scheduled")
+	query.Set("access_key", apiKey.Reveal())
 	request.URL.RawQuery = query.Encode()
 
-	httpClient := &http.Client{}
+	httpClient := transport.GetClient()
 
 	// Call the flights API
+	start := time.Now()
 	response, err := httpClient.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("requesting scheduled flights: %w", err)
-	}
-
-	if response.StatusCode != 200 {
-		return nil, fmt.Errorf("unexpected response code (%d) getting scheduled flights", response.StatusCode)
+		return nil, fmt.Errorf("requesting flights: %w", err)
 	}
+	defer response.Body.Close()
+	slog.Debug("AviationStack request", "origin", query.Get("dep_iata"), "status", response.StatusCode, "latency", time.Since(start))
 
-	// Read and parse the response data
-	responseBody, err := io.ReadAll(response.Body)
+	// Read the response data
+	responseBody, err = io.ReadAll(response.Body)
 	if err != nil {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
 
-	var scheduledFlights flightsResponse
-	json.Unmarshal(responseBody, &scheduledFlights)
-
-	if scheduledFlights.Page.Count > 0 && scheduledFlights.Flights == nil {
-		err = errors.New("missing flight info in aviationstack response")
-		return nil, err
+	if response.StatusCode != http.StatusOK {
+		return nil, apierror.ParseAviationStack(response.StatusCode, flightsPath, responseBody)
 	}
 
-	// Find the unique destinations based on the realtime flight data
-	return findUniqueDestinations(origin, scheduledFlights.Flights), nil
+	return responseBody, nil
 }
 
-func findUniqueDestinations(origin string, scheduledFlights []flightInfo) (destinations []string) {
+// Note: A destination is included if at least one flight there passes the filters, since the same route
+// is often flown by several airlines (or listed under several codeshare numbers).
+func findUniqueDestinations(source string, origin string, scheduledFlights []flightInfo, filters DestinationFilters) (destinations []string) {
 
 	destMap := make(map[string]bool)
 
 	// Find flights departing on the given day
 	for _, flight := range scheduledFlights {
-
-		// This shouldn't happen, but just in case
-		if flight.Departure.Airport != origin {
-			continue
+		if filters.Allows(origin, newFlight(source, flight)) {
+			destMap[flight.Arrival.Airport] = true
 		}
-
-		destMap[flight.Arrival.Airport] = true
 	}
 
 	destinations = make([]string, 0, len(destMap))
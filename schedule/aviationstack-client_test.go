@@ -0,0 +1,107 @@
+package schedule
+
+import (
+	"encoding/json"
+	"flynow/airlines"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFindUniqueDestinations(t *testing.T) {
+
+	// Arrange
+	const expected = 22
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Skipf("Unable to parse test data: %v", err)
+	}
+
+	// Act
+	actual := findUniqueDestinations("fixture", "OSL", fakeResponse.Flights, DestinationFilters{})
+
+	// Assert
+	if len(actual) != expected {
+		t.Errorf("Found %d results; Expected %d", len(actual), expected)
+	}
+}
+
+func TestAuditExplainsEachFlight(t *testing.T) {
+
+	// Arrange
+	fakeResponse, err := getFakeResponseData()
+	if err != nil {
+		t.Skipf("Unable to parse test data: %v", err)
+	}
+	audit := NewAudit()
+	filters := DestinationFilters{Airlines: airlines.Filter{Exclude: []string{"SK"}}}
+
+	// Act
+	audit.recordFlights("fixture", "OSL", fakeResponse.Flights, filters)
+	destinations := findUniqueDestinations("fixture", "OSL", fakeResponse.Flights, filters)
+
+	// Assert
+	verdicts := audit.GetVerdicts()
+	if len(verdicts) != len(fakeResponse.Flights) {
+		t.Fatalf("Got %d verdicts; Expected one for each of the %d flights", len(verdicts), len(fakeResponse.Flights))
+	}
+	used := make(map[string]bool)
+	for _, verdict := range verdicts {
+		if verdict.Accepted {
+			used[verdict.Destination] = true
+		} else if verdict.Reason == "" {
+			t.Errorf("Flight %s was ignored without a reason", verdict.FlightNumber)
+		}
+	}
+	if len(used) != len(destinations) {
+		t.Errorf("Used flights to %d destinations; Expected %d", len(used), len(destinations))
+	}
+}
+
+func TestAviationStackReportsUnparsableResponse(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		body      string
+		malformed bool
+	}{
+		{"malformed JSON", "", true},
+		{"HTML page", "<html><body>Service unavailable</body></html>", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			aviationStack, _, endpoints := setupFakeEndpoints(t)
+			aviationStack.Flights.Body = test.body
+			aviationStack.Flights.Malformed = test.malformed
+			client := GetAviationStackClient(endpoints.AviationStackUrl, endpoints.Credentials, DestinationFilters{}, nil, nil, nil)
+
+			// Act
+			destinations, err := client.GetScheduledDestinations("OSL")
+
+			// Assert
+			if err == nil || !strings.Contains(err.Error(), "parsing response body") {
+				t.Errorf("Found %v with error %v; Expected the response to be reported as unparsable", destinations, err)
+			}
+		})
+	}
+}
+
+func getFakeResponseData() (fakeFlights flightsResponse, err error) {
+
+	data, err := os.ReadFile("sample-scheduled-flights.json")
+	if err != nil {
+		return fakeFlights, fmt.Errorf("reading json file: %w", err)
+	}
+
+	var flightData flightsResponse
+	err = json.Unmarshal(data, &flightData)
+	if err != nil {
+		return fakeFlights, fmt.Errorf("parsing test data: %w", err)
+	}
+
+	return flightData, nil
+}
@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// A schedule client with a name, for use in a composite client
+type Provider struct {
+	Name   string
+	Client ScheduleClient
+}
+
+// A destination airport, with the names of the providers which listed it
+type Destination struct {
+	Airport   string
+	Providers []string
+}
+
+// Schedule client which wraps several other clients. By default, the providers are tried in priority
+// order, falling back to the next one whenever a provider fails (e.g. because it has run out of quota)
+// or doesn't find any destinations. Alternatively, the results from all the providers can be merged.
+type CompositeClient struct {
+	providers []Provider
+	merge     bool
+}
+
+// Creates a composite client, with the providers given in priority order
+func NewCompositeClient(providers []Provider, merge bool) *CompositeClient {
+	client := CompositeClient{providers: providers, merge: merge}
+	return &client
+}
+
+func (client *CompositeClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
+
+	sourced, err := client.GetSourcedDestinations(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations = make([]string, 0, len(sourced))
+	for _, dest := range sourced {
+		destinations = append(destinations, dest.Airport)
+	}
+
+	return destinations, nil
+}
+
+// Gets the destinations from the given airport, along with the providers which supplied them
+func (client *CompositeClient) GetSourcedDestinations(origin string) (destinations []Destination, err error) {
+
+	if len(client.providers) == 0 {
+		return nil, errors.New("no schedule providers have been configured")
+	}
+
+	providersByDest := make(map[string][]string)
+	failures := errors.New("getting scheduled destinations")
+	succeeded := false
+
+	for _, provider := range client.providers {
+
+		found, err := provider.Client.GetScheduledDestinations(origin)
+		if err != nil {
+			slog.Warn("Schedule provider failed", "provider", provider.Name, "origin", origin, "error", err)
+			failures = fmt.Errorf("%w; %s: %w", failures, provider.Name, err)
+			continue
+		}
+		slog.Debug("Schedule provider found destinations", "provider", provider.Name, "origin", origin, "destinations", len(found))
+		succeeded = true
+
+		for _, dest := range found {
+			providersByDest[dest] = append(providersByDest[dest], provider.Name)
+		}
+
+		// Stop at the first provider with any results, unless merging
+		if !client.merge && len(found) > 0 {
+			break
+		}
+	}
+
+	if !succeeded {
+		return nil, failures
+	}
+
+	destinations = make([]Destination, 0, len(providersByDest))
+	for airport, providers := range providersByDest {
+		destinations = append(destinations, Destination{Airport: airport, Providers: providers})
+	}
+	sort.Slice(destinations, func(i, j int) bool { return destinations[i].Airport < destinations[j].Airport })
+
+	return destinations, nil
+}
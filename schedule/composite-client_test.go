@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fakeScheduleClient struct {
+	destinations []string
+	err          error
+}
+
+func (client *fakeScheduleClient) GetScheduledDestinations(origin string) ([]string, error) {
+	return client.destinations, client.err
+}
+
+func TestCompositeClientFallback(t *testing.T) {
+
+	// Arrange
+	client := NewCompositeClient([]Provider{
+		{Name: "failing", Client: &fakeScheduleClient{err: errors.New("quota exceeded")}},
+		{Name: "empty", Client: &fakeScheduleClient{}},
+		{Name: "fixture", Client: &fakeScheduleClient{destinations: []string{"CPH", "ARN"}}},
+		{Name: "unused", Client: &fakeScheduleClient{destinations: []string{"LHR"}}},
+	}, false)
+
+	// Act
+	actual, err := client.GetSourcedDestinations("OSL")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Destination{
+		{Airport: "ARN", Providers: []string{"fixture"}},
+		{Airport: "CPH", Providers: []string{"fixture"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Found %v; Expected %v", actual, expected)
+	}
+}
+
+func TestCompositeClientMerge(t *testing.T) {
+
+	// Arrange
+	client := NewCompositeClient([]Provider{
+		{Name: "first", Client: &fakeScheduleClient{destinations: []string{"CPH"}}},
+		{Name: "failing", Client: &fakeScheduleClient{err: errors.New("unavailable")}},
+		{Name: "second", Client: &fakeScheduleClient{destinations: []string{"CPH", "LHR"}}},
+	}, true)
+
+	// Act
+	actual, err := client.GetSourcedDestinations("OSL")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Destination{
+		{Airport: "CPH", Providers: []string{"first", "second"}},
+		{Airport: "LHR", Providers: []string{"second"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Found %v; Expected %v", actual, expected)
+	}
+}
+
+func TestCompositeClientAllFailing(t *testing.T) {
+
+	client := NewCompositeClient([]Provider{
+		{Name: "first", Client: &fakeScheduleClient{err: errors.New("unavailable")}},
+		{Name: "second", Client: &fakeScheduleClient{err: errors.New("unauthorized")}},
+	}, false)
+
+	if _, err := client.GetSourcedDestinations("OSL"); err == nil {
+		t.Error("Expected an error when every provider fails")
+	}
+}
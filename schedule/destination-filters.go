@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"flynow/airlines"
+	"flynow/airports"
+	"flynow/pipeline"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The destination filters decide which scheduled flights count towards the destinations to search. The
+// built-in filters are registered here, and others can be added with RegisterDestinationFilter. Unless the
+// config gives the filters to use, the defaults are used, in this order:
+//
+//	origin    only flights departing from the airport searched from
+//	airlines  only flights operated by the airlines allowed by the airline filter
+//
+// The exclude filter isn't used by default, but skips the destinations given by its parameters, e.g.
+//
+//	{"stage": "exclude", "params": {"airports": ["TRF"], "countries": ["GB"]}}
+
+// A scheduled flight, as seen by the destination filters. The airline is empty if the source doesn't say
+// which airline flies the route.
+type Flight struct {
+	Source       string
+	FlightNumber string
+	Airline      string
+	Origin       string
+	Destination  string
+}
+
+// The search the scheduled flights were found for
+type DestinationSearch struct {
+	Origin   string
+	Airlines airlines.Filter
+}
+
+// Decides whether a scheduled flight counts towards the destinations, and if not, gives the reason for
+// the audit (e.g. "departs from TRF, not OSL")
+type DestinationFilter interface {
+	CheckFlight(search DestinationSearch, flight Flight) (accepted bool, reason string)
+}
+
+// Adapts an ordinary function to the DestinationFilter interface
+type DestinationFilterFunc func(search DestinationSearch, flight Flight) (accepted bool, reason string)
+
+func (f DestinationFilterFunc) CheckFlight(search DestinationSearch, flight Flight) (accepted bool, reason string) {
+	return f(search, flight)
+}
+
+var destinationFilters = newDestinationFilterRegistry()
+
+var defaultDestinationFilters = []string{"origin", "airlines"}
+
+// Makes a destination filter available to the config, under the given name. This panics if the name is
+// already used, e.g. by one of the built-in filters.
+func RegisterDestinationFilter(name string, factory pipeline.Factory[DestinationFilter]) {
+	destinationFilters.Register(name, factory)
+}
+
+// Gets the names of the destination filters which can be used in the config
+func GetDestinationFilterNames() []string {
+	return destinationFilters.GetNames()
+}
+
+// The filters used by the schedule sources, and the airline filter they are given
+type DestinationFilters struct {
+	Airlines airlines.Filter
+
+	// The filters, in order, or the defaults if nil
+	Stages []pipeline.Named[DestinationFilter]
+}
+
+// Creates the given destination filters, in the same order, or the default filters if none are given
+func NewDestinationFilters(stages []pipeline.Stage, airlineFilter airlines.Filter) (DestinationFilters, error) {
+
+	if len(stages) == 0 {
+		stages = pipeline.GetStages(defaultDestinationFilters...)
+	}
+
+	built, err := destinationFilters.Build(stages)
+	if err != nil {
+		return DestinationFilters{}, err
+	}
+	return DestinationFilters{Airlines: airlineFilter, Stages: built}, nil
+}
+
+// The built-in filters used by default take no parameters, so building them can't fail
+var getDefaultDestinationFilters = sync.OnceValue(func() []pipeline.Named[DestinationFilter] {
+
+	filters, err := NewDestinationFilters(nil, airlines.Filter{})
+	if err != nil {
+		panic(err)
+	}
+	return filters.Stages
+})
+
+// Checks whether the destination of a scheduled flight should be searched, and gives the rule which
+// decided it
+func (filters DestinationFilters) Check(origin string, flight Flight) (accepted bool, reason string) {
+
+	stages := filters.Stages
+	if stages == nil {
+		stages = getDefaultDestinationFilters()
+	}
+
+	search := DestinationSearch{Origin: origin, Airlines: filters.Airlines}
+	names := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		if accepted, reason := stage.Stage.CheckFlight(search, flight); !accepted {
+			return false, reason
+		}
+		names = append(names, stage.Name)
+	}
+
+	return true, fmt.Sprintf("passed every destination filter (%s)", strings.Join(names, ", "))
+}
+
+// Checks whether a flight counts towards the destinations, without the reason
+func (filters DestinationFilters) Allows(origin string, flight Flight) bool {
+
+	accepted, _ := filters.Check(origin, flight)
+	return accepted
+}
+
+func newDestinationFilterRegistry() *pipeline.Registry[DestinationFilter] {
+
+	registry := pipeline.NewRegistry[DestinationFilter]("destination filter")
+	registry.Register("origin", func(params json.RawMessage) (DestinationFilter, error) {
+		return DestinationFilterFunc(checkOrigin), pipeline.NoParams(params)
+	})
+	registry.Register("airlines", func(params json.RawMessage) (DestinationFilter, error) {
+		return DestinationFilterFunc(checkAirline), pipeline.NoParams(params)
+	})
+	registry.Register("exclude", newExcludeFilter)
+	return registry
+}
+
+// This shouldn't happen, but just in case
+func checkOrigin(search DestinationSearch, flight Flight) (bool, string) {
+
+	if flight.Origin != search.Origin {
+		return false, fmt.Sprintf("departs from %s, not %s", flight.Origin, search.Origin)
+	}
+	return true, ""
+}
+
+// A flight by an unknown airline is accepted, since it can still be filtered by airline when it's priced
+func checkAirline(search DestinationSearch, flight Flight) (bool, string) {
+
+	if flight.Airline != "" && !search.Airlines.Allows(flight.Airline) {
+		return false, fmt.Sprintf("airline %s is excluded by the airline filter", flight.Airline)
+	}
+	return true, ""
+}
+
+// Creates the filter which skips the given destination airports, and the airports in the given countries
+// (as ISO 3166-1 alpha-2 codes)
+func newExcludeFilter(params json.RawMessage) (DestinationFilter, error) {
+
+	var settings struct {
+		Airports  []string `json:"airports"`
+		Countries []string `json:"countries"`
+	}
+	if err := pipeline.DecodeParams(params, &settings); err != nil {
+		return nil, err
+	}
+	if len(settings.Airports) == 0 && len(settings.Countries) == 0 {
+		return nil, errors.New("expected airports or countries to exclude")
+	}
+	for _, country := range settings.Countries {
+		if len(country) != 2 {
+			return nil, fmt.Errorf("invalid country code %q (expected a 2-letter ISO code)", country)
+		}
+	}
+
+	excludeFilter := func(search DestinationSearch, flight Flight) (bool, string) {
+
+		if slices.ContainsFunc(settings.Airports, func(code string) bool { return strings.EqualFold(code, flight.Destination) }) {
+			return false, fmt.Sprintf("destination %s is excluded", flight.Destination)
+		}
+		if airport, found := airports.Lookup(flight.Destination); found {
+			if slices.ContainsFunc(settings.Countries, func(code string) bool { return strings.EqualFold(code, airport.Country) }) {
+				return false, fmt.Sprintf("destination %s is in %s, which is excluded", flight.Destination, airport.Country)
+			}
+		}
+		return true, ""
+	}
+
+	return DestinationFilterFunc(excludeFilter), nil
+}
+
+// Gets a flight from AviationStack (or a fixture in the same format), as seen by the filters
+func newFlight(source string, flight flightInfo) Flight {
+
+	return Flight{
+		Source:       source,
+		FlightNumber: flight.Number.Number,
+		Airline:      flight.Airline.Code,
+		Origin:       flight.Departure.Airport,
+		Destination:  flight.Arrival.Airport,
+	}
+}
@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"encoding/json"
+	"flynow/airlines"
+	"flynow/pipeline"
+	"testing"
+)
+
+func TestDestinationFilters(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		stages         string
+		flight         Flight
+		expectedReason string
+	}{
+		{"default filters", `[]`, Flight{Airline: "DY", Origin: "OSL", Destination: "CPH"}, "passed every destination filter (origin, airlines)"},
+		{"another origin", `[]`, Flight{Airline: "DY", Origin: "TRF", Destination: "CPH"}, "departs from TRF, not OSL"},
+		{"excluded airline", `[]`, Flight{Airline: "FR", Origin: "OSL", Destination: "AGP"}, "airline FR is excluded by the airline filter"},
+		{"unknown airline", `[]`, Flight{Origin: "OSL", Destination: "AGP"}, "passed every destination filter (origin, airlines)"},
+		{"excluded airport", `[{"stage": "exclude", "params": {"airports": ["cph"]}}]`, Flight{Airline: "DY", Origin: "OSL", Destination: "CPH"}, "destination CPH is excluded"},
+		{"excluded country", `[{"stage": "exclude", "params": {"countries": ["SE"]}}]`, Flight{Airline: "SK", Origin: "OSL", Destination: "ARN"}, "destination ARN is in SE, which is excluded"},
+		{"other country", `[{"stage": "exclude", "params": {"countries": ["SE"]}}]`, Flight{Airline: "FR", Origin: "TRF", Destination: "AGP"}, "passed every destination filter (exclude)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Arrange
+			var stages []pipeline.Stage
+			if err := json.Unmarshal([]byte(test.stages), &stages); err != nil {
+				t.Fatalf("Unable to parse stages: %v", err)
+			}
+			filters, err := NewDestinationFilters(stages, airlines.Filter{Exclude: []string{"FR"}})
+			if err != nil {
+				t.Fatalf("NewDestinationFilters failed: %v", err)
+			}
+
+			// Act
+			_, reason := filters.Check("OSL", test.flight)
+
+			// Assert
+			if reason != test.expectedReason {
+				t.Errorf("Got %q; Expected %q", reason, test.expectedReason)
+			}
+		})
+	}
+}
+
+func TestExcludeFilterNeedsParameters(t *testing.T) {
+
+	// Arrange
+	stages := []pipeline.Stage{{Name: "exclude", Params: json.RawMessage(`{"countries": ["Sweden"]}`)}}
+
+	// Act
+	_, err := NewDestinationFilters(stages, airlines.Filter{})
+	_, missingErr := NewDestinationFilters([]pipeline.Stage{{Name: "exclude"}}, airlines.Filter{})
+
+	// Assert
+	if err == nil || err.Error() != `creating destination filter "exclude": invalid country code "Sweden" (expected a 2-letter ISO code)` {
+		t.Errorf("Got error %v; Expected the country code to be rejected", err)
+	}
+	if missingErr == nil || missingErr.Error() != `creating destination filter "exclude": expected airports or countries to exclude` {
+		t.Errorf("Got error %v; Expected the missing parameters to be reported", missingErr)
+	}
+}
@@ -0,0 +1,39 @@
+package schedule
+
+import (
+	"encoding/json"
+	"flynow/config"
+	"fmt"
+	"os"
+)
+
+// Gets a schedule client which reads the flights from a file in the same format as the AviationStack
+// flights response (such as sample-scheduled-flights.json), rather than calling the API. This is useful
+// as a fallback, or for testing and demos. If an audit is given, the decision about each flight is recorded in it.
+func GetFixtureClient(path string, filters DestinationFilters, audit *Audit) ScheduleClient {
+	client := fixtureClient{path: path, filters: filters, audit: audit}
+	return &client
+}
+
+type fixtureClient struct {
+	path    string
+	filters DestinationFilters
+	audit   *Audit
+}
+
+// Reads the flights from the fixture file, and finds the unique destinations from the given airport
+func (client *fixtureClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
+
+	data, err := os.ReadFile(client.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule fixture: %w", err)
+	}
+
+	var scheduledFlights flightsResponse
+	if err := json.Unmarshal(data, &scheduledFlights); err != nil {
+		return nil, fmt.Errorf("parsing schedule fixture: %w", err)
+	}
+
+	client.audit.recordFlights(config.FixtureSource, origin, scheduledFlights.Flights, client.filters)
+	return findUniqueDestinations(config.FixtureSource, origin, scheduledFlights.Flights, client.filters), nil
+}
@@ -0,0 +1,33 @@
+package schedule
+
+import (
+	"flynow/airports"
+	"flynow/config"
+	"time"
+)
+
+// Gets a schedule client which predicts the destinations from the history in the route database, rather
+// than calling an API. A destination is predicted if it had a flight on the same weekday, at a later time
+// of day, on at least the given fraction (0 to 1) of the days recorded so far.
+func GetLearnedClient(routes *RouteDatabase, minConfidence float64, filters DestinationFilters) ScheduleClient {
+	client := learnedClient{routes: routes, minConfidence: minConfidence, filters: filters}
+	return &client
+}
+
+type learnedClient struct {
+	routes        *RouteDatabase
+	minConfidence float64
+	filters       DestinationFilters
+}
+
+// Predicts the destinations of the flights which haven't yet departed today
+func (client *learnedClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
+
+	// The routes were recorded with the local weekday and hour, so the current ones are at the origin too
+	now := time.Now().In(airports.GetLocation(origin))
+	allows := func(flight Flight) bool {
+		flight.Source = config.LearnedSource
+		return client.filters.Allows(origin, flight)
+	}
+	return client.routes.PredictDestinations(origin, now.Weekday(), now.Hour(), 24, client.minConfidence, allows), nil
+}
@@ -1,7 +1,190 @@
 package schedule
 
+import (
+	"flynow/amadeus"
+	"flynow/config"
+	"flynow/credentials"
+	"flynow/quota"
+	"fmt"
+	"sort"
+)
+
+// Interface for the flight schedule client, so that it can be mocked in consuming code
+
+type ScheduleClient interface {
+	GetScheduledDestinations(origin string) ([]string, error)
+}
+
+// The APIs used by the schedule sources, and how to access them
+type Endpoints struct {
+	AviationStackUrl string
+	Amadeus          *amadeus.Client
+
+	// Provides the AviationStack API key
+	Credentials credentials.Provider
+
+	// Keeps track of the AviationStack monthly quota, if set
+	AviationStackQuota *quota.Tracker
+
+	// Records the decision about each scheduled flight, if set
+	Audit *Audit
+}
+
+// Gets a schedule client for the sources selected in the config, which only considers flights which pass
+// the destination filters (as far as each source makes that possible). The sources are combined into a
+// composite client, so that each destination records which source supplied it.
+func GetClient(filters DestinationFilters, settings config.Schedule, endpoints Endpoints) (ScheduleClient, error) {
+
+	// The route database is only opened if one of the sources uses it, and then only once
+	var routes *RouteDatabase
+	getRoutes := func() (*RouteDatabase, error) {
+		if routes == nil {
+			var err error
+			if routes, err = OpenRouteDatabase(settings.RouteDatabase); err != nil {
+				return nil, err
+			}
+		}
+		return routes, nil
+	}
+
+	providers := make([]Provider, 0, len(settings.Sources))
+	for _, source := range settings.Sources {
+		client, err := getSourceClient(source, filters, settings, endpoints, getRoutes)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, Provider{Name: source, Client: client})
+	}
+
+	return NewCompositeClient(providers, settings.Merge), nil
+}
+
+// Gets the schedule client for a single source, using the given function to get the route database for the
+// sources which record or predict routes
+func getSourceClient(source string, filters DestinationFilters, settings config.Schedule, endpoints Endpoints, getRoutes func() (*RouteDatabase, error)) (ScheduleClient, error) {
+
+	switch source {
+	case config.AviationStackSource:
+		routes, err := getRoutes()
+		if err != nil {
+			return nil, err
+		}
+		return GetAviationStackClient(endpoints.AviationStackUrl, endpoints.Credentials, filters, routes, endpoints.AviationStackQuota, endpoints.Audit), nil
+	case config.LearnedSource:
+		routes, err := getRoutes()
+		if err != nil {
+			return nil, err
+		}
+		return GetLearnedClient(routes, settings.RouteConfidence, filters), nil
+	case config.AmadeusRoutesSource:
+		return GetAmadeusRoutesClient(endpoints.Amadeus, filters), nil
+	case config.FixtureSource:
+		return GetFixtureClient(settings.Fixture, filters, endpoints.Audit), nil
+	case config.TimetableSource:
+		return GetTimetableClient(settings.Timetable, filters), nil
+	case config.CrossCheckSource:
+		routes, err := getRoutes()
+		if err != nil {
+			return nil, err
+		}
+		// Only the destinations from the day's actual flights which are also known direct routes
+		client := crossCheckClient{
+			first:  GetAviationStackClient(endpoints.AviationStackUrl, endpoints.Credentials, filters, routes, endpoints.AviationStackQuota, endpoints.Audit),
+			second: GetAmadeusRoutesClient(endpoints.Amadeus, filters),
+		}
+		return &client, nil
+	default:
+		return nil, fmt.Errorf("unknown schedule source %q", source)
+	}
+}
+
 // Gets a list of scheduled destination airports, using the given API client.
 func GetDestinations(origin string, client ScheduleClient) (destinations []string, err error) {
 
 	return client.GetScheduledDestinations(origin)
 }
+
+// Gets a list of scheduled destination airports, along with the providers which supplied them. If the client
+// isn't a composite client, all the destinations are attributed to the given provider name.
+func GetSourcedDestinations(origin string, client ScheduleClient, providerName string) (destinations []Destination, err error) {
+
+	if composite, ok := client.(*CompositeClient); ok {
+		return composite.GetSourcedDestinations(origin)
+	}
+
+	airports, err := client.GetScheduledDestinations(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations = make([]Destination, 0, len(airports))
+	for _, airport := range airports {
+		destinations = append(destinations, Destination{Airport: airport, Providers: []string{providerName}})
+	}
+
+	return destinations, nil
+}
+
+// Result of comparing the destinations given by two different schedule clients
+type DestinationComparison struct {
+	InBoth       []string
+	OnlyInFirst  []string
+	OnlyInSecond []string
+}
+
+// Gets the destinations from two schedule clients, and compares them. This can be used to cross-check
+// one source of schedule data against another.
+func CompareDestinations(origin string, first ScheduleClient, second ScheduleClient) (comparison DestinationComparison, err error) {
+
+	firstDestinations, err := first.GetScheduledDestinations(origin)
+	if err != nil {
+		return comparison, fmt.Errorf("getting first list of destinations: %w", err)
+	}
+	secondDestinations, err := second.GetScheduledDestinations(origin)
+	if err != nil {
+		return comparison, fmt.Errorf("getting second list of destinations: %w", err)
+	}
+
+	inSecond := make(map[string]bool)
+	for _, dest := range secondDestinations {
+		inSecond[dest] = true
+	}
+
+	comparison.InBoth = []string{}
+	comparison.OnlyInFirst = []string{}
+	comparison.OnlyInSecond = []string{}
+
+	for _, dest := range firstDestinations {
+		if inSecond[dest] {
+			comparison.InBoth = append(comparison.InBoth, dest)
+			delete(inSecond, dest)
+		} else {
+			comparison.OnlyInFirst = append(comparison.OnlyInFirst, dest)
+		}
+	}
+	for dest := range inSecond {
+		comparison.OnlyInSecond = append(comparison.OnlyInSecond, dest)
+	}
+
+	sort.Strings(comparison.InBoth)
+	sort.Strings(comparison.OnlyInFirst)
+	sort.Strings(comparison.OnlyInSecond)
+
+	return comparison, nil
+}
+
+// Schedule client which only returns the destinations confirmed by both of two other clients
+type crossCheckClient struct {
+	first  ScheduleClient
+	second ScheduleClient
+}
+
+func (client *crossCheckClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
+
+	comparison, err := CompareDestinations(origin, client.first, client.second)
+	if err != nil {
+		return nil, err
+	}
+
+	return comparison.InBoth, nil
+}
@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"flynow/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetClientOnlyOpensRouteDatabaseWhenUsed(t *testing.T) {
+
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{config.AmadeusRoutesSource, ""},
+		{config.FixtureSource, ""},
+		{config.AviationStackSource, "parsing route database"},
+		{config.LearnedSource, "parsing route database"},
+		{config.CrossCheckSource, "parsing route database"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+
+			// Arrange
+			path := filepath.Join(t.TempDir(), "routes.json")
+			if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+				t.Fatalf("Unable to write route database: %v", err)
+			}
+			settings := config.Schedule{Sources: []string{test.source}, RouteDatabase: path, Fixture: "sample-scheduled-flights.json"}
+
+			// Act
+			_, err := GetClient(DestinationFilters{}, settings, Endpoints{})
+
+			// Assert
+			actual := ""
+			if err != nil {
+				actual = err.Error()
+			}
+			if test.expected == "" && err != nil {
+				t.Errorf("Got error %v; Expected the route database not to be opened", err)
+			}
+			if !strings.Contains(actual, test.expected) {
+				t.Errorf("Got error %q; Expected it to contain %q", actual, test.expected)
+			}
+		})
+	}
+}
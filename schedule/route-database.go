@@ -0,0 +1,245 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Local database of the routes seen in the AviationStack responses, so that the information we pay for
+// (in quota) can be reused to predict which destinations are likely on a given weekday and time of day.
+type RouteDatabase struct {
+	path  string
+	mutex sync.Mutex
+	data  routeDatabaseFile
+}
+
+// Persisted contents of the route database
+type routeDatabaseFile struct {
+	// The dates on which schedules have been recorded, for each origin
+	Snapshots map[string][]string `json:"snapshots"`
+
+	// The flights seen on each route, by origin and then destination
+	Routes map[string]map[string][]routeObservation `json:"routes"`
+}
+
+// A single scheduled flight seen on a route
+type routeObservation struct {
+	Date    string       `json:"date"`
+	Weekday time.Weekday `json:"weekday"`
+	Hour    int          `json:"hour"`
+	Airline string       `json:"airline"`
+	Flight  string       `json:"flight"`
+}
+
+// How long observations are kept before the most recent one, so that old schedules (e.g. from a previous
+// season) fade out
+const routeHistoryDays = 182
+
+// Opens the route database stored in the given file. If the file doesn't exist yet, the database is empty,
+// and the file is created when the first schedule is recorded.
+func OpenRouteDatabase(path string) (*RouteDatabase, error) {
+
+	db := RouteDatabase{
+		path: path,
+		data: routeDatabaseFile{
+			Snapshots: make(map[string][]string),
+			Routes:    make(map[string]map[string][]routeObservation),
+		},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading route database: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &db.data); err != nil {
+		return nil, fmt.Errorf("parsing route database %s: %w", path, err)
+	}
+
+	return &db, nil
+}
+
+// Adds the flights from a schedule response to the database, and saves it
+func (db *RouteDatabase) Record(origin string, flights []flightInfo) error {
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.data.Routes[origin] == nil {
+		db.data.Routes[origin] = make(map[string][]routeObservation)
+	}
+
+	for _, flight := range flights {
+		if flight.Departure.Airport != origin || flight.Arrival.Airport == "" {
+			continue
+		}
+
+		observation, err := newRouteObservation(flight)
+		if err != nil {
+			continue
+		}
+
+		db.addSnapshot(origin, observation.Date)
+		db.addObservation(origin, flight.Arrival.Airport, observation)
+	}
+
+	db.prune(origin)
+
+	return db.save()
+}
+
+// Gets the destinations which have had flights on the given weekday, departing within the given hours, on
+// at least the given fraction (0 to 1) of the recorded days. Only flights which are allowed by the given
+// function (e.g. the destination filters) are considered.
+func (db *RouteDatabase) PredictDestinations(origin string, weekday time.Weekday, fromHour int, toHour int, minConfidence float64, allows func(flight Flight) bool) []string {
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	// Count the recorded days for this weekday, since a route can only be predicted from those
+	days := 0
+	for _, date := range db.data.Snapshots[origin] {
+		if d, err := time.Parse(time.DateOnly, date); err == nil && d.Weekday() == weekday {
+			days++
+		}
+	}
+	if days == 0 {
+		return []string{}
+	}
+
+	destinations := make([]string, 0)
+	for dest, observations := range db.data.Routes[origin] {
+
+		datesSeen := make(map[string]bool)
+		for _, o := range observations {
+			if o.Weekday == weekday && o.Hour >= fromHour && o.Hour < toHour && allows(Flight{FlightNumber: o.Flight, Airline: o.Airline, Origin: origin, Destination: dest}) {
+				datesSeen[o.Date] = true
+			}
+		}
+
+		if float64(len(datesSeen))/float64(days) >= minConfidence {
+			destinations = append(destinations, dest)
+		}
+	}
+	sort.Strings(destinations)
+
+	return destinations
+}
+
+func newRouteObservation(flight flightInfo) (observation routeObservation, err error) {
+
+	date, err := time.Parse(time.DateOnly, flight.Date)
+	if err != nil {
+		return observation, err
+	}
+
+	// Note: AviationStack gives the local time, even though it's labelled as UTC
+	departure, err := time.Parse(time.RFC3339, flight.Departure.Time)
+	if err != nil {
+		return observation, err
+	}
+
+	observation.Date = flight.Date
+	observation.Weekday = date.Weekday()
+	observation.Hour = departure.Hour()
+	observation.Airline = flight.Airline.Code
+	observation.Flight = flight.Number.Number
+
+	return observation, nil
+}
+
+func (db *RouteDatabase) addSnapshot(origin string, date string) {
+
+	for _, d := range db.data.Snapshots[origin] {
+		if d == date {
+			return
+		}
+	}
+
+	db.data.Snapshots[origin] = append(db.data.Snapshots[origin], date)
+	sort.Strings(db.data.Snapshots[origin])
+}
+
+// Adds the observation, unless the same flight has already been seen on the same date
+func (db *RouteDatabase) addObservation(origin string, dest string, observation routeObservation) {
+
+	for _, o := range db.data.Routes[origin][dest] {
+		if o.Date == observation.Date && o.Flight == observation.Flight && o.Airline == observation.Airline && o.Hour == observation.Hour {
+			return
+		}
+	}
+
+	db.data.Routes[origin][dest] = append(db.data.Routes[origin][dest], observation)
+}
+
+// Removes the observations and snapshots for the origin which are older than the history period
+func (db *RouteDatabase) prune(origin string) {
+
+	dates := db.data.Snapshots[origin]
+	if len(dates) == 0 {
+		return
+	}
+
+	latest, err := time.Parse(time.DateOnly, dates[len(dates)-1])
+	if err != nil {
+		return
+	}
+	cutoff := latest.AddDate(0, 0, -routeHistoryDays).Format(time.DateOnly)
+
+	kept := make([]string, 0, len(dates))
+	for _, date := range dates {
+		if date >= cutoff {
+			kept = append(kept, date)
+		}
+	}
+	db.data.Snapshots[origin] = kept
+
+	routes := db.data.Routes[origin]
+	for dest, observations := range routes {
+		keptObservations := make([]routeObservation, 0, len(observations))
+		for _, o := range observations {
+			if o.Date >= cutoff {
+				keptObservations = append(keptObservations, o)
+			}
+		}
+		if len(keptObservations) == 0 {
+			delete(routes, dest)
+		} else {
+			routes[dest] = keptObservations
+		}
+	}
+}
+
+// Writes the database to a temporary file first, so that an interrupted save can't corrupt it
+func (db *RouteDatabase) save() error {
+
+	data, err := json.Marshal(db.data)
+	if err != nil {
+		return fmt.Errorf("serializing route database: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(db.path), 0o755); err != nil {
+		return fmt.Errorf("creating route database directory: %w", err)
+	}
+
+	tempPath := db.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing route database: %w", err)
+	}
+
+	if err := os.Rename(tempPath, db.path); err != nil {
+		return fmt.Errorf("writing route database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package schedule
+
+import (
+	"encoding/json"
+	"flynow/airlines"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRouteDatabasePrediction(t *testing.T) {
+
+	// Arrange
+	data, err := os.ReadFile("sample-scheduled-flights.json")
+	if err != nil {
+		t.Fatalf("Unable to read test data: %v", err)
+	}
+	var fakeResponse flightsResponse
+	if err := json.Unmarshal(data, &fakeResponse); err != nil {
+		t.Fatalf("Unable to parse test data: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "routes.json")
+	db, err := OpenRouteDatabase(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Act
+	if err := db.Record("OSL", fakeResponse.Flights); err != nil {
+		t.Fatalf("Unexpected error recording schedule: %v", err)
+	}
+	reopened, err := OpenRouteDatabase(path)
+	if err != nil {
+		t.Fatalf("Unexpected error reopening database: %v", err)
+	}
+	allDay := reopened.PredictDestinations("OSL", time.Monday, 0, 24, 0.5, func(Flight) bool { return true })
+	afternoon := reopened.PredictDestinations("OSL", time.Monday, 12, 24, 0.5, func(Flight) bool { return true })
+	norwegianOnly := reopened.PredictDestinations("OSL", time.Sunday, 0, 24, 0.5, func(flight Flight) bool { return airlines.Filter{Include: []string{"DY"}}.Allows(flight.Airline) })
+	otherDay := reopened.PredictDestinations("OSL", time.Tuesday, 0, 24, 0.5, func(Flight) bool { return true })
+
+	// Assert
+	if len(allDay) != 11 {
+		t.Errorf("Predicted %d destinations on Monday; Expected 11", len(allDay))
+	}
+	if expected := []string{"CDG", "CPH", "ORY", "RIX", "SOG", "TRD"}; !reflect.DeepEqual(afternoon, expected) {
+		t.Errorf("Predicted %v on Monday afternoon; Expected %v", afternoon, expected)
+	}
+	if expected := []string{"BDU", "TRD"}; !reflect.DeepEqual(norwegianOnly, expected) {
+		t.Errorf("Predicted %v for Norwegian on Sunday; Expected %v", norwegianOnly, expected)
+	}
+	if len(otherDay) != 0 {
+		t.Errorf("Predicted %v for a day without any recorded schedules", otherDay)
+	}
+}
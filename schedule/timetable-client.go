@@ -0,0 +1,162 @@
+package schedule
+
+import (
+	"flynow/airports"
+	"flynow/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A single flight from a timetable, which operates on certain days of the week within a period
+type timetableFlight struct {
+	Airline      string
+	FlightNumber string
+	Origin       string
+	Destination  string
+	Departure    time.Duration // Local time of day
+	Arrival      time.Duration // Local time of day
+	Days         [7]bool       // Indexed by time.Weekday
+	ValidFrom    time.Time     // The zero value means there is no start date
+	ValidTo      time.Time     // The zero value means there is no end date
+}
+
+// Checks whether the flight operates on the given date
+func (flight timetableFlight) operatesOn(date time.Time) bool {
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if !flight.ValidFrom.IsZero() && day.Before(flight.ValidFrom) {
+		return false
+	}
+	if !flight.ValidTo.IsZero() && day.After(flight.ValidTo) {
+		return false
+	}
+
+	return flight.Days[date.Weekday()]
+}
+
+// Gets a schedule client which reads the flights from a local timetable file, rather than calling an API.
+// Both IATA SSIM (Chapter 7) files and a simple CSV format are supported, based on the file extension:
+//
+//	airline,flight,origin,destination,departure,arrival,days,valid_from,valid_to
+//	DY,932,OSL,CPH,07:40,08:50,1234567,2024-03-31,2024-10-26
+//
+// The days are given as in SSIM, where 1 is Monday and 7 is Sunday, and the validity dates are optional.
+func GetTimetableClient(path string, filters DestinationFilters) *TimetableClient {
+	client := TimetableClient{path: path, filters: filters}
+	return &client
+}
+
+type TimetableClient struct {
+	path    string
+	filters DestinationFilters
+	flights []timetableFlight
+}
+
+// Gets the destinations of the flights which haven't yet departed today, in the same way as the
+// AviationStack client only considers flights in the "scheduled" state
+func (client *TimetableClient) GetScheduledDestinations(origin string) (destinations []string, err error) {
+
+	// The timetable gives local times, so "today" is the current day and time at the origin
+	now := time.Now().In(airports.GetLocation(origin))
+	timeOfDay := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+
+	return client.GetDestinationsInWindow(origin, now, timeOfDay, 24*time.Hour)
+}
+
+// Gets the destinations of the flights departing from the given airport on the given date, with a local
+// departure time within the given window (as a time of day).
+func (client *TimetableClient) GetDestinationsInWindow(origin string, date time.Time, from time.Duration, to time.Duration) (destinations []string, err error) {
+
+	if err := client.load(); err != nil {
+		return nil, err
+	}
+
+	destMap := make(map[string]bool)
+	for _, flight := range client.flights {
+		if flight.Origin != origin || !flight.operatesOn(date) {
+			continue
+		}
+		if flight.Departure < from || flight.Departure >= to {
+			continue
+		}
+		candidate := Flight{
+			Source:       config.TimetableSource,
+			FlightNumber: flight.Airline + flight.FlightNumber,
+			Airline:      flight.Airline,
+			Origin:       flight.Origin,
+			Destination:  flight.Destination,
+		}
+		if !client.filters.Allows(origin, candidate) {
+			continue
+		}
+		destMap[flight.Destination] = true
+	}
+
+	destinations = make([]string, 0, len(destMap))
+	for dest := range destMap {
+		destinations = append(destinations, dest)
+	}
+	sort.Strings(destinations)
+
+	return destinations, nil
+}
+
+// Reads and parses the timetable file, if that hasn't already been done
+func (client *TimetableClient) load() error {
+
+	if client.flights != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(client.path)
+	if err != nil {
+		return fmt.Errorf("reading timetable: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(client.path), ".csv") {
+		client.flights, err = parseCsvTimetable(string(data))
+	} else {
+		client.flights, err = parseSsimTimetable(string(data))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing timetable %s: %w", client.path, err)
+	}
+
+	return nil
+}
+
+// Parses a time of day in the form HHMM or HH:MM
+func parseTimeOfDay(value string) (time.Duration, error) {
+
+	value = strings.ReplaceAll(strings.TrimSpace(value), ":", "")
+	t, err := time.Parse("1504", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Parses the days of operation, where each day that the flight operates is given by its number (1 for
+// Monday through 7 for Sunday), in any order. Other characters (e.g. spaces or dots) are ignored.
+func parseDaysOfOperation(value string) (days [7]bool, err error) {
+
+	found := false
+	for _, c := range value {
+		if c >= '1' && c <= '7' {
+			days[time.Weekday((c-'0')%7)] = true
+			found = true
+		} else if c != ' ' && c != '.' && c != '-' {
+			return days, fmt.Errorf("invalid days of operation %q", value)
+		}
+	}
+
+	if !found {
+		return days, fmt.Errorf("no days of operation in %q", value)
+	}
+	return days, nil
+}
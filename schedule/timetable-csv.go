@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Parsing of timetables in a simple CSV format, with a header row naming the columns. The columns can be
+// in any order, and the valid_from and valid_to columns are optional.
+
+var csvTimetableColumns = []string{"airline", "flight", "origin", "destination", "departure", "arrival", "days"}
+
+func parseCsvTimetable(data string) (flights []timetableFlight, err error) {
+
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range csvTimetableColumns {
+		if _, found := columns[name]; !found {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	flights = make([]timetableFlight, 0)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		flight, err := parseCsvFlight(row, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		flights = append(flights, flight)
+	}
+
+	return flights, nil
+}
+
+func parseCsvFlight(row []string, columns map[string]int) (flight timetableFlight, err error) {
+
+	field := func(name string) string {
+		if i, found := columns[name]; found && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	flight.Airline = strings.ToUpper(field("airline"))
+	flight.FlightNumber = field("flight")
+	flight.Origin = strings.ToUpper(field("origin"))
+	flight.Destination = strings.ToUpper(field("destination"))
+
+	if flight.Departure, err = parseTimeOfDay(field("departure")); err != nil {
+		return flight, err
+	}
+	if flight.Arrival, err = parseTimeOfDay(field("arrival")); err != nil {
+		return flight, err
+	}
+	if flight.Days, err = parseDaysOfOperation(field("days")); err != nil {
+		return flight, err
+	}
+
+	if value := field("valid_from"); value != "" {
+		if flight.ValidFrom, err = time.Parse("2006-01-02", value); err != nil {
+			return flight, fmt.Errorf("invalid date %q", value)
+		}
+	}
+	if value := field("valid_to"); value != "" {
+		if flight.ValidTo, err = time.Parse("2006-01-02", value); err != nil {
+			return flight, fmt.Errorf("invalid date %q", value)
+		}
+	}
+
+	return flight, nil
+}
@@ -0,0 +1,163 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Parsing of IATA Standard Schedules Information Manual (SSIM) Chapter 7 files. Each flight leg is given in
+// a fixed-width type 3 record, and the type 2 record says whether the times are local or UTC. The other
+// record types (and the fields which aren't needed here) are ignored.
+
+// Service types for passenger flights (e.g. J for a normal scheduled passenger service)
+const ssimPassengerServiceTypes = "JSUGQ"
+
+// 1-based positions of the fields used from the type 3 (flight leg) record
+type ssimField struct {
+	start, end int
+}
+
+var (
+	ssimAirline         = ssimField{3, 5}
+	ssimFlightNumber    = ssimField{6, 9}
+	ssimServiceType     = ssimField{14, 14}
+	ssimPeriodFrom      = ssimField{15, 21}
+	ssimPeriodTo        = ssimField{22, 28}
+	ssimDays            = ssimField{29, 35}
+	ssimOrigin          = ssimField{37, 39}
+	ssimDeparture       = ssimField{40, 43}
+	ssimDepartureOffset = ssimField{48, 52}
+	ssimDestination     = ssimField{55, 57}
+	ssimArrival         = ssimField{62, 65}
+	ssimArrivalOffset   = ssimField{66, 70}
+)
+
+func parseSsimTimetable(data string) (flights []timetableFlight, err error) {
+
+	flights = make([]timetableFlight, 0)
+	utcTimes := false
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		record := strings.TrimRight(scanner.Text(), "\r")
+		if record == "" {
+			continue
+		}
+
+		switch record[0] {
+		case '2':
+			// The time mode is either U (UTC) or L (local)
+			utcTimes = len(record) > 1 && record[1] == 'U'
+		case '3':
+			flight, passenger, err := parseSsimFlightLeg(record, utcTimes)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			if passenger {
+				flights = append(flights, flight)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return flights, nil
+}
+
+// Parses a type 3 record. Non-passenger (e.g. cargo) flights are parsed, but passenger is false.
+func parseSsimFlightLeg(record string, utcTimes bool) (flight timetableFlight, passenger bool, err error) {
+
+	if len(record) < ssimArrivalOffset.end {
+		return flight, false, fmt.Errorf("flight leg record is too short (%d characters)", len(record))
+	}
+
+	field := func(f ssimField) string {
+		return strings.TrimSpace(record[f.start-1 : f.end])
+	}
+
+	flight.Airline = field(ssimAirline)
+	flight.FlightNumber = strings.TrimLeft(field(ssimFlightNumber), "0")
+	flight.Origin = field(ssimOrigin)
+	flight.Destination = field(ssimDestination)
+	passenger = strings.Contains(ssimPassengerServiceTypes, field(ssimServiceType))
+
+	if flight.Days, err = parseDaysOfOperation(field(ssimDays)); err != nil {
+		return flight, false, err
+	}
+
+	if flight.ValidFrom, err = parseSsimDate(field(ssimPeriodFrom)); err != nil {
+		return flight, false, err
+	}
+	if flight.ValidTo, err = parseSsimDate(field(ssimPeriodTo)); err != nil {
+		return flight, false, err
+	}
+
+	if flight.Departure, err = parseTimeOfDay(field(ssimDeparture)); err != nil {
+		return flight, false, err
+	}
+	if flight.Arrival, err = parseTimeOfDay(field(ssimArrival)); err != nil {
+		return flight, false, err
+	}
+
+	// Convert UTC times to local, using the UTC/local time variation of each station (e.g. +0200).
+	// Note: The days of operation still refer to the UTC date, so a flight shortly after midnight
+	// local time may be listed on the previous day.
+	if utcTimes {
+		offset, err := parseSsimTimeVariation(field(ssimDepartureOffset))
+		if err != nil {
+			return flight, false, err
+		}
+		flight.Departure = wrapTimeOfDay(flight.Departure + offset)
+
+		if offset, err = parseSsimTimeVariation(field(ssimArrivalOffset)); err != nil {
+			return flight, false, err
+		}
+		flight.Arrival = wrapTimeOfDay(flight.Arrival + offset)
+	}
+
+	return flight, passenger, nil
+}
+
+// Parses a date in the form 15APR24. The special value 00XXX00 means there is no end date.
+func parseSsimDate(value string) (time.Time, error) {
+
+	if value == "" || value == "00XXX00" {
+		return time.Time{}, nil
+	}
+
+	date, err := time.Parse("02Jan06", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	return date, nil
+}
+
+// Parses a UTC/local time variation in the form +HHMM or -HHMM
+func parseSsimTimeVariation(value string) (time.Duration, error) {
+
+	if len(value) != 5 || (value[0] != '+' && value[0] != '-') {
+		return 0, fmt.Errorf("invalid time variation %q", value)
+	}
+
+	offset, err := parseTimeOfDay(value[1:])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time variation %q", value)
+	}
+
+	if value[0] == '-' {
+		return -offset, nil
+	}
+	return offset, nil
+}
+
+// Keeps a time of day within 00:00 to 23:59 after applying a time variation
+func wrapTimeOfDay(timeOfDay time.Duration) time.Duration {
+
+	const day = 24 * time.Hour
+	return ((timeOfDay % day) + day) % day
+}
@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"flynow/airlines"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Records are padded to the SSIM record length of 200 characters
+func ssimRecord(record string) string {
+	return record + strings.Repeat(" ", 200-len(record))
+}
+
+func TestParseSsimTimetable(t *testing.T) {
+
+	// Arrange
+	data := strings.Join([]string{
+		ssimRecord("1AIRLINE STANDARD SCHEDULE DATA SET"),
+		ssimRecord("2UDY  S2431MAR2426OCT24"),
+		ssimRecord("3 DY 09320101J31MAR2426OCT241234567 OSL05400540+0200  CPH06500650+0200  73H"),
+		ssimRecord("3 DY 09480101J31MAR2426OCT2412345 7 OSL15051505+0200  CPH16151615+0200  73H"),
+		ssimRecord("3 DY 10000101F31MAR2426OCT241234567 OSL22002200+0200  CPH23102310+0200  73F"),
+	}, "\n")
+
+	// Act
+	flights, err := parseSsimTimetable(data)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(flights) != 2 {
+		t.Fatalf("Found %d passenger flights; Expected 2", len(flights))
+	}
+
+	first := flights[0]
+	if first.Airline != "DY" || first.FlightNumber != "932" || first.Origin != "OSL" || first.Destination != "CPH" {
+		t.Errorf("Found flight %s%s %s-%s; Expected DY932 OSL-CPH", first.Airline, first.FlightNumber, first.Origin, first.Destination)
+	}
+	if first.Departure != 7*time.Hour+40*time.Minute {
+		t.Errorf("Found departure %v; Expected 7h40m local time", first.Departure)
+	}
+	if flights[1].Days[time.Saturday] || !flights[1].Days[time.Sunday] {
+		t.Errorf("Found days %v; Expected every day except Saturday", flights[1].Days)
+	}
+}
+
+func TestTimetableClientWindow(t *testing.T) {
+
+	// Arrange
+	client := GetTimetableClient("sample-timetable.csv", DestinationFilters{})
+	tuesday := time.Date(2024, time.April, 16, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	actual, err := client.GetDestinationsInWindow("OSL", tuesday, 6*time.Hour, 9*time.Hour)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"ARN", "CDG", "CPH", "TRD"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Found %v; Expected %v", actual, expected)
+	}
+}
+
+func TestTimetableClientValidity(t *testing.T) {
+
+	// Arrange
+	client := GetTimetableClient("sample-timetable.csv", DestinationFilters{Airlines: airlines.Filter{Include: []string{"DY"}}})
+	winterSunday := time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	actual, err := client.GetDestinationsInWindow("OSL", winterSunday, 0, 24*time.Hour)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"TOS"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Found %v; Expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Removal of the secrets (API keys, client credentials and Bearer tokens) from HTTP requests and responses,
+// so that they can be saved or logged.
+
+// Placeholder written in place of any secret
+const Redacted = "REDACTED"
+
+// Query parameters and form fields which contain secrets
+var secretParameters = []string{"access_key", "client_id", "client_secret"}
+
+// Fields in JSON response bodies which contain secrets
+var secretFields = []string{"access_token"}
+
+// Removes any secrets from the query parameters of the URL
+func RedactUrl(u *url.URL) string {
+
+	redactedUrl := *u
+	redactedUrl.RawQuery = redactValues(u.Query()).Encode()
+
+	return redactedUrl.String()
+}
+
+// Removes any secrets from the request body, if it's form data
+func RedactRequestBody(body []byte, contentType string) string {
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			return redactValues(values).Encode()
+		}
+	}
+
+	return string(body)
+}
+
+// Removes any secrets (e.g. the Bearer token) from the response body, if it's a JSON object
+func RedactResponseBody(body []byte) string {
+
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return string(body)
+	}
+
+	changed := false
+	for _, name := range secretFields {
+		if _, found := fields[name]; found {
+			fields[name] = Redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return string(body)
+	}
+
+	if data, err := json.Marshal(fields); err == nil {
+		return string(data)
+	}
+	return string(body)
+}
+
+func redactValues(values url.Values) url.Values {
+
+	result := url.Values{}
+	for name, value := range values {
+		result[name] = value
+	}
+	for _, name := range secretParameters {
+		if result.Has(name) {
+			result.Set(name, Redacted)
+		}
+	}
+
+	return result
+}
+
+// Headers which contain secrets
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Gets a copy of the headers with any secrets removed
+func RedactHeader(header http.Header) http.Header {
+
+	result := header.Clone()
+	for _, name := range secretHeaders {
+		if result.Get(name) != "" {
+			result.Set(name, Redacted)
+		}
+	}
+
+	return result
+}
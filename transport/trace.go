@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Transport which logs every HTTP request and response (including the bodies) at the debug level, with the
+// secrets removed. This is meant for finding out what the APIs are actually sending back.
+type Tracer struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+// Creates a tracer which sends the requests on to the given transport, and logs them to the given logger
+func NewTracer(next http.RoundTripper, logger *slog.Logger) *Tracer {
+	tracer := Tracer{next: next, logger: logger}
+	return &tracer
+}
+
+func (tracer *Tracer) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	// Keep a copy of the request body, since sending the request consumes it
+	var requestBody []byte
+	if request.Body != nil {
+		data, err := io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		requestBody = data
+		request.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	logger := tracer.logger.With("method", request.Method, "url", RedactUrl(request.URL))
+	logger.Debug("HTTP request",
+		"headers", RedactHeader(request.Header),
+		"body", RedactRequestBody(requestBody, request.Header.Get("Content-Type")))
+
+	start := time.Now()
+	response, err := tracer.next.RoundTrip(request)
+	latency := time.Since(start)
+	if err != nil {
+		logger.Debug("HTTP request failed", "latency", latency, "error", err)
+		return nil, err
+	}
+
+	// Likewise, keep a copy of the response body, and give the caller a fresh reader for it
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		logger.Debug("HTTP response unreadable", "status", response.StatusCode, "latency", latency, "error", err)
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	logger.Debug("HTTP response",
+		"status", response.StatusCode,
+		"latency", latency,
+		"headers", RedactHeader(response.Header),
+		"body", RedactResponseBody(responseBody))
+
+	return response, nil
+}
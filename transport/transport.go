@@ -0,0 +1,35 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Central place for the HTTP transport used by all the API clients, so that it can be replaced as a
+// whole (e.g. to record or replay the API traffic) without each client needing to know about it.
+
+var mutex sync.RWMutex
+var roundTripper http.RoundTripper = http.DefaultTransport
+
+// Replaces the transport used by all HTTP clients created from now on
+func Use(rt http.RoundTripper) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	roundTripper = rt
+}
+
+// Gets the transport which is currently in use, e.g. so that it can be wrapped by another transport
+func Current() http.RoundTripper {
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return roundTripper
+}
+
+// Gets an HTTP client which uses the current transport
+func GetClient() *http.Client {
+	return &http.Client{Transport: Current()}
+}
@@ -0,0 +1,132 @@
+package where
+
+import (
+	"flynow/airports"
+	"flynow/pricing"
+	"slices"
+	"time"
+)
+
+// The context an expression is evaluated in
+type environment struct {
+	flight *pricing.FlightForPurchase
+	now    time.Time
+}
+
+// A field of the flight which can be used in an expression
+type field struct {
+	typ valueType
+	get func(flight *pricing.FlightForPurchase) value
+}
+
+func stringField(get func(flight *pricing.FlightForPurchase) string) field {
+	return field{stringType, func(f *pricing.FlightForPurchase) value { return value{s: get(f)} }}
+}
+
+func numberField(get func(flight *pricing.FlightForPurchase) float64) field {
+	return field{numberType, func(f *pricing.FlightForPurchase) value { return value{n: get(f)} }}
+}
+
+// An amount of money in the currency of the flight
+func moneyField(get func(flight *pricing.FlightForPurchase) float32) field {
+	return field{moneyType, func(f *pricing.FlightForPurchase) value { return value{n: float64(get(f)), currency: f.Currency} }}
+}
+
+func durationField(get func(flight *pricing.FlightForPurchase) time.Duration) field {
+	return field{durationType, func(f *pricing.FlightForPurchase) value { return value{d: get(f)} }}
+}
+
+func timeField(get func(flight *pricing.FlightForPurchase) time.Time) field {
+	return field{timeType, func(f *pricing.FlightForPurchase) value { return value{t: get(f)} }}
+}
+
+// Gets a detail of an airport from the airport database, or an empty string if it isn't in the database
+func airportField(code func(flight *pricing.FlightForPurchase) string, get func(airport airports.Airport) string) field {
+
+	return stringField(func(f *pricing.FlightForPurchase) string {
+		airport, _ := airports.Lookup(code(f))
+		return get(airport)
+	})
+}
+
+func getOrigin(flight *pricing.FlightForPurchase) string      { return flight.Origin }
+func getDestination(flight *pricing.FlightForPurchase) string { return flight.Destination }
+
+var fields = map[string]field{
+	"flight":  stringField(func(f *pricing.FlightForPurchase) string { return f.FlightNumber }),
+	"carrier": stringField(getCarrier),
+
+	"origin":         stringField(getOrigin),
+	"origin.name":    airportField(getOrigin, func(a airports.Airport) string { return a.Name }),
+	"origin.city":    airportField(getOrigin, func(a airports.Airport) string { return a.City }),
+	"origin.country": airportField(getOrigin, func(a airports.Airport) string { return a.Country }),
+	"dest":           stringField(getDestination),
+	"dest.name":      airportField(getDestination, func(a airports.Airport) string { return a.Name }),
+	"dest.city":      airportField(getDestination, func(a airports.Airport) string { return a.City }),
+	"dest.country":   airportField(getDestination, func(a airports.Airport) string { return a.Country }),
+
+	"depart":   timeField(func(f *pricing.FlightForPurchase) time.Time { return f.Departure }),
+	"arrive":   timeField(func(f *pricing.FlightForPurchase) time.Time { return f.Arrival }),
+	"duration": durationField(func(f *pricing.FlightForPurchase) time.Duration { return f.Arrival.Sub(f.Departure) }),
+	"stops":    numberField(func(f *pricing.FlightForPurchase) float64 { return float64(f.Stops) }),
+
+	"price":       moneyField(func(f *pricing.FlightForPurchase) float32 { return f.Price }),
+	"price.base":  moneyField(func(f *pricing.FlightForPurchase) float32 { return f.PriceDetails.Base }),
+	"price.taxes": moneyField(func(f *pricing.FlightForPurchase) float32 { return f.PriceDetails.Taxes }),
+	"price.fees":  moneyField(func(f *pricing.FlightForPurchase) float32 { return f.PriceDetails.Fees }),
+	"price.bags":  moneyField(func(f *pricing.FlightForPurchase) float32 { return f.PriceDetails.Bags }),
+	"currency":    stringField(func(f *pricing.FlightForPurchase) string { return f.Currency }),
+
+	"fare.brand": stringField(func(f *pricing.FlightForPurchase) string { return f.Fare.Brand }),
+	"fare.label": stringField(func(f *pricing.FlightForPurchase) string { return f.Fare.BrandLabel }),
+	"fare.cabin": stringField(func(f *pricing.FlightForPurchase) string { return f.Fare.Cabin }),
+	"fare.class": stringField(func(f *pricing.FlightForPurchase) string { return f.Fare.BookingClass }),
+	"fare.basis": stringField(func(f *pricing.FlightForPurchase) string { return f.Fare.FareBasis }),
+	"bags":       numberField(func(f *pricing.FlightForPurchase) float64 { return float64(f.PriceDetails.IncludedBags) }),
+	"amenities":  {listType, getAmenities},
+
+	"aircraft":      stringField(func(f *pricing.FlightForPurchase) string { return f.Aircraft }),
+	"distance":      numberField(func(f *pricing.FlightForPurchase) float64 { return f.Distance }),
+	"co2":           numberField(func(f *pricing.FlightForPurchase) float64 { return f.Co2 }),
+	"value":         moneyField(func(f *pricing.FlightForPurchase) float32 { return f.GetPricePer100Km() }),
+	"transfer.time": durationField(func(f *pricing.FlightForPurchase) time.Duration { return f.Transfer.Duration }),
+	"transfer.cost": moneyField(func(f *pricing.FlightForPurchase) float32 { return f.Transfer.Cost }),
+}
+
+// The type of the elements of the list fields
+var listFields = map[string]valueType{
+	"amenities": stringType,
+}
+
+// Gets the IATA code of the airline, from the start of the flight number
+func getCarrier(flight *pricing.FlightForPurchase) string {
+
+	if len(flight.FlightNumber) < 2 {
+		return flight.FlightNumber
+	}
+	return flight.FlightNumber[:2]
+}
+
+// Gets the categories of the amenities which are included in the fare (e.g. checked-bag or wifi), rather
+// than costing extra
+func getAmenities(flight *pricing.FlightForPurchase) value {
+
+	categories := make([]value, 0)
+	for _, amenity := range flight.Fare.Amenities {
+		if amenity.Category != "" && !amenity.Chargeable {
+			categories = append(categories, value{s: amenity.Category})
+		}
+	}
+	return value{list: categories}
+}
+
+// Gets the sorted names of the fields, for listing them in an error
+func getFieldNames() []string {
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
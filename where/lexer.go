@@ -0,0 +1,131 @@
+package where
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The kinds of token in an expression
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	numberToken
+	durationToken
+	stringToken
+	identifierToken
+	operatorToken
+)
+
+// A token, and its position in the expression (the byte offsets of its start and end, from 0). The text of
+// a string is without the quotes.
+type token struct {
+	kind     tokenKind
+	text     string
+	position int
+	end      int
+}
+
+func (t token) String() string {
+
+	if t.kind == endToken {
+		return "the end of the expression"
+	}
+	return `"` + t.text + `"`
+}
+
+// The operators and punctuation, with the longer ones first so that e.g. <= isn't read as <
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "+", "-"}
+
+// Splits an expression into tokens
+func tokenize(text string) ([]token, error) {
+
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(text) {
+		c := rune(text[i])
+		start := i
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+
+		case isDigit(c):
+			for i < len(text) && (isDigit(rune(text[i])) || text[i] == '.') {
+				i++
+			}
+
+			// A number followed directly by a unit is a duration, e.g. 2h or 1h30m
+			if i < len(text) && isLetter(rune(text[i])) {
+				for i < len(text) && (isDigit(rune(text[i])) || isLetter(rune(text[i])) || text[i] == '.') {
+					i++
+				}
+				tokens = append(tokens, token{durationToken, text[start:i], start, i})
+			} else {
+				tokens = append(tokens, token{numberToken, text[start:i], start, i})
+			}
+
+		case c == '"' || c == '\'':
+			value, end, err := readString(text, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{stringToken, value, start, end})
+			i = end
+
+		case isLetter(c):
+			for i < len(text) && (isLetter(rune(text[i])) || isDigit(rune(text[i])) || text[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{identifierToken, text[start:i], start, i})
+
+		default:
+			operator := ""
+			for _, o := range operators {
+				if strings.HasPrefix(text[i:], o) {
+					operator = o
+					break
+				}
+			}
+			if operator == "" {
+				r, _ := utf8.DecodeRuneInString(text[i:])
+				return nil, newSyntaxError(text, i, "unexpected character %q", r)
+			}
+			i += len(operator)
+			tokens = append(tokens, token{operatorToken, operator, start, i})
+		}
+	}
+
+	return append(tokens, token{endToken, "", len(text), len(text)}), nil
+}
+
+// Reads a string in single or double quotes, starting at the given position, where a backslash escapes the
+// next character. Gets the string, and the position after the closing quote.
+func readString(text string, start int) (value string, end int, err error) {
+
+	quote := text[start]
+	var s strings.Builder
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case quote:
+			return s.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(text) {
+				i++
+			}
+		}
+		s.WriteByte(text[i])
+	}
+
+	return "", 0, newSyntaxError(text, start, "the string isn't closed (missing %c)", quote)
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
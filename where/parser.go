@@ -0,0 +1,544 @@
+package where
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A type-checked part of an expression, which has been compiled into a function which evaluates it
+type expression struct {
+	typ  valueType
+	elem valueType // The type of the elements of a list
+
+	// The position in the text (the byte offsets of its start and end, from 0)
+	position int
+	end      int
+
+	evaluate func(env *environment) (value, error)
+}
+
+// A recursive descent parser, with one function for each level of precedence
+type parser struct {
+	text   string
+	tokens []token
+	next   int
+
+	// The amounts of money given in the expression, so that their currencies can be checked
+	amounts []amount
+}
+
+// An amount of money given in an expression (e.g. 1500 NOK), and where it is
+type amount struct {
+	currency string
+	position int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+
+	t := p.tokens[p.next]
+	if t.kind != endToken {
+		p.next++
+	}
+	return t
+}
+
+// Checks whether the next token is the given operator
+func (p *parser) isOperator(operators ...string) bool {
+
+	next := p.peek()
+	for _, operator := range operators {
+		if next.kind == operatorToken && next.text == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether the next token is the given keyword
+func (p *parser) isKeyword(keyword string) bool {
+
+	next := p.peek()
+	return next.kind == identifierToken && next.text == keyword
+}
+
+func (p *parser) errorAt(offset int, format string, args ...any) *SyntaxError {
+	return newSyntaxError(p.text, offset, format, args...)
+}
+
+// Gets the text of a part of the expression, for describing it in an error
+func (p *parser) describe(e *expression) string {
+	return strings.TrimSpace(p.text[e.position:e.end])
+}
+
+// Checks that a part of the expression is true or false, for the given operator
+func (p *parser) requireBool(e *expression, operator token) error {
+
+	if e.typ != boolType {
+		return p.errorAt(e.position, "%s needs a condition (such as price < 1000), but %s is %s", operator.text, p.describe(e), e.typ)
+	}
+	return nil
+}
+
+func (p *parser) parseExpression() (*expression, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (*expression, error) {
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		operator := p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.requireBool(left, operator); err != nil {
+			return nil, err
+		}
+		if err := p.requireBool(right, operator); err != nil {
+			return nil, err
+		}
+
+		l, r := left, right
+		left = &expression{typ: boolType, position: l.position, end: r.end, evaluate: func(env *environment) (value, error) {
+			a, err := l.evaluate(env)
+			if err != nil || a.b {
+				return a, err
+			}
+			return r.evaluate(env)
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (*expression, error) {
+
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		operator := p.advance()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.requireBool(left, operator); err != nil {
+			return nil, err
+		}
+		if err := p.requireBool(right, operator); err != nil {
+			return nil, err
+		}
+
+		l, r := left, right
+		left = &expression{typ: boolType, position: l.position, end: r.end, evaluate: func(env *environment) (value, error) {
+			a, err := l.evaluate(env)
+			if err != nil || !a.b {
+				return a, err
+			}
+			return r.evaluate(env)
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (*expression, error) {
+
+	if !p.isOperator("!") {
+		return p.parseComparison()
+	}
+
+	operator := p.advance()
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.requireBool(operand, operator); err != nil {
+		return nil, err
+	}
+	return &expression{typ: boolType, position: operator.position, end: operand.end, evaluate: func(env *environment) (value, error) {
+		a, err := operand.evaluate(env)
+		return value{b: !a.b}, err
+	}}, nil
+}
+
+func (p *parser) parseComparison() (*expression, error) {
+
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isOperator("==", "!=", "<", "<=", ">", ">="):
+		operator := p.advance()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return p.compare(left, operator, right)
+
+	case p.isKeyword("in"):
+		p.advance()
+		return p.contains(left, false)
+
+	case p.isKeyword("not"):
+		not := p.advance()
+		if !p.isKeyword("in") {
+			return nil, p.errorAt(p.peek().position, "expected in after %s, but found %s", not.text, p.peek())
+		}
+		p.advance()
+		return p.contains(left, true)
+	}
+
+	return left, nil
+}
+
+// Compiles a comparison of two values with the given operator
+func (p *parser) compare(left *expression, operator token, right *expression) (*expression, error) {
+
+	if left.typ == listType || right.typ == listType {
+		list := left
+		if right.typ == listType {
+			list = right
+		}
+		return nil, p.errorAt(list.position, "a list can't be compared with %s; use in to check whether it contains a value", operator.text)
+	}
+	if !areComparable(left.typ, right.typ) {
+		return nil, p.errorAt(right.position, "%s is %s, so it can't be compared with %s, which is %s", p.describe(left), left.typ, p.describe(right), right.typ)
+	}
+	if operator.text != "==" && operator.text != "!=" && !isOrdered(left.typ) {
+		return nil, p.errorAt(operator.position, "%s is %s, which can only be compared with == or !=", p.describe(left), left.typ)
+	}
+
+	typ := left.typ
+	return &expression{typ: boolType, position: left.position, end: right.end, evaluate: func(env *environment) (value, error) {
+		a, err := left.evaluate(env)
+		if err != nil {
+			return value{}, err
+		}
+		b, err := right.evaluate(env)
+		if err != nil {
+			return value{}, err
+		}
+		result, err := compareValues(typ, a, b)
+		if err != nil {
+			return value{}, err
+		}
+
+		switch operator.text {
+		case "==":
+			return value{b: result == 0}, nil
+		case "!=":
+			return value{b: result != 0}, nil
+		case "<":
+			return value{b: result < 0}, nil
+		case "<=":
+			return value{b: result <= 0}, nil
+		case ">":
+			return value{b: result > 0}, nil
+		default:
+			return value{b: result >= 0}, nil
+		}
+	}}, nil
+}
+
+// Compiles a check of whether the list which comes next contains the given value
+func (p *parser) contains(item *expression, negate bool) (*expression, error) {
+
+	list, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if list.typ != listType {
+		return nil, p.errorAt(list.position, `expected a list after in (such as ["SK", "DY"]), but %s is %s`, p.describe(list), list.typ)
+	}
+	if item.typ == listType || !areComparable(item.typ, list.elem) {
+		return nil, p.errorAt(item.position, "%s is %s, so it can't be compared with the values in the list, which are %s", p.describe(item), item.typ, list.elem)
+	}
+
+	typ := item.typ
+	return &expression{typ: boolType, position: item.position, end: list.end, evaluate: func(env *environment) (value, error) {
+		a, err := item.evaluate(env)
+		if err != nil {
+			return value{}, err
+		}
+		elements, err := list.evaluate(env)
+		if err != nil {
+			return value{}, err
+		}
+
+		for _, element := range elements.list {
+			result, err := compareValues(typ, a, element)
+			if err != nil {
+				return value{}, err
+			}
+			if result == 0 {
+				return value{b: !negate}, nil
+			}
+		}
+		return value{b: negate}, nil
+	}}, nil
+}
+
+func (p *parser) parseAdditive() (*expression, error) {
+
+	left, err := p.parseNegative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		operator := p.advance()
+		right, err := p.parseNegative()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.add(left, operator, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// Compiles an addition or subtraction
+func (p *parser) add(left *expression, operator token, right *expression) (*expression, error) {
+
+	subtract := operator.text == "-"
+	e := &expression{position: left.position, end: right.end}
+	l, r := left, right
+
+	switch {
+	case left.typ == numberType && right.typ == numberType:
+		e.typ = numberType
+		e.evaluate = p.evaluateBoth(l, r, func(a value, b value) (value, error) {
+			if subtract {
+				return value{n: a.n - b.n}, nil
+			}
+			return value{n: a.n + b.n}, nil
+		})
+
+	case areComparable(left.typ, right.typ) && (left.typ == moneyType || right.typ == moneyType):
+		e.typ = moneyType
+		e.evaluate = p.evaluateBoth(l, r, func(a value, b value) (value, error) {
+			if err := checkCurrencies(a, b); err != nil {
+				return value{}, err
+			}
+			if subtract {
+				return value{n: a.n - b.n, currency: getCurrency(a, b)}, nil
+			}
+			return value{n: a.n + b.n, currency: getCurrency(a, b)}, nil
+		})
+
+	case left.typ == durationType && right.typ == durationType:
+		e.typ = durationType
+		e.evaluate = p.evaluateBoth(l, r, func(a value, b value) (value, error) {
+			if subtract {
+				return value{d: a.d - b.d}, nil
+			}
+			return value{d: a.d + b.d}, nil
+		})
+
+	case left.typ == timeType && right.typ == durationType:
+		e.typ = timeType
+		e.evaluate = p.evaluateBoth(l, r, func(a value, b value) (value, error) {
+			if subtract {
+				return value{t: a.t.Add(-b.d)}, nil
+			}
+			return value{t: a.t.Add(b.d)}, nil
+		})
+
+	case left.typ == timeType && right.typ == timeType && subtract:
+		e.typ = durationType
+		e.evaluate = p.evaluateBoth(l, r, func(a value, b value) (value, error) {
+			return value{d: a.t.Sub(b.t)}, nil
+		})
+
+	default:
+		return nil, p.errorAt(operator.position, "can't use %s with %s (%s) and %s (%s)", operator.text, p.describe(left), left.typ, p.describe(right), right.typ)
+	}
+
+	return e, nil
+}
+
+// Gets a function which evaluates both parts of the expression, and then combines them
+func (p *parser) evaluateBoth(left *expression, right *expression, combine func(a value, b value) (value, error)) func(env *environment) (value, error) {
+
+	return func(env *environment) (value, error) {
+		a, err := left.evaluate(env)
+		if err != nil {
+			return value{}, err
+		}
+		b, err := right.evaluate(env)
+		if err != nil {
+			return value{}, err
+		}
+		return combine(a, b)
+	}
+}
+
+func (p *parser) parseNegative() (*expression, error) {
+
+	if !p.isOperator("-") {
+		return p.parsePrimary()
+	}
+
+	operator := p.advance()
+	operand, err := p.parseNegative()
+	if err != nil {
+		return nil, err
+	}
+	if operand.typ != numberType && operand.typ != moneyType && operand.typ != durationType {
+		return nil, p.errorAt(operand.position, "- needs a number, an amount of money or a duration, but %s is %s", p.describe(operand), operand.typ)
+	}
+	return &expression{typ: operand.typ, position: operator.position, end: operand.end, evaluate: func(env *environment) (value, error) {
+		a, err := operand.evaluate(env)
+		return value{n: -a.n, currency: a.currency, d: -a.d}, err
+	}}, nil
+}
+
+func (p *parser) parsePrimary() (*expression, error) {
+
+	t := p.advance()
+	switch t.kind {
+	case numberToken:
+		return p.parseNumber(t)
+
+	case durationToken:
+		d, err := time.ParseDuration(t.text)
+		if err != nil {
+			return nil, p.errorAt(t.position, "%q is not a valid duration (such as 2h or 1h30m)", t.text)
+		}
+		return constant(durationType, value{d: d}, t), nil
+
+	case stringToken:
+		return constant(stringType, value{s: t.text}, t), nil
+
+	case identifierToken:
+		return p.parseIdentifier(t)
+
+	case operatorToken:
+		switch t.text {
+		case "(":
+			e, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOperator(")") {
+				return nil, p.errorAt(p.peek().position, "expected ) to close the ( at column %d, but found %s", getColumn(p.text, t.position), p.peek())
+			}
+			e.position, e.end = t.position, p.advance().end
+			return e, nil
+		case "[":
+			return p.parseList(t)
+		}
+	}
+
+	return nil, p.errorAt(t.position, "expected a value, but found %s", t)
+}
+
+// Parses a number, which is an amount of money if it is followed by a currency code (e.g. 1500 NOK)
+func (p *parser) parseNumber(t token) (*expression, error) {
+
+	n, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, p.errorAt(t.position, "%q is not a valid number", t.text)
+	}
+
+	if next := p.peek(); next.kind == identifierToken && isCurrencyCode(next.text) {
+		p.advance()
+		p.amounts = append(p.amounts, amount{currency: next.text, position: t.position})
+		return &expression{typ: moneyType, position: t.position, end: next.end, evaluate: func(env *environment) (value, error) {
+			return value{n: n, currency: next.text}, nil
+		}}, nil
+	}
+	return constant(numberType, value{n: n}, t), nil
+}
+
+// Checks for an ISO currency code, which is 3 upper-case letters
+func isCurrencyCode(text string) bool {
+	return len(text) == 3 && strings.ToUpper(text) == text && !strings.Contains(text, "_")
+}
+
+func (p *parser) parseIdentifier(t token) (*expression, error) {
+
+	switch t.text {
+	case "true", "false":
+		return constant(boolType, value{b: t.text == "true"}, t), nil
+	case "now":
+		return &expression{typ: timeType, position: t.position, end: t.end, evaluate: func(env *environment) (value, error) {
+			return value{t: env.now}, nil
+		}}, nil
+	case "in", "not":
+		return nil, p.errorAt(t.position, "expected a value before %s", t.text)
+	}
+
+	f, found := fields[t.text]
+	if !found {
+		if isCurrencyCode(t.text) {
+			return nil, p.errorAt(t.position, "expected an amount before the currency %s (such as 1500 %s)", t.text, t.text)
+		}
+		if strings.ToUpper(t.text) == t.text {
+			return nil, p.errorAt(t.position, "unknown field %q (for a string, put it in quotes, e.g. \"%s\")", t.text, t.text)
+		}
+		return nil, p.errorAt(t.position, "unknown field %q (the fields are %s)", t.text, strings.Join(getFieldNames(), ", "))
+	}
+	return &expression{typ: f.typ, elem: listFields[t.text], position: t.position, end: t.end, evaluate: func(env *environment) (value, error) {
+		return f.get(env.flight), nil
+	}}, nil
+}
+
+// Parses a list of values in square brackets, which must all be of the same type
+func (p *parser) parseList(open token) (*expression, error) {
+
+	elements := make([]*expression, 0)
+	elem := unknownType
+	for !p.isOperator("]") {
+		if len(elements) > 0 {
+			if !p.isOperator(",") {
+				return nil, p.errorAt(p.peek().position, "expected , or ] in the list, but found %s", p.peek())
+			}
+			p.advance()
+		}
+
+		e, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if e.typ == listType || !areComparable(elem, e.typ) {
+			return nil, p.errorAt(e.position, "%s is %s, but the values before it in the list are %s", p.describe(e), e.typ, elem)
+		}
+		if elem == unknownType || e.typ == moneyType {
+			elem = e.typ
+		}
+		elements = append(elements, e)
+	}
+	close := p.advance()
+
+	return &expression{typ: listType, elem: elem, position: open.position, end: close.end, evaluate: func(env *environment) (value, error) {
+		list := value{list: make([]value, 0, len(elements))}
+		for _, e := range elements {
+			v, err := e.evaluate(env)
+			if err != nil {
+				return value{}, err
+			}
+			list.list = append(list.list, v)
+		}
+		return list, nil
+	}}, nil
+}
+
+// Gets an expression for a value which is given in the text
+func constant(typ valueType, v value, t token) *expression {
+
+	return &expression{typ: typ, position: t.position, end: t.end, evaluate: func(env *environment) (value, error) {
+		return v, nil
+	}}
+}
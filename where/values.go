@@ -0,0 +1,111 @@
+package where
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The types of the values in an expression
+type valueType int
+
+const (
+	unknownType valueType = iota // The elements of an empty list
+	boolType
+	numberType
+	moneyType
+	stringType
+	durationType
+	timeType
+	listType
+)
+
+func (t valueType) String() string {
+
+	switch t {
+	case boolType:
+		return "true or false"
+	case numberType:
+		return "a number"
+	case moneyType:
+		return "an amount of money"
+	case stringType:
+		return "a string"
+	case durationType:
+		return "a duration"
+	case timeType:
+		return "a time"
+	case listType:
+		return "a list"
+	default:
+		return "unknown"
+	}
+}
+
+// A value in an expression. Only the fields for its type are set.
+type value struct {
+	b        bool
+	n        float64 // A number or an amount of money
+	currency string  // The currency of an amount of money, or empty if it was given as a plain number
+	s        string
+	d        time.Duration
+	t        time.Time
+	list     []value
+}
+
+// Checks whether values of the two types can be compared with each other. A plain number can be compared
+// with an amount of money (e.g. price < 1500), in which case it's taken to be in the same currency.
+func areComparable(a valueType, b valueType) bool {
+
+	isAmount := func(t valueType) bool { return t == numberType || t == moneyType }
+	return a == b || a == unknownType || b == unknownType || isAmount(a) && isAmount(b)
+}
+
+// Checks whether values of the type can be ordered, rather than only checked for equality
+func isOrdered(t valueType) bool {
+	return t == numberType || t == moneyType || t == stringType || t == durationType || t == timeType
+}
+
+// Compares two values of comparable types, returning -1, 0 or 1. Strings are compared ignoring case.
+func compareValues(t valueType, a value, b value) (int, error) {
+
+	switch t {
+	case boolType:
+		if a.b == b.b {
+			return 0, nil
+		}
+		return 1, nil
+	case numberType, moneyType:
+		if err := checkCurrencies(a, b); err != nil {
+			return 0, err
+		}
+		return cmp.Compare(a.n, b.n), nil
+	case stringType:
+		return cmp.Compare(strings.ToUpper(a.s), strings.ToUpper(b.s)), nil
+	case durationType:
+		return cmp.Compare(a.d, b.d), nil
+	case timeType:
+		return a.t.Compare(b.t), nil
+	default:
+		return 0, fmt.Errorf("can't compare %s", t)
+	}
+}
+
+// Checks that two amounts of money are in the same currency, unless one of them is a plain number
+func checkCurrencies(a value, b value) error {
+
+	if a.currency != "" && b.currency != "" && a.currency != b.currency {
+		return fmt.Errorf("can't compare an amount in %s with one in %s", a.currency, b.currency)
+	}
+	return nil
+}
+
+// Gets the currency of the result of adding or subtracting two amounts
+func getCurrency(a value, b value) string {
+
+	if a.currency != "" {
+		return a.currency
+	}
+	return b.currency
+}
@@ -0,0 +1,129 @@
+package where
+
+import (
+	"flynow/pricing"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// A small expression language for filtering the flight options, e.g.
+//
+//	price < 1500 NOK && dest.country != "NO" && depart >= now+2h && carrier in ["SK","DY"]
+//
+// The values are numbers, amounts of money (e.g. 1500 NOK), strings in single or double quotes, durations
+// (e.g. 2h or 1h30m), times (now, and fields such as depart), true and false, and lists in square
+// brackets. The operators are, in order of precedence:
+//
+//  1. + and -, for adding a duration to a time, or subtracting two times to get a duration
+//  2. == != < <= > >=, and in / not in for membership of a list (strings are compared ignoring case)
+//  3. ! (not)
+//  4. && (and)
+//  5. || (or)
+//
+// Parentheses can be used for grouping. See fields.go for the fields which can be used.
+
+// A compiled expression, which can be evaluated for each flight
+type Condition struct {
+	text    string
+	root    *expression
+	amounts []amount
+}
+
+// Parses and type-checks an expression, so that any mistake is reported before any flights are filtered.
+// A *SyntaxError gives the position of the mistake.
+func Compile(text string) (*Condition, error) {
+
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{text: text, tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != endToken {
+		return nil, p.errorAt(next.position, "expected && or || before %s", next)
+	}
+	if root.typ != boolType {
+		return nil, p.errorAt(0, "the expression is %s, rather than a condition (such as price < 1000)", root.typ)
+	}
+
+	return &Condition{text: text, root: root, amounts: p.amounts}, nil
+}
+
+// Checks that every amount of money in the expression is in the given currency, which the prices are
+// in, so that a mismatch (e.g. price < 100 EUR when searching in NOK) is reported before searching rather
+// than when the first flight is checked. A *SyntaxError gives the position of the amount.
+func (condition *Condition) CheckCurrency(currency string) error {
+
+	for _, a := range condition.amounts {
+		if a.currency != currency {
+			return newSyntaxError(condition.text, a.position, "the amount is in %s, but the prices are in %s", a.currency, currency)
+		}
+	}
+	return nil
+}
+
+// Checks whether the flight matches the condition, where "now" is the given time
+func (condition *Condition) Matches(flight pricing.FlightForPurchase, now time.Time) (bool, error) {
+
+	result, err := condition.root.evaluate(&environment{flight: &flight, now: now})
+	if err != nil {
+		return false, fmt.Errorf("evaluating %q for %s: %w", condition.text, flight.FlightNumber, err)
+	}
+	return result.b, nil
+}
+
+// Gets the flights which match the condition, in the same order, where "now" is the given time
+func (condition *Condition) Filter(flights []pricing.FlightForPurchase, now time.Time) ([]pricing.FlightForPurchase, error) {
+
+	results := make([]pricing.FlightForPurchase, 0, len(flights))
+	for _, flight := range flights {
+		matches, err := condition.Matches(flight, now)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			results = append(results, flight)
+		}
+	}
+	return results, nil
+}
+
+func (condition *Condition) String() string {
+	return condition.text
+}
+
+// A mistake in an expression, and where it is
+type SyntaxError struct {
+	Expression string
+	Offset     int // The byte offset of the mistake, from 0
+	Message    string
+}
+
+func newSyntaxError(text string, offset int, format string, args ...any) *SyntaxError {
+	return &SyntaxError{Expression: text, Offset: offset, Message: fmt.Sprintf(format, args...)}
+}
+
+// Gets the column of the mistake, counting the characters from 1
+func (err *SyntaxError) Column() int {
+	return getColumn(err.Expression, err.Offset)
+}
+
+// Gets the column of the given byte offset, counting the characters from 1
+func getColumn(text string, offset int) int {
+	return utf8.RuneCountInString(text[:offset]) + 1
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", err.Column(), err.Message)
+}
+
+// Shows the expression, with a ^ under the mistake
+func (err *SyntaxError) Pointer() string {
+	return err.Expression + "\n" + strings.Repeat(" ", err.Column()-1) + "^"
+}
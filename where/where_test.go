@@ -0,0 +1,233 @@
+package where
+
+import (
+	"errors"
+	"flynow/pricing"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 4, 15, 6, 0, 0, 0, time.UTC)
+
+func getTestFlight() pricing.FlightForPurchase {
+
+	return pricing.FlightForPurchase{
+		FlightNumber: "SK484",
+		Origin:       "OSL",
+		Destination:  "ARN",
+		Departure:    testNow.Add(3 * time.Hour),
+		Arrival:      testNow.Add(3*time.Hour + 55*time.Minute),
+		Price:        650,
+		Currency:     "NOK",
+		PriceDetails: pricing.PriceBreakdown{Total: 650, Base: 520, Taxes: 130, IncludedBags: 1},
+		Fare: pricing.FareDetails{Brand: "GO", Cabin: "ECONOMY", BookingClass: "Y", Amenities: []pricing.Amenity{
+			{Description: "CHECKED BAG", Category: pricing.AmenityCheckedBag},
+			{Description: "WIFI", Category: pricing.AmenityWifi, Chargeable: true},
+		}},
+		Distance: 385,
+	}
+}
+
+func TestMatches(t *testing.T) {
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`price < 1500 NOK && dest.country != "NO" && depart >= now+2h && carrier in ["SK","DY"]`, true},
+		{`price < 600`, false},
+		{`price <= 650 NOK`, true},
+		{`price.base + price.taxes == price`, true},
+		{`dest.country == "SE" && dest.city == 'stockholm'`, true},
+		{`carrier not in ["SK", "DY"]`, false},
+		{`flight == "sk484"`, true},
+		{`depart - now < 2h || duration > 1h`, false},
+		{`arrive <= now + 3h55m`, true},
+		{`!(stops > 0) && bags >= 1`, true},
+		{`"checked-bag" in amenities && !("wifi" in amenities)`, true},
+		{`fare.cabin == "ECONOMY" && fare.class in ["Y", "B"]`, true},
+		{`distance > 300 && value < 200`, true},
+		{`transfer.time == 0m && transfer.cost == 0`, true},
+		{`co2 > 0 || aircraft == ""`, true},
+		{`origin.name == "Oslo Gardermoen Airport" && -price < -600`, true},
+		{`true && (false || dest in [])`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+
+			// Arrange
+			condition, err := Compile(test.expression)
+			if err != nil {
+				t.Fatalf("Compile failed: %v", err)
+			}
+
+			// Act
+			actual, err := condition.Matches(getTestFlight(), testNow)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Matches failed: %v", err)
+			}
+			if actual != test.expected {
+				t.Errorf("Got %t; Expected %t", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	tests := []struct {
+		expression string
+		column     int
+		expected   string
+	}{
+		{`price < `, 9, "expected a value, but found the end of the expression"},
+		{`price < 1500 NOK &&`, 20, "expected a value"},
+		{`price < 1500 NOK dest == "SE"`, 18, `expected && or || before "dest"`},
+		{`dst == "SE"`, 1, `unknown field "dst"`},
+		{`carrier in [SK, DY]`, 13, `unknown field "SK" (for a string, put it in quotes, e.g. "SK")`},
+		{`price < "cheap"`, 9, `price is an amount of money, so it can't be compared with "cheap", which is a string`},
+		{`depart > now + 2x`, 16, `"2x" is not a valid duration`},
+		{`dest == "SE`, 9, "the string isn't closed"},
+		{`price < 1500 NOK & dest == "SE"`, 18, `unexpected character '&'`},
+		{`(price < 1500`, 14, "expected ) to close the ( at column 1"},
+		{`price`, 1, "the expression is an amount of money, rather than a condition"},
+		{`price && true`, 1, "&& needs a condition (such as price < 1000), but price is an amount of money"},
+		{`dest in "SE"`, 9, "expected a list after in"},
+		{`dest in [1, 2]`, 1, "dest is a string, so it can't be compared with the values in the list, which are a number"},
+		{`dest in ["SE", 2]`, 16, "2 is a number, but the values before it in the list are a string"},
+		{`dest not ["SE"]`, 10, "expected in after not"},
+		{`true < false`, 6, "true is true or false, which can only be compared with == or !="},
+		{`depart + now > now`, 8, "can't use + with depart (a time) and now (a time)"},
+		{`amenities == "wifi"`, 1, "a list can't be compared with =="},
+		{`price < NOK`, 9, "expected an amount before the currency NOK"},
+		{`dest == "Tromsø" && prïce > 0`, 23, `unexpected character 'ï'`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+
+			// Act
+			_, err := Compile(test.expression)
+
+			// Assert
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Got error %v; Expected a syntax error", err)
+			}
+			if syntaxErr.Column() != test.column {
+				t.Errorf("Got error at column %d; Expected column %d (%v)", syntaxErr.Column(), test.column, err)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Got error %q; Expected it to contain %q", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorPointer(t *testing.T) {
+
+	// Arrange
+	_, err := Compile(`price < 1500 NOK && dest.country != `)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Got error %v; Expected a syntax error", err)
+	}
+
+	// Act
+	actual := syntaxErr.Pointer()
+
+	// Assert
+	expected := "price < 1500 NOK && dest.country != \n" + strings.Repeat(" ", 36) + "^"
+	if actual != expected {
+		t.Errorf("Got pointer\n%s\nExpected\n%s", actual, expected)
+	}
+}
+
+func TestMatchesReportsCurrencyMismatch(t *testing.T) {
+
+	// Arrange
+	condition, err := Compile("price < 100 EUR")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	// Act
+	_, err = condition.Matches(getTestFlight(), testNow)
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "can't compare an amount in NOK with one in EUR") {
+		t.Errorf("Got error %v; Expected the currencies not to be comparable", err)
+	}
+}
+
+func TestCheckCurrency(t *testing.T) {
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"price < 1500 NOK && duration < 2h", ""},
+		{"price < 1500", ""},
+		{"price < 1500 NOK || price + 10 EUR < 200 NOK", "column 29: the amount is in EUR, but the prices are in NOK"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+
+			// Arrange
+			condition, err := Compile(test.expression)
+			if err != nil {
+				t.Fatalf("Compile failed: %v", err)
+			}
+
+			// Act
+			err = condition.CheckCurrency("NOK")
+
+			// Assert
+			actual := ""
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != test.expected {
+				t.Errorf("Got error %q; Expected %q", actual, test.expected)
+			}
+		})
+	}
+}
+
+// Compiles the expression, and gets the flights which match it now
+func compileAndFilter(t *testing.T, expression string, flights ...pricing.FlightForPurchase) ([]pricing.FlightForPurchase, error) {
+
+	condition, err := Compile(expression)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return condition.Filter(flights, time.Now())
+}
+
+func TestFilter(t *testing.T) {
+
+	// Arrange
+	cheap := getTestFlight()
+	expensive := getTestFlight()
+	expensive.FlightNumber, expensive.Destination, expensive.Price = "DY932", "CPH", 899
+
+	// Act
+	actual, err := compileAndFilter(t, `price < 800 || dest.country == "DK"`, expensive, cheap)
+	none, noneErr := compileAndFilter(t, `depart > now`, expensive, cheap)
+
+	// Assert
+	if err != nil || noneErr != nil {
+		t.Fatalf("Filter failed: %v, %v", err, noneErr)
+	}
+	if len(actual) != 2 || actual[0].FlightNumber != "DY932" || actual[1].FlightNumber != "SK484" {
+		t.Errorf("Got %v; Expected both flights, in the same order", actual)
+	}
+	if len(none) != 0 {
+		t.Errorf("Got %v; Expected no flights, since they departed in 2024", none)
+	}
+}